@@ -6,19 +6,26 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/go-git/go-git/v5"
 	"github.com/nix-community/nixos-cli/internal/activation"
 	"github.com/nix-community/nixos-cli/internal/build"
+	"github.com/nix-community/nixos-cli/internal/closure"
 	"github.com/nix-community/nixos-cli/internal/cmd/nixopts"
 	"github.com/nix-community/nixos-cli/internal/cmd/opts"
 	"github.com/nix-community/nixos-cli/internal/cmd/utils"
 	"github.com/nix-community/nixos-cli/internal/configuration"
 	"github.com/nix-community/nixos-cli/internal/constants"
+	"github.com/nix-community/nixos-cli/internal/flake"
 	"github.com/nix-community/nixos-cli/internal/generation"
+	"github.com/nix-community/nixos-cli/internal/git"
+	"github.com/nix-community/nixos-cli/internal/history"
+	"github.com/nix-community/nixos-cli/internal/hooks"
+	"github.com/nix-community/nixos-cli/internal/lock"
 	"github.com/nix-community/nixos-cli/internal/logger"
 	"github.com/nix-community/nixos-cli/internal/settings"
 	"github.com/nix-community/nixos-cli/internal/system"
+	timeUtils "github.com/nix-community/nixos-cli/internal/time"
 	"github.com/nix-community/nixos-cli/internal/utils"
 	"github.com/spf13/cobra"
 )
@@ -28,7 +35,7 @@ func ApplyCommand(cfg *settings.Settings) *cobra.Command {
 
 	usage := "apply"
 	if buildOpts.Flake == "true" {
-		usage += " [FLAKE-REF]"
+		usage += " [FLAKE-REF...]"
 	}
 
 	cmd := cobra.Command{
@@ -37,10 +44,9 @@ func ApplyCommand(cfg *settings.Settings) *cobra.Command {
 		Long:  "Build and activate a NixOS system from a given configuration.",
 		Args: func(cmd *cobra.Command, args []string) error {
 			if buildOpts.Flake == "true" {
-				if err := cobra.MaximumNArgs(1)(cmd, args); err != nil {
-					return err
-				}
-				if len(args) > 0 {
+				if isMultiHostApply(args) {
+					opts.FlakeRefs = args
+				} else if len(args) > 0 {
 					opts.FlakeRef = args[0]
 				}
 			} else {
@@ -56,12 +62,74 @@ func ApplyCommand(cfg *settings.Settings) *cobra.Command {
 					return fmt.Errorf("--install-bootloader requires activation, remove --no-activate and/or --no-boot to use this option")
 				}
 
-				if opts.OutputPath == "" {
+				// Multiple configurations are only built to check them,
+				// so there is no single output to link to.
+				if opts.OutputPath == "" && len(opts.FlakeRefs) == 0 {
 					return fmt.Errorf("if --no-activate and --no-boot are both specified, --output must be specified too")
 				}
 			}
 
-			if buildOpts.Flake == "true" && opts.GenerationTag != "" && !opts.NixOptions.Impure {
+			if opts.TargetHost != "" {
+				if opts.BuildVM || opts.BuildVMWithBootloader {
+					return fmt.Errorf("--target-host cannot be used when building a VM")
+				}
+				if opts.NoActivate && opts.NoBoot {
+					return fmt.Errorf("--target-host requires activation, remove --no-activate and/or --no-boot to use this option")
+				}
+			} else if opts.UseRemoteRoot {
+				return fmt.Errorf("--use-remote-root requires --target-host to be set")
+			}
+
+			if opts.ConfirmTimeout != "" {
+				timeout, err := timeUtils.DurationFromTimeSpan(opts.ConfirmTimeout)
+				if err != nil {
+					return fmt.Errorf("invalid value for --confirm-timeout: %v", err.Error())
+				}
+				if timeout < time.Second {
+					return fmt.Errorf("--confirm-timeout must be at least one second")
+				}
+				if opts.NoActivate || opts.BuildVM || opts.BuildVMWithBootloader {
+					return fmt.Errorf("--confirm-timeout requires activation, remove --no-activate to use this option")
+				}
+			}
+
+			if opts.File != "" || opts.Attr != "" {
+				if opts.FlakeRef != "" || len(opts.FlakeRefs) > 0 {
+					return fmt.Errorf("--file and --attr cannot be used with a flake ref")
+				}
+				if len(opts.UpdateFlakeInputs) > 0 {
+					return fmt.Errorf("--update cannot be used with --file or --attr")
+				}
+			}
+
+			if len(opts.FlakeRefs) > 0 {
+				if opts.TargetHost != "" || opts.BuildHost != "" {
+					return fmt.Errorf("--target-host and --build-host cannot be used when building multiple configurations")
+				}
+				if opts.BuildVM || opts.BuildVMWithBootloader || opts.OutputPath != "" {
+					return fmt.Errorf("--vm, --vm-with-bootloader, and --output cannot be used when building multiple configurations")
+				}
+				if len(opts.UpdateFlakeInputs) > 0 || opts.Confirm {
+					return fmt.Errorf("--update and --confirm cannot be used when building multiple configurations")
+				}
+			}
+			if opts.Parallel < 0 {
+				return fmt.Errorf("--parallel must be a positive number")
+			}
+
+			if opts.RebootIfNeeded != "" {
+				if opts.RebootIfNeeded != rebootImmediately {
+					if _, err := timeUtils.DurationFromTimeSpan(opts.RebootIfNeeded); err != nil {
+						return fmt.Errorf("invalid value for --reboot-if-needed: %v", err.Error())
+					}
+				}
+				if opts.Dry || opts.NoBoot || opts.BuildVM || opts.BuildVMWithBootloader {
+					return fmt.Errorf("--reboot-if-needed requires a boot entry, remove --dry and/or --no-boot to use this option")
+				}
+			}
+
+			usesFlake := buildOpts.Flake == "true" && opts.File == "" && opts.Attr == ""
+			if usesFlake && opts.GenerationTag != "" && !opts.NixOptions.Impure {
 				if cfg.Apply.ImplyImpureWithTag {
 					if err := cmd.Flags().Set("impure", "true"); err != nil {
 						panic("failed to set --impure flag for apply command before exec with explicit generation tag")
@@ -70,25 +138,49 @@ func ApplyCommand(cfg *settings.Settings) *cobra.Command {
 					return fmt.Errorf("--impure is required when using --tag for flake configurations")
 				}
 			}
+
+			// Closures built on a build host are pushed from the target
+			// host, which does not have access to the local signing key.
+			pushTo := opts.PushTo
+			if pushTo == "" {
+				pushTo = cfg.Apply.PushTo
+			}
+			if pushTo != "" && cfg.Apply.PushSigningKey != "" && opts.BuildHost != "" && opts.TargetHost != "" {
+				return fmt.Errorf("apply.push_signing_key cannot be used when pushing with both --build-host and --target-host")
+			}
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(opts.FlakeRefs) > 0 {
+				return cmdUtils.CommandErrorHandler(multiHostApplyMain(cmd, &opts))
+			}
 			return cmdUtils.CommandErrorHandler(applyMain(cmd, &opts))
 		},
 	}
 
+	cmd.Flags().StringVar(&opts.Attr, "attr", "", "Build the configuration at attribute `path` in --file")
+	cmd.Flags().StringVar(&opts.BuildHost, "build-host", "", "Realise the configuration on a remote `host` over SSH")
+	cmd.Flags().BoolVar(&opts.Confirm, "confirm", false, "Confirm a previous activation that is awaiting confirmation")
+	cmd.Flags().StringVar(&opts.ConfirmTimeout, "confirm-timeout", "", "Roll back unless activation is confirmed within `period`")
 	cmd.Flags().BoolVarP(&opts.Dry, "dry", "d", false, "Show what would be built or ran")
+	cmd.Flags().StringVar(&opts.File, "file", "", "Build the configuration from a Nix `file` or directory")
 	cmd.Flags().BoolVar(&opts.InstallBootloader, "install-bootloader", false, "(Re)install the bootloader on the configured device(s)")
 	cmd.Flags().BoolVar(&opts.NoActivate, "no-activate", false, "Do not activate the built configuration")
 	cmd.Flags().BoolVar(&opts.NoBoot, "no-boot", false, "Do not create boot entry for this generation")
 	cmd.Flags().StringVarP(&opts.OutputPath, "output", "o", "", "Symlink the output to `location`")
 	cmd.Flags().StringVarP(&opts.ProfileName, "profile-name", "p", "system", "Store generations using the profile `name`")
+	cmd.Flags().StringVar(&opts.PushTo, "push-to", "", "Copy the built closure to the binary cache at `store-uri`")
+	cmd.Flags().StringVar(&opts.RebootIfNeeded, "reboot-if-needed", "", "Reboot after activation if required, optionally after `period`")
+	cmd.Flags().Lookup("reboot-if-needed").NoOptDefVal = rebootImmediately
 	cmd.Flags().StringVarP(&opts.Specialisation, "specialisation", "s", "", "Activate the specialisation with `name`")
 	cmd.Flags().StringVarP(&opts.GenerationTag, "tag", "t", "", "Tag this generation with a `description`")
+	cmd.Flags().StringVar(&opts.TargetHost, "target-host", "", "Activate the configuration on a remote `host` over SSH")
+	cmd.Flags().BoolVar(&opts.UseRemoteRoot, "use-remote-root", false, "Use 'root_command' to run activation commands on the target host")
 	cmd.Flags().BoolVar(&opts.UseNom, "use-nom", false, "Use 'nix-output-monitor' to build configuration")
 	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", opts.Verbose, "Show verbose logging")
 	cmd.Flags().BoolVar(&opts.BuildVM, "vm", false, "Build a NixOS VM script")
 	cmd.Flags().BoolVar(&opts.BuildVMWithBootloader, "vm-with-bootloader", false, "Build a NixOS VM script with a bootloader")
+	cmd.Flags().BoolVar(&opts.WaitForLock, "wait", false, "Wait for other operations in progress to finish")
 	cmd.Flags().BoolVarP(&opts.AlwaysConfirm, "yes", "y", false, "Automatically confirm activation")
 
 	nixopts.AddQuietNixOption(&cmd, &opts.NixOptions.Quiet)
@@ -118,6 +210,10 @@ func ApplyCommand(cfg *settings.Settings) *cobra.Command {
 		nixopts.AddCommitLockFileNixOption(&cmd, &opts.NixOptions.CommitLockFile)
 		nixopts.AddUpdateInputNixOption(&cmd, &opts.NixOptions.UpdateInputs)
 		nixopts.AddOverrideInputNixOption(&cmd, &opts.NixOptions.OverrideInputs)
+
+		cmd.Flags().IntVar(&opts.Parallel, "parallel", defaultParallelBuilds, "Build at most `N` configurations at once when building multiple")
+		cmd.Flags().StringSliceVarP(&opts.UpdateFlakeInputs, "update", "u", nil, "Update flake `inputs` before building, or all inputs if none are given")
+		cmd.Flags().Lookup("update").NoOptDefVal = updateAllInputs
 	}
 
 	if buildOpts.Flake == "false" {
@@ -126,22 +222,31 @@ func ApplyCommand(cfg *settings.Settings) *cobra.Command {
 	}
 
 	_ = cmd.RegisterFlagCompletionFunc("profile-name", generation.CompleteProfileFlag)
-	_ = cmd.RegisterFlagCompletionFunc("specialisation", generation.CompleteSpecialisationFlagFromConfig(opts.FlakeRef, opts.NixOptions.Includes))
+	_ = cmd.RegisterFlagCompletionFunc("specialisation", generation.CompleteSpecialisationFlagFromConfig(&opts.FlakeRef, &opts.File, &opts.Attr, &opts.NixOptions.Includes))
 
 	cmd.MarkFlagsMutuallyExclusive("dry", "output")
 	cmd.MarkFlagsMutuallyExclusive("vm", "vm-with-bootloader")
 	cmd.MarkFlagsMutuallyExclusive("no-activate", "specialisation")
+	cmd.MarkFlagsMutuallyExclusive("confirm", "confirm-timeout")
+	cmd.MarkFlagsMutuallyExclusive("confirm-timeout", "reboot-if-needed")
 
 	helpTemplate := cmd.HelpTemplate()
 	if buildOpts.Flake == "true" {
 		helpTemplate += `
 Arguments:
-  [FLAKE-REF]  Flake ref to build configuration from (default: $NIXOS_CONFIG)
+  [FLAKE-REF...]  Flake refs to build configurations from (default: $NIXOS_CONFIG)
+
+Multiple flake refs, or a configuration name that is a glob pattern (such
+as '.#web-*') or 'all', build several configurations at once. Only the
+configuration matching the local hostname is activated.
 `
 	}
 	helpTemplate += `
 This command also forwards Nix options passed here to all relevant Nix invocations.
 Check the man page nixos-cli-apply(5) for more details on what options are available.
+
+The 'period' parameters in --confirm-timeout and --reboot-if-needed are
+systemd.time(7) spans (i.e. "5m 30s"). Check the manual page for more information.
 `
 
 	cmdUtils.SetHelpFlagText(&cmd)
@@ -150,13 +255,51 @@ Check the man page nixos-cli-apply(5) for more details on what options are avail
 	return &cmd
 }
 
-func applyMain(cmd *cobra.Command, opts *cmdOpts.ApplyOpts) error {
+func applyMain(cmd *cobra.Command, opts *cmdOpts.ApplyOpts) (err error) {
 	log := logger.FromContext(cmd.Context())
 	cfg := settings.FromContext(cmd.Context())
 	s := system.NewLocalSystem(log)
 
-	if !s.IsNixOS() {
+	// The target system is where the configuration gets activated.
+	// This is the local system, unless a remote host was specified.
+	var targetHost system.System = s
+	var remoteTargetHost *system.SSHSystem
+	if opts.TargetHost != "" {
+		rootCommand := ""
+		if opts.UseRemoteRoot {
+			rootCommand = cfg.RootCommand
+		}
+
+		sshSystem, err := system.NewSSHSystem(log, opts.TargetHost, &system.SSHSystemOptions{
+			RootCommand: rootCommand,
+		})
+		if err != nil {
+			log.Errorf("failed to initialize connection to target host: %v", err)
+			return err
+		}
+		defer sshSystem.Close()
+
+		targetHost = sshSystem
+		remoteTargetHost = sshSystem
+	}
+
+	var buildHost *system.SSHSystem
+	if opts.BuildHost != "" {
+		sshSystem, err := system.NewSSHSystem(log, opts.BuildHost, &system.SSHSystemOptions{})
+		if err != nil {
+			log.Errorf("failed to initialize connection to build host: %v", err)
+			return err
+		}
+		defer sshSystem.Close()
+
+		buildHost = sshSystem
+	}
+
+	if !targetHost.IsNixOS() {
 		msg := "this command only is only supported on NixOS systems"
+		if targetHost.IsRemote() {
+			msg = fmt.Sprintf("target host %v is not a NixOS system, or is unreachable", opts.TargetHost)
+		}
 		log.Errorf(msg)
 		return fmt.Errorf("%v", msg)
 	}
@@ -170,7 +313,9 @@ func applyMain(cmd *cobra.Command, opts *cmdOpts.ApplyOpts) error {
 		buildType = configuration.SystemBuildTypeSystem
 	}
 
-	if os.Geteuid() != 0 {
+	// Remote activation does not need local root privileges, since
+	// all privileged operations are performed on the target host.
+	if os.Geteuid() != 0 && !targetHost.IsRemote() {
 		err := utils.ExecAsRoot(cfg.RootCommand)
 		if err != nil {
 			log.Errorf("failed to re-exec command as root: %v", err)
@@ -178,6 +323,20 @@ func applyMain(cmd *cobra.Command, opts *cmdOpts.ApplyOpts) error {
 		}
 	}
 
+	modifiesProfile := applyModifiesProfile(opts, buildType)
+
+	operationLock, err := acquireApplyLock(log, targetHost, "/", opts, buildType)
+	if err != nil {
+		return err
+	}
+	if operationLock != nil {
+		defer cmdUtils.ReleaseOperationLock(log, operationLock)
+	}
+
+	if opts.Confirm {
+		return confirmActivation(log, targetHost, opts.Verbose)
+	}
+
 	if opts.Verbose {
 		log.Step("Looking for configuration...")
 	}
@@ -189,6 +348,13 @@ func applyMain(cmd *cobra.Command, opts *cmdOpts.ApplyOpts) error {
 			log.Errorf("failed to infer hostname: %v", err)
 			return err
 		}
+	} else if opts.File != "" || opts.Attr != "" {
+		c, err := configuration.FileConfigurationFromPath(opts.File, opts.Attr, opts.NixOptions.Includes)
+		if err != nil {
+			log.Errorf("failed to find configuration: %v", err)
+			return err
+		}
+		nixConfig = c
 	} else {
 		c, err := configuration.FindConfiguration(log, cfg, opts.NixOptions.Includes, opts.Verbose)
 		if err != nil {
@@ -203,23 +369,34 @@ func applyMain(cmd *cobra.Command, opts *cmdOpts.ApplyOpts) error {
 	var configDirname string
 	switch c := nixConfig.(type) {
 	case *configuration.FlakeRef:
-		configDirname = c.URI
+		// Relative paths would no longer refer to the same
+		// flake after changing to the configuration directory.
+		if err := c.MakeAbsolute(); err != nil {
+			log.Errorf("failed to resolve flake path: %v", err)
+			return err
+		}
+		// Flakes that are not on the local filesystem, such as
+		// remote flakes or flakes accessed through the registry,
+		// have no configuration directory.
+		if dir, ok := c.LocalDirectory(); ok {
+			configDirname = dir
+		}
 	case *configuration.LegacyConfiguration:
 		configDirname = c.ConfigDirname
+	case *configuration.FileConfiguration:
+		configDirname = c.Dirname()
 	}
 
-	configIsDirectory := true
+	configIsDirectory := configDirname != ""
 	originalCwd, err := os.Getwd()
 	if err != nil {
 		log.Errorf("failed to get current directory: %v", err)
 		return err
 	}
 	if configDirname != "" {
-		// Change to the configuration directory, if it exists:
-		// this will likely fail for remote configurations or
-		// configurations accessed through the registry, which
-		// should be a rare occurrence, but valid, so ignore any
-		// errors in that case.
+		// Change to the configuration directory, if it exists.
+		// Legacy configurations may point to a single file, in
+		// which case this fails, so ignore any errors.
 		err := os.Chdir(configDirname)
 		if err != nil {
 			configIsDirectory = false
@@ -238,6 +415,39 @@ func applyMain(cmd *cobra.Command, opts *cmdOpts.ApplyOpts) error {
 		}
 	}
 
+	if len(opts.UpdateFlakeInputs) > 0 {
+		if !configIsDirectory {
+			msg := "--update requires the flake to be a local directory"
+			log.Error(msg)
+			return fmt.Errorf("%v", msg)
+		}
+
+		log.Step("Updating flake inputs...")
+
+		err := updateFlakeInputs(log, s, &updateInputsOptions{
+			Inputs:  opts.UpdateFlakeInputs,
+			Commit:  opts.NixOptions.CommitLockFile && !opts.Dry,
+			Verbose: opts.Verbose,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	var gitProvenance *git.Provenance
+	var gitProvenanceErr error
+	if configIsDirectory && configDirname != "" {
+		// The configuration directory is now the working directory,
+		// and configDirname may have been relative to the original.
+		gitProvenance, gitProvenanceErr = collectGitProvenance(log, ".")
+	}
+
+	if modifiesProfile {
+		if err := checkGitPolicy(log, cfg.Apply.GitPolicy, gitProvenance, gitProvenanceErr); err != nil {
+			return err
+		}
+	}
+
 	if buildType.IsVM() {
 		log.Step("Building VM...")
 	} else {
@@ -251,6 +461,15 @@ func applyMain(cmd *cobra.Command, opts *cmdOpts.ApplyOpts) error {
 		log.Error("--use-nom was specified, but `nom` is not executable")
 	} else if cfg.Apply.UseNom && !nomFound {
 		log.Warn("apply.use_nom is specified in config, but `nom` is not executable")
+		if cfg.Apply.ShowProgress {
+			log.Warn("falling back to built-in build progress display")
+		} else {
+			log.Warn("falling back to `nix` command for building")
+		}
+		useNom = false
+	}
+	if useNom && buildHost != nil {
+		log.Warn("nix-output-monitor cannot be used with --build-host")
 		log.Warn("falling back to `nix` command for building")
 		useNom = false
 	}
@@ -259,15 +478,12 @@ func applyMain(cmd *cobra.Command, opts *cmdOpts.ApplyOpts) error {
 	if generationTag == "" && cfg.Apply.UseGitCommitMsg {
 		if !configIsDirectory {
 			log.Warn("configuration is not a directory")
+		} else if gitProvenance == nil {
+			log.Warn("configuration is not in a git repository")
+		} else if gitProvenance.Dirty && !cfg.Apply.IgnoreDirtyTree {
+			log.Warn("git tree is dirty")
 		} else {
-			commitMsg, err := getLatestGitCommitMessage(configDirname, cfg.Apply.IgnoreDirtyTree)
-			if err == dirtyGitTreeError {
-				log.Warnf("failed to get latest git commit message: %v", err)
-			} else if err != nil {
-				log.Warn("git tree is dirty")
-			} else {
-				generationTag = commitMsg
-			}
+			generationTag = gitProvenance.Message
 		}
 
 		generationTag = strings.TrimSpace(generationTag)
@@ -283,6 +499,53 @@ func applyMain(cmd *cobra.Command, opts *cmdOpts.ApplyOpts) error {
 		}
 	}
 
+	// Hooks are meant to run around real changes to the
+	// system, so they are skipped for dry runs entirely.
+	hookSettings := &cfg.Hooks
+	if opts.Dry {
+		hookSettings = &settings.HookSettings{}
+	}
+
+	// This is also used by `auto-upgrade`, so use
+	// the name of whichever command is running.
+	hookCtx := &hooks.Context{
+		Command:    cmd.Name(),
+		Profile:    opts.ProfileName,
+		Tag:        generationTag,
+		TargetHost: opts.TargetHost,
+	}
+	if currentGenNumber, err := activation.GetCurrentGenerationNumber(targetHost, opts.ProfileName); err == nil {
+		hookCtx.OldGeneration = currentGenNumber
+	}
+
+	// Only builds that are activated or added to the boot
+	// menu change the system, so only those are recorded.
+	historyRecord := history.NewRecord(cmd.Name(), opts.ProfileName)
+	historyRecord.Tag = generationTag
+	historyRecord.PreviousGeneration = hookCtx.OldGeneration
+	if !opts.Dry && buildType == configuration.SystemBuildTypeSystemActivation {
+		defer func() {
+			historyRecord.NewGeneration = hookCtx.NewGeneration
+			cmdUtils.WriteHistoryRecord(log, targetHost, historyRecord, err)
+		}()
+	}
+
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		hookCtx.Error = err
+		if err := hooks.Run(s, hookSettings, hooks.StageOnFailure, hookCtx, opts.Verbose); err != nil {
+			log.Warnf("%v", err)
+		}
+	}()
+
+	if err := hooks.Run(s, hookSettings, hooks.StagePreBuild, hookCtx, opts.Verbose); err != nil {
+		log.Errorf("%v", err)
+		return err
+	}
+
 	// Dry activation requires a real build, so --dry-run shouldn't be set
 	// if --activate or --boot is set
 	dryBuild := opts.Dry && buildType == configuration.SystemBuildTypeSystem
@@ -298,9 +561,13 @@ func applyMain(cmd *cobra.Command, opts *cmdOpts.ApplyOpts) error {
 		UseNom:         useNom,
 		GenerationTag:  generationTag,
 		Verbose:        opts.Verbose,
+		ShowProgress:   cfg.Apply.ShowProgress && log.OutputFormat() == logger.OutputFormatText,
 
 		CmdFlags: cmd.Flags(),
 		NixOpts:  &opts.NixOptions,
+
+		BuildHost:  buildHost,
+		TargetHost: remoteTargetHost,
 	}
 
 	resultLocation, err := nixConfig.BuildSystem(buildType, buildOptions)
@@ -309,6 +576,54 @@ func applyMain(cmd *cobra.Command, opts *cmdOpts.ApplyOpts) error {
 		return err
 	}
 
+	log.Event("build_result", map[string]any{
+		"path":       resultLocation,
+		"build_attr": buildType.BuildAttr(),
+		"dry":        dryBuild,
+	})
+
+	hookCtx.StorePath = resultLocation
+
+	// The lock file is read after building, since building can
+	// add missing inputs to the lock file.
+	var flakeLock *flake.LockFile
+	if flakeRef, ok := nixConfig.(*configuration.FlakeRef); ok && modifiesProfile {
+		lock, err := readFlakeLock(s, flakeRef, configIsDirectory)
+		if err != nil {
+			log.Warnf("failed to read flake lock file: %v", err)
+		}
+		flakeLock = lock
+	}
+
+	if err := hooks.Run(s, hookSettings, hooks.StagePostBuild, hookCtx, opts.Verbose); err != nil {
+		log.Errorf("%v", err)
+		return err
+	}
+
+	pushTo := opts.PushTo
+	if pushTo == "" {
+		pushTo = cfg.Apply.PushTo
+	}
+	if pushTo != "" && !opts.Dry {
+		log.Step("Pushing closure...")
+
+		// Results from build hosts are copied straight to
+		// the target host, so push from there instead.
+		var pushHost system.CommandRunner = s
+		if buildHost != nil && remoteTargetHost != nil {
+			pushHost = remoteTargetHost
+		}
+
+		err := pushClosure(log, pushHost, resultLocation, &closure.PushOptions{
+			To:         pushTo,
+			SigningKey: cfg.Apply.PushSigningKey,
+			Verbose:    opts.Verbose,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	if buildType.IsVM() && !dryBuild {
 		matches, err := filepath.Glob(fmt.Sprintf("%v/bin/run-*-vm", resultLocation))
 		if err != nil || len(matches) == 0 {
@@ -327,9 +642,20 @@ func applyMain(cmd *cobra.Command, opts *cmdOpts.ApplyOpts) error {
 		return nil
 	}
 
+	// Results from build hosts are copied straight to the target host,
+	// so this only needs to happen for local builds.
+	if remoteTargetHost != nil && buildHost == nil {
+		log.Step("Copying closure to target host...")
+
+		if err := remoteTargetHost.CopyClosureTo(s, resultLocation, opts.Verbose); err != nil {
+			log.Errorf("failed to copy closure to %v: %v", opts.TargetHost, err)
+			return err
+		}
+	}
+
 	log.Step("Comparing changes...")
 
-	err = generation.RunDiffCommand(log, s, constants.CurrentSystem, resultLocation, &generation.DiffCommandOptions{
+	err = generation.RunDiffCommand(log, targetHost, constants.CurrentSystem, resultLocation, &generation.DiffCommandOptions{
 		UseNvd:  cfg.UseNvd,
 		Verbose: opts.Verbose,
 	})
@@ -337,6 +663,8 @@ func applyMain(cmd *cobra.Command, opts *cmdOpts.ApplyOpts) error {
 		log.Errorf("failed to run diff command: %v", err)
 	}
 
+	closureSize := compareClosureSize(log, targetHost, resultLocation, cfg.Apply.SizeGrowthWarning, opts.Verbose)
+
 	if !opts.AlwaysConfirm {
 		log.Printf("\n")
 		confirm, err := cmdUtils.ConfirmationInput("Activate this configuration?")
@@ -353,7 +681,7 @@ func applyMain(cmd *cobra.Command, opts *cmdOpts.ApplyOpts) error {
 
 	specialisation := opts.Specialisation
 	if specialisation == "" {
-		defaultSpecialisation, err := activation.FindDefaultSpecialisationFromConfig(resultLocation)
+		defaultSpecialisation, err := activation.FindDefaultSpecialisationFromConfig(targetHost, resultLocation)
 		if err != nil {
 			log.Warnf("unable to find default specialisation from config: %v", err)
 		} else {
@@ -361,27 +689,56 @@ func applyMain(cmd *cobra.Command, opts *cmdOpts.ApplyOpts) error {
 		}
 	}
 
-	if !activation.VerifySpecialisationExists(resultLocation, specialisation) {
+	if !activation.VerifySpecialisationExists(targetHost, resultLocation, specialisation) {
 		log.Warnf("specialisation '%v' does not exist", specialisation)
 		log.Warn("using base configuration without specialisations")
 		specialisation = ""
 	}
 
-	previousGenNumber, err := activation.GetCurrentGenerationNumber(opts.ProfileName)
+	previousGenNumber, err := activation.GetCurrentGenerationNumber(targetHost, opts.ProfileName)
 	if err != nil {
 		log.Errorf("%v", err)
 		return err
 	}
+	hookCtx.OldGeneration = previousGenNumber
+	historyRecord.PreviousGeneration = previousGenNumber
+
+	if err := hooks.Run(s, hookSettings, hooks.StagePreActivate, hookCtx, opts.Verbose); err != nil {
+		log.Errorf("%v", err)
+		return err
+	}
 
 	if !opts.Dry {
 		if opts.Verbose {
 			log.Step("Setting system profile...")
 		}
 
-		if err := activation.AddNewNixProfile(s, opts.ProfileName, resultLocation, opts.Verbose); err != nil {
+		if err := activation.AddNewNixProfile(targetHost, opts.ProfileName, resultLocation, opts.Verbose); err != nil {
 			log.Errorf("failed to set system profile: %v", err)
 			return err
 		}
+
+		if newGenNumber, err := activation.GetCurrentGenerationNumber(targetHost, opts.ProfileName); err == nil {
+			hookCtx.NewGeneration = newGenNumber
+
+			if gitProvenance != nil {
+				if err := generation.WriteGitProvenance(targetHost, opts.ProfileName, newGenNumber, resultLocation, gitProvenance); err != nil {
+					log.Warnf("failed to record git provenance: %v", err)
+				}
+			}
+
+			if flakeLock != nil {
+				if err := generation.WriteFlakeLock(targetHost, opts.ProfileName, newGenNumber, resultLocation, flakeLock); err != nil {
+					log.Warnf("failed to record flake lock file: %v", err)
+				}
+			}
+
+			if closureSize != nil {
+				if err := generation.WriteClosureSize(targetHost, opts.ProfileName, newGenNumber, closureSize); err != nil {
+					log.Warnf("failed to record closure size: %v", err)
+				}
+			}
+		}
 	}
 
 	// In case switch-to-configuration fails, rollback the profile.
@@ -402,14 +759,22 @@ func applyMain(cmd *cobra.Command, opts *cmdOpts.ApplyOpts) error {
 				return
 			}
 
-			log.Step("Rolling back system profile...")
-			if err := activation.SetNixProfileGeneration(s, "system", previousGenNumber, opts.Verbose); err != nil {
-				log.Errorf("failed to rollback system profile: %v", err)
-				log.Info("make sure to rollback the system manually before deleting anything!")
-			}
+			// The running system may be partially activated, so only
+			// the profile is rolled back here.
+			_ = rollbackActivation(log, targetHost, rollbackReasonActivationFailed, &rollbackOptions{
+				Profile:    opts.ProfileName,
+				Generation: previousGenNumber,
+				Verbose:    opts.Verbose,
+			})
 		}(&rollbackProfile)
 	}
 
+	var confirmTimeout time.Duration
+	if opts.ConfirmTimeout != "" && !opts.Dry {
+		// This is validated during argument parsing, so no need to check for errors.
+		confirmTimeout, _ = timeUtils.DurationFromTimeSpan(opts.ConfirmTimeout)
+	}
+
 	log.Step("Activating...")
 
 	var stcAction activation.SwitchToConfigurationAction
@@ -424,8 +789,41 @@ func applyMain(cmd *cobra.Command, opts *cmdOpts.ApplyOpts) error {
 	} else {
 		panic("unknown switch to configuration action to take, this is a bug")
 	}
+	historyRecord.Action = stcAction.String()
+
+	// The watchdog must be scheduled before activation, since activation
+	// itself may be what cuts off the connection to the target host.
+	var confirmDeadline time.Time
+	if confirmTimeout > 0 {
+		if opts.Verbose {
+			log.Infof("scheduling rollback to generation %v in %v", previousGenNumber, confirmTimeout)
+		}
 
-	err = activation.SwitchToConfiguration(s, resultLocation, stcAction, &activation.SwitchToConfigurationOptions{
+		err := activation.ScheduleRollbackWatchdog(targetHost, &activation.RollbackWatchdogOptions{
+			Profile:        opts.ProfileName,
+			Generation:     previousGenNumber,
+			Action:         stcAction,
+			Specialisation: rollbackSpecialisation(targetHost, opts.ProfileName, previousGenNumber),
+			Timeout:        confirmTimeout,
+			Verbose:        opts.Verbose,
+		})
+		if err != nil {
+			rollbackProfile = true
+			log.Errorf("failed to schedule automatic rollback: %v", err)
+			return err
+		}
+
+		confirmDeadline = time.Now().Add(confirmTimeout)
+	}
+
+	log.Event("activation", map[string]any{
+		"action":         stcAction.String(),
+		"path":           resultLocation,
+		"specialisation": specialisation,
+		"generation":     hookCtx.NewGeneration,
+	})
+
+	err = activation.SwitchToConfiguration(targetHost, resultLocation, stcAction, &activation.SwitchToConfigurationOptions{
 		InstallBootloader: opts.InstallBootloader,
 		Verbose:           opts.Verbose,
 		Specialisation:    specialisation,
@@ -433,9 +831,75 @@ func applyMain(cmd *cobra.Command, opts *cmdOpts.ApplyOpts) error {
 	if err != nil {
 		rollbackProfile = true
 		log.Errorf("failed to switch to configuration: %v", err)
+
+		// Failed activations are handled by the regular profile
+		// rollback, so the watchdog is no longer needed.
+		if confirmTimeout > 0 {
+			if err := activation.CancelRollbackWatchdog(targetHost, opts.Verbose); err != nil {
+				log.Warnf("failed to cancel scheduled rollback: %v", err)
+			}
+		}
+
 		return err
 	}
 
+	// Boot activations do not change the running system, so
+	// there is nothing to check until the next reboot.
+	runsNewConfiguration := stcAction == activation.SwitchToConfigurationActionSwitch || stcAction == activation.SwitchToConfigurationActionTest
+	if cfg.HealthChecks.IsConfigured() && runsNewConfiguration {
+		err := runHealthChecks(log, cfg, targetHost, &healthCheckOptions{
+			Profile:            opts.ProfileName,
+			StorePath:          resultLocation,
+			PreviousGeneration: previousGenNumber,
+			Action:             stcAction,
+			HasWatchdog:        confirmTimeout > 0,
+			Verbose:            opts.Verbose,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if confirmTimeout > 0 {
+		err := handleActivationConfirmation(log, targetHost, confirmDeadline, opts.AlwaysConfirm, &rollbackOptions{
+			Profile:     opts.ProfileName,
+			Generation:  previousGenNumber,
+			Reactivate:  true,
+			Action:      stcAction,
+			HasWatchdog: true,
+			Verbose:     opts.Verbose,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Test activations are not added to the boot menu, so
+	// rebooting would only bring back the previous generation.
+	var rebootReasons []activation.RebootReason
+	if !opts.Dry && stcAction != activation.SwitchToConfigurationActionTest {
+		rebootReasons = reportRebootRequired(log, targetHost, resultLocation, stcAction)
+	}
+
+	if err := hooks.Run(s, hookSettings, hooks.StagePostActivate, hookCtx, opts.Verbose); err != nil {
+		log.Warnf("%v", err)
+	}
+
+	if opts.RebootIfNeeded != "" {
+		if len(rebootReasons) == 0 {
+			log.Info("no reboot is required")
+			return nil
+		}
+
+		var rebootDelay time.Duration
+		if opts.RebootIfNeeded != rebootImmediately {
+			// This is validated during argument parsing, so no need to check for errors.
+			rebootDelay, _ = timeUtils.DurationFromTimeSpan(opts.RebootIfNeeded)
+		}
+
+		return rebootAfterActivation(log, targetHost, rebootDelay, opts.Verbose)
+	}
+
 	return nil
 }
 
@@ -477,37 +941,21 @@ func upgradeChannels(s system.CommandRunner, opts *upgradeChannelsOptions) error
 	return err
 }
 
-var dirtyGitTreeError = fmt.Errorf("git tree is dirty")
-
-func getLatestGitCommitMessage(pathToRepo string, ignoreDirty bool) (string, error) {
-	repo, err := git.PlainOpen(pathToRepo)
-	if err != nil {
-		return "", err
-	}
-
-	wt, err := repo.Worktree()
-	if err != nil {
-		return "", err
-	}
-
-	status, err := wt.Status()
-	if err != nil {
-		return "", err
-	}
-
-	if !status.IsClean() && !ignoreDirty {
-		return "", dirtyGitTreeError
-	}
-
-	head, err := repo.Head()
-	if err != nil {
-		return "", err
-	}
+// Check if an apply run changes the system profile. Confirming an
+// activation does not, since it only stops the rollback watchdog.
+func applyModifiesProfile(opts *cmdOpts.ApplyOpts, buildType configuration.SystemBuildType) bool {
+	return !opts.Confirm && !opts.Dry && buildType == configuration.SystemBuildTypeSystemActivation
+}
 
-	commit, err := repo.CommitObject(head.Hash())
-	if err != nil {
-		return "", err
+// Acquire the operation lock under `root` if this run changes the system
+// profile, or return nil otherwise. An activation that is waiting to be
+// confirmed still holds the lock, so `--confirm` must never take it.
+func acquireApplyLock(log *logger.Logger, targetHost system.System, root string, opts *cmdOpts.ApplyOpts, buildType configuration.SystemBuildType) (*lock.Lock, error) {
+	// Operations on remote target hosts are not locked, since the
+	// lock would need to be held on the target host itself.
+	if !applyModifiesProfile(opts, buildType) || targetHost.IsRemote() {
+		return nil, nil
 	}
 
-	return commit.Message, nil
+	return cmdUtils.AcquireOperationLock(log, root, opts.WaitForLock)
 }
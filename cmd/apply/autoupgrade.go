@@ -0,0 +1,373 @@
+package apply
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/nix-community/nixos-cli/internal/activation"
+	"github.com/nix-community/nixos-cli/internal/autoupgrade"
+	"github.com/nix-community/nixos-cli/internal/build"
+	"github.com/nix-community/nixos-cli/internal/cmd/nixopts"
+	"github.com/nix-community/nixos-cli/internal/cmd/opts"
+	"github.com/nix-community/nixos-cli/internal/cmd/utils"
+	"github.com/nix-community/nixos-cli/internal/generation"
+	"github.com/nix-community/nixos-cli/internal/lock"
+	"github.com/nix-community/nixos-cli/internal/logger"
+	"github.com/nix-community/nixos-cli/internal/settings"
+	"github.com/nix-community/nixos-cli/internal/system"
+	timeUtils "github.com/nix-community/nixos-cli/internal/time"
+	"github.com/nix-community/nixos-cli/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+func AutoUpgradeCommand() *cobra.Command {
+	opts := cmdOpts.AutoUpgradeOpts{}
+
+	usage := "auto-upgrade"
+	if buildOpts.Flake == "true" {
+		usage += " [FLAKE-REF]"
+	}
+
+	cmd := cobra.Command{
+		Use:   usage,
+		Short: "Upgrade the system unattended",
+		Long:  "Update, build, and activate the system configuration without interaction, rebooting if allowed.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if buildOpts.Flake == "true" {
+				if err := cobra.MaximumNArgs(1)(cmd, args); err != nil {
+					return err
+				}
+				if len(args) > 0 {
+					opts.FlakeRef = args[0]
+				}
+			} else {
+				if err := cobra.NoArgs(cmd, args); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmdUtils.CommandErrorHandler(autoUpgradeMain(cmd, &opts))
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.DisplayJson, "json", false, "Display the status in JSON format")
+	cmd.Flags().BoolVar(&opts.NoDelay, "no-delay", false, "Ignore 'auto_upgrade.randomized_delay' and upgrade immediately")
+	cmd.Flags().BoolVar(&opts.Status, "status", false, "Show the result of the last upgrade and exit with its exit code")
+	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "Show verbose logging")
+	cmd.Flags().BoolVar(&opts.WaitForLock, "wait", false, "Wait for other operations in progress to finish")
+
+	nixopts.AddPrintBuildLogsNixOption(&cmd, &opts.NixOptions.PrintBuildLogs)
+	nixopts.AddShowTraceNixOption(&cmd, &opts.NixOptions.ShowTrace)
+	nixopts.AddKeepGoingNixOption(&cmd, &opts.NixOptions.KeepGoing)
+	nixopts.AddFallbackNixOption(&cmd, &opts.NixOptions.Fallback)
+	nixopts.AddRefreshNixOption(&cmd, &opts.NixOptions.Refresh)
+	nixopts.AddImpureNixOption(&cmd, &opts.NixOptions.Impure)
+	nixopts.AddMaxJobsNixOption(&cmd, &opts.NixOptions.MaxJobs)
+	nixopts.AddCoresNixOption(&cmd, &opts.NixOptions.Cores)
+	nixopts.AddBuildersNixOption(&cmd, &opts.NixOptions.Builders)
+	nixopts.AddOptionNixOption(&cmd, &opts.NixOptions.Options)
+	nixopts.AddIncludesNixOption(&cmd, &opts.NixOptions.Includes)
+
+	if buildOpts.Flake == "true" {
+		nixopts.AddCommitLockFileNixOption(&cmd, &opts.NixOptions.CommitLockFile)
+		nixopts.AddOverrideInputNixOption(&cmd, &opts.NixOptions.OverrideInputs)
+	}
+
+	cmd.MarkFlagsMutuallyExclusive("status", "no-delay")
+	cmd.MarkFlagsMutuallyExclusive("status", "wait")
+
+	helpTemplate := cmd.HelpTemplate()
+	if buildOpts.Flake == "true" {
+		helpTemplate += `
+Arguments:
+  [FLAKE-REF]  Flake ref to build configuration from (default: $NIXOS_CONFIG)
+`
+	}
+	helpTemplate += `
+Exit codes:
+  0  the upgrade succeeded
+  1  the upgrade failed
+  2  another operation was in progress, so nothing was done
+  3  the upgrade succeeded, but a reboot is needed and was not performed
+
+This command is configured using the 'auto_upgrade' settings section.
+Check the man page nixos-cli-auto-upgrade(1) for more details.
+`
+
+	cmdUtils.SetHelpFlagText(&cmd)
+	cmd.SetHelpTemplate(helpTemplate)
+
+	return &cmd
+}
+
+func autoUpgradeMain(cmd *cobra.Command, opts *cmdOpts.AutoUpgradeOpts) error {
+	log := logger.FromContext(cmd.Context())
+	cfg := settings.FromContext(cmd.Context())
+
+	if opts.Status {
+		return autoUpgradeStatus(log, opts.DisplayJson)
+	}
+
+	// Re-exec before waiting, so that the delay
+	// is not waited for twice.
+	if os.Geteuid() != 0 {
+		err := utils.ExecAsRoot(cfg.RootCommand)
+		if err != nil {
+			log.Errorf("failed to re-exec command as root: %v", err)
+			return err
+		}
+	}
+
+	s := system.NewLocalSystem(log)
+
+	if cfg.AutoUpgrade.RandomizedDelay != "" && !opts.NoDelay {
+		// This is validated when loading settings, so no need to check for errors.
+		maxDelay, _ := timeUtils.DurationFromTimeSpan(cfg.AutoUpgrade.RandomizedDelay)
+		if maxDelay > 0 {
+			delay := rand.N(maxDelay)
+			log.Infof("waiting %v before upgrading", delay.Round(time.Second))
+			time.Sleep(delay)
+		}
+	}
+
+	operation := cfg.AutoUpgrade.Operation
+	if operation == "" {
+		operation = "switch"
+	}
+
+	result := &autoupgrade.Result{
+		Time:      time.Now(),
+		Operation: operation,
+	}
+
+	if previousGenNumber, err := activation.GetCurrentGenerationNumber(s, "system"); err == nil {
+		result.PreviousGeneration = previousGenNumber
+	}
+
+	applyOpts := cmdOpts.ApplyOpts{
+		FlakeRef:      opts.FlakeRef,
+		ProfileName:   "system",
+		NoActivate:    operation == "boot",
+		AlwaysConfirm: true,
+		Verbose:       opts.Verbose,
+		WaitForLock:   opts.WaitForLock,
+		NixOptions:    opts.NixOptions,
+	}
+
+	if buildOpts.Flake == "true" {
+		if len(cfg.AutoUpgrade.UpdateInputs) > 0 {
+			applyOpts.UpdateFlakeInputs = cfg.AutoUpgrade.UpdateInputs
+		} else if cfg.AutoUpgrade.Update {
+			applyOpts.UpdateFlakeInputs = []string{updateAllInputs}
+		}
+	} else {
+		applyOpts.UpgradeChannels = cfg.AutoUpgrade.Update
+	}
+
+	err := applyMain(cmd, &applyOpts)
+
+	if newGenNumber, genErr := activation.GetCurrentGenerationNumber(s, "system"); genErr == nil {
+		result.NewGeneration = newGenNumber
+	}
+
+	var heldErr *lock.HeldError
+	if errors.As(err, &heldErr) {
+		result.Status = autoupgrade.StatusSkipped
+		result.Error = err.Error()
+	} else if err != nil {
+		result.Status = autoupgrade.StatusFailure
+		result.Error = err.Error()
+	} else {
+		result.Status = autoupgrade.StatusSuccess
+
+		reasons, err := checkUpgradeRebootRequired(s, operation == "boot")
+		if err != nil {
+			log.Warnf("failed to check if a reboot is required: %v", err)
+		}
+
+		result.RebootRequired = len(reasons) > 0
+		result.RebootReasons = reasons
+
+		if result.RebootRequired {
+			log.Infof("a reboot is required: %v", strings.Join(reasons, ", "))
+			result.Rebooted = rebootAllowed(log, &cfg.AutoUpgrade, opts.Verbose)
+		}
+	}
+
+	result.Duration = time.Since(result.Time).Milliseconds()
+
+	// The result is written before rebooting, so that it
+	// is recorded even if the reboot cuts this process off.
+	if writeErr := autoupgrade.Write(result); writeErr != nil {
+		log.Warnf("failed to record upgrade result: %v", writeErr)
+	}
+
+	if result.Rebooted {
+		log.Step("Rebooting...")
+
+		if err := activation.Reboot(s, 0, opts.Verbose); err != nil {
+			log.Errorf("failed to reboot: %v", err)
+
+			result.Rebooted = false
+			result.Error = fmt.Sprintf("failed to reboot: %v", err)
+			if writeErr := autoupgrade.Write(result); writeErr != nil {
+				log.Warnf("failed to record upgrade result: %v", writeErr)
+			}
+
+			return exitCodeForResult(result, err)
+		}
+	}
+
+	return exitCodeForResult(result, err)
+}
+
+func checkUpgradeRebootRequired(s system.System, bootOnly bool) ([]string, error) {
+	systemPath, err := filepath.EvalSymlinks(generation.GetProfileDirectoryFromName("system"))
+	if err != nil {
+		return nil, err
+	}
+
+	reasons, err := activation.CheckRebootRequired(s, systemPath, bootOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(reasons))
+	for _, reason := range reasons {
+		result = append(result, reason.String())
+	}
+
+	return result, nil
+}
+
+// Check if the system is allowed to be rebooted right now.
+func rebootAllowed(log *logger.Logger, cfg *settings.AutoUpgradeSettings, verbose bool) bool {
+	if !cfg.AllowReboot {
+		log.Info("rebooting is disabled, reboot manually to finish the upgrade")
+		return false
+	}
+
+	if len(cfg.RebootWindows) == 0 {
+		return true
+	}
+
+	windows := make([]timeUtils.DailyWindow, 0, len(cfg.RebootWindows))
+	for _, w := range cfg.RebootWindows {
+		window, err := timeUtils.ParseDailyWindow(w)
+		if err != nil {
+			log.Warnf("invalid reboot window '%v': %v", w, err)
+			log.Warn("not rebooting, since it is unknown when rebooting is allowed")
+			return false
+		}
+		windows = append(windows, window)
+	}
+
+	now := time.Now()
+	for _, window := range windows {
+		if window.Contains(now) {
+			if verbose {
+				log.Infof("current time is within reboot window %v", window)
+			}
+			return true
+		}
+	}
+
+	log.Infof("current time is outside of all reboot windows (%v), not rebooting", strings.Join(cfg.RebootWindows, ", "))
+	return false
+}
+
+func exitCodeForResult(result *autoupgrade.Result, err error) error {
+	code := result.ExitCode()
+	if code == autoupgrade.ExitSuccess {
+		return nil
+	}
+
+	if err == nil {
+		err = fmt.Errorf("a reboot is required to finish the upgrade")
+	}
+
+	return &cmdUtils.ExitCodeError{Code: code, Err: err}
+}
+
+func autoUpgradeStatus(log *logger.Logger, displayJson bool) error {
+	result, err := autoupgrade.Read()
+	if err != nil {
+		log.Errorf("failed to read result of last upgrade: %v", err)
+		return err
+	}
+
+	if result == nil {
+		if displayJson {
+			fmt.Println("null")
+			return nil
+		}
+		msg := "no automatic upgrade has been run yet"
+		log.Error(msg)
+		return fmt.Errorf("%v", msg)
+	}
+
+	if displayJson {
+		bytes, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Printf("%v\n", string(bytes))
+	} else {
+		printAutoUpgradeResult(result)
+	}
+
+	return exitCodeForResult(result, nil)
+}
+
+func printAutoUpgradeResult(r *autoupgrade.Result) {
+	printKey := func(key string) {
+		fmt.Printf("%v :: ", color.CyanString("%-10v", key))
+	}
+
+	printKey("Time")
+	fmt.Println(r.Time.Local().Format(time.DateTime))
+
+	printKey("Status")
+	switch r.Status {
+	case autoupgrade.StatusSuccess:
+		fmt.Println(color.GreenString("%v", r.Status))
+	case autoupgrade.StatusSkipped:
+		fmt.Println(color.YellowString("%v", r.Status))
+	default:
+		fmt.Println(color.RedString("%v", r.Status))
+	}
+
+	printKey("Operation")
+	fmt.Println(r.Operation)
+
+	printKey("Generation")
+	if r.NewGeneration != 0 && r.NewGeneration != r.PreviousGeneration {
+		fmt.Printf("%v -> %v\n", r.PreviousGeneration, r.NewGeneration)
+	} else {
+		fmt.Printf("%v (unchanged)\n", r.PreviousGeneration)
+	}
+
+	printKey("Reboot")
+	switch {
+	case !r.RebootRequired:
+		fmt.Println("not required")
+	case r.Rebooted:
+		fmt.Printf("rebooted (%v)\n", strings.Join(r.RebootReasons, ", "))
+	default:
+		fmt.Println(color.YellowString("pending (%v)", strings.Join(r.RebootReasons, ", ")))
+	}
+
+	printKey("Duration")
+	fmt.Println((time.Duration(r.Duration) * time.Millisecond).Round(time.Second))
+
+	if r.Error != "" {
+		printKey("Error")
+		fmt.Println(r.Error)
+	}
+}
@@ -0,0 +1,126 @@
+package apply
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nix-community/nixos-cli/internal/activation"
+	"github.com/nix-community/nixos-cli/internal/cmd/utils"
+	"github.com/nix-community/nixos-cli/internal/logger"
+	"github.com/nix-community/nixos-cli/internal/system"
+	"golang.org/x/term"
+)
+
+type confirmationResult int
+
+const (
+	confirmationResultAccepted confirmationResult = iota
+	confirmationResultRejected
+	confirmationResultExternal
+	confirmationResultTimedOut
+)
+
+// Wait for the user to confirm that the newly activated configuration
+// works. The rollback watchdog can also be cancelled from another
+// session using `nixos apply --confirm`, which is detected by polling
+// the watchdog's status.
+func waitForActivationConfirmation(s system.System, deadline time.Time) confirmationResult {
+	inputCh := make(chan bool, 1)
+
+	// Stop waiting for input once a result has been decided
+	// some other way, so that stdin is left alone afterwards.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		confirm, err := cmdUtils.ConfirmationInputContext(ctx, fmt.Sprintf("Keep this configuration? It will be rolled back at %v otherwise.", deadline.Format(time.TimeOnly)))
+		if err != nil {
+			confirm = false
+		}
+		inputCh <- confirm
+	}()
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	for {
+		select {
+		case confirm := <-inputCh:
+			if confirm {
+				return confirmationResultAccepted
+			}
+			return confirmationResultRejected
+		case <-ticker.C:
+			if !activation.IsRollbackWatchdogActive(s) {
+				if time.Now().Before(deadline) {
+					return confirmationResultExternal
+				}
+				return confirmationResultTimedOut
+			}
+		case <-timer.C:
+			return confirmationResultTimedOut
+		}
+	}
+}
+
+func confirmActivation(log *logger.Logger, s system.System, verbose bool) error {
+	if !activation.IsRollbackWatchdogActive(s) {
+		msg := "there is no activation awaiting confirmation"
+		log.Error(msg)
+		return fmt.Errorf("%v", msg)
+	}
+
+	if err := activation.CancelRollbackWatchdog(s, verbose); err != nil {
+		log.Errorf("failed to cancel rollback: %v", err)
+		return err
+	}
+
+	log.Print("Activation confirmed, the current configuration will be kept.")
+
+	return nil
+}
+
+func handleActivationConfirmation(log *logger.Logger, s system.System, deadline time.Time, alwaysConfirm bool, rollback *rollbackOptions) error {
+	if alwaysConfirm || !term.IsTerminal(int(os.Stdin.Fd())) {
+		log.Infof("run `nixos apply --confirm` before %v to keep this configuration", deadline.Format(time.TimeOnly))
+		log.Info("otherwise, the previous generation will be activated automatically")
+		return nil
+	}
+
+	log.Print()
+
+	switch waitForActivationConfirmation(s, deadline) {
+	case confirmationResultAccepted:
+		if err := activation.CancelRollbackWatchdog(s, rollback.Verbose); err != nil {
+			log.Errorf("failed to cancel rollback: %v", err)
+			return err
+		}
+		log.Print("Activation confirmed, the current configuration will be kept.")
+	case confirmationResultExternal:
+		log.Print()
+		log.Info("activation was confirmed from another session")
+	case confirmationResultRejected:
+		if err := rollbackActivation(log, s, rollbackReasonActivationRejected, rollback); err != nil {
+			return err
+		}
+
+		msg := "activation was rejected, the previous generation has been restored"
+		log.Warn(msg)
+		return fmt.Errorf("%v", msg)
+	case confirmationResultTimedOut:
+		log.Print()
+		log.Event("rollback", map[string]any{
+			"reason": "confirmation_timed_out",
+		})
+		msg := "no confirmation was received in time, the previous generation is being restored"
+		log.Warn(msg)
+		return fmt.Errorf("%v", msg)
+	}
+
+	return nil
+}
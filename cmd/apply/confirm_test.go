@@ -0,0 +1,71 @@
+package apply
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nix-community/nixos-cli/internal/cmd/opts"
+	"github.com/nix-community/nixos-cli/internal/cmd/utils"
+	"github.com/nix-community/nixos-cli/internal/configuration"
+	"github.com/nix-community/nixos-cli/internal/logger"
+	"github.com/nix-community/nixos-cli/internal/system"
+)
+
+// A local system where the rollback watchdog is always active,
+// and every command succeeds without running anything.
+type watchdogSystem struct {
+	log      *logger.Logger
+	commands []string
+}
+
+func (s *watchdogSystem) Run(cmd *system.Command) (int, error) {
+	s.commands = append(s.commands, strings.Join(append([]string{cmd.Name}, cmd.Args...), " "))
+	return 0, nil
+}
+
+func (s *watchdogSystem) Logger() *logger.Logger { return s.log }
+func (s *watchdogSystem) IsNixOS() bool          { return true }
+func (s *watchdogSystem) IsRemote() bool         { return false }
+
+func TestConfirmWhileLockIsHeld(t *testing.T) {
+	log := logger.NewLogger()
+	s := &watchdogSystem{log: log}
+	root := t.TempDir()
+
+	// The activation that is awaiting confirmation holds the lock.
+	pending, err := cmdUtils.AcquireOperationLock(log, root, false)
+	if err != nil {
+		t.Fatalf("failed to acquire lock: %v", err)
+	}
+	defer cmdUtils.ReleaseOperationLock(log, pending)
+
+	opts := &cmdOpts.ApplyOpts{Confirm: true}
+
+	l, err := acquireApplyLock(log, s, root, opts, configuration.SystemBuildTypeSystemActivation)
+	if err != nil {
+		t.Fatalf("expected --confirm to not take the lock, got %v", err)
+	}
+	if l != nil {
+		t.Fatalf("expected --confirm to not take the lock")
+	}
+
+	if err := confirmActivation(log, s, false); err != nil {
+		t.Fatalf("failed to confirm activation: %v", err)
+	}
+
+	stopped := false
+	for _, command := range s.commands {
+		if strings.HasPrefix(command, "systemctl stop") {
+			stopped = true
+		}
+	}
+	if !stopped {
+		t.Errorf("expected the rollback watchdog to be stopped, ran %v", s.commands)
+	}
+
+	// Other activations still have to wait for the pending one.
+	opts = &cmdOpts.ApplyOpts{}
+	if _, err := acquireApplyLock(log, s, root, opts, configuration.SystemBuildTypeSystemActivation); err == nil {
+		t.Errorf("expected activation to fail while the lock is held")
+	}
+}
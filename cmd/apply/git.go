@@ -0,0 +1,70 @@
+package apply
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nix-community/nixos-cli/internal/git"
+	"github.com/nix-community/nixos-cli/internal/logger"
+	"github.com/nix-community/nixos-cli/internal/settings"
+)
+
+// Collect the git provenance of the configuration directory, if it
+// is inside a git repository. Failing to do so is not fatal, since
+// the configuration can still be built without it, but the error is
+// returned so that `apply.git_policy` can still be enforced.
+func collectGitProvenance(log *logger.Logger, configDirname string) (*git.Provenance, error) {
+	provenance, err := git.CollectProvenance(configDirname)
+	if err != nil {
+		if errors.Is(err, git.ErrNotRepository) {
+			return nil, nil
+		}
+		log.Warnf("failed to collect git provenance: %v", err)
+		return nil, err
+	}
+
+	return provenance, nil
+}
+
+// Refuse to continue if the state of the git repository does
+// not satisfy the configured `apply.git_policy` setting.
+func checkGitPolicy(log *logger.Logger, policy string, provenance *git.Provenance, provenanceErr error) error {
+	if policy == "" || policy == settings.GitPolicyAllow {
+		return nil
+	}
+
+	// The state of the repository is unknown, so it cannot be
+	// assumed to satisfy the policy.
+	if provenanceErr != nil {
+		msg := fmt.Sprintf("unable to verify the state of the git repository: %v", provenanceErr)
+		log.Error(msg)
+		log.Errorf("refusing to apply due to apply.git_policy = %q", policy)
+		return fmt.Errorf("%v", msg)
+	}
+
+	if provenance == nil {
+		return nil
+	}
+
+	var msg string
+
+	if provenance.Dirty {
+		msg = "configuration has uncommitted changes"
+	} else if policy == settings.GitPolicyPushed {
+		if provenance.Branch == "" {
+			msg = "configuration is not on a branch, unable to verify that it has been pushed"
+		} else if provenance.Unpushed < 0 {
+			msg = fmt.Sprintf("branch %v has no upstream branch, unable to verify that it has been pushed", provenance.Branch)
+		} else if provenance.Unpushed > 0 {
+			msg = fmt.Sprintf("branch %v has %d commits that have not been pushed to %v", provenance.Branch, provenance.Unpushed, provenance.Upstream)
+		}
+	}
+
+	if msg == "" {
+		return nil
+	}
+
+	log.Error(msg)
+	log.Errorf("refusing to apply due to apply.git_policy = %q", policy)
+	return fmt.Errorf("%v", msg)
+}
@@ -0,0 +1,79 @@
+package apply
+
+import (
+	"fmt"
+
+	"github.com/nix-community/nixos-cli/internal/activation"
+	"github.com/nix-community/nixos-cli/internal/generation"
+	"github.com/nix-community/nixos-cli/internal/logger"
+	"github.com/nix-community/nixos-cli/internal/settings"
+	"github.com/nix-community/nixos-cli/internal/system"
+)
+
+type healthCheckOptions struct {
+	Profile            string
+	PreviousGeneration uint64
+	Action             activation.SwitchToConfigurationAction
+	// Store path of the new generation
+	StorePath string
+	// Whether or not a rollback watchdog was scheduled
+	// by --confirm-timeout for this activation.
+	HasWatchdog bool
+	Verbose     bool
+}
+
+// Run the configured health checks after activation, and record the result
+// for the new generation. If any checks fail, the previous generation
+// is restored, unless automatic rollback is disabled.
+func runHealthChecks(log *logger.Logger, cfg *settings.Settings, s system.System, opts *healthCheckOptions) error {
+	log.Step("Running health checks...")
+
+	result := activation.RunHealthChecks(s, &cfg.HealthChecks, opts.Verbose)
+
+	newGenNumber, err := activation.GetCurrentGenerationNumber(s, opts.Profile)
+	if err != nil {
+		log.Warnf("failed to record health check result: %v", err)
+	} else if err := generation.WriteHealthCheckResult(s, opts.Profile, newGenNumber, opts.StorePath, result); err != nil {
+		log.Warnf("failed to record health check result: %v", err)
+	}
+
+	log.Event("health_check", map[string]any{
+		"passed":   result.Passed,
+		"failures": result.Failures,
+	})
+
+	if result.Passed {
+		log.Print("All health checks passed.")
+		return nil
+	}
+
+	for _, failure := range result.Failures {
+		log.Errorf("health check failed: %v", failure)
+	}
+
+	msg := "health checks failed after activation"
+
+	if !cfg.AutoRollback {
+		log.Warnf("automatic rollback is disabled, the currently active configuration may have unresolved problems")
+		if opts.HasWatchdog {
+			// Leave it to the user to confirm or reject the activation.
+			return nil
+		}
+		log.Warnf("you are on your own!")
+		return fmt.Errorf("%v", msg)
+	}
+
+	err = rollbackActivation(log, s, rollbackReasonHealthCheckFailed, &rollbackOptions{
+		Profile:     opts.Profile,
+		Generation:  opts.PreviousGeneration,
+		Reactivate:  true,
+		Action:      opts.Action,
+		HasWatchdog: opts.HasWatchdog,
+		Verbose:     opts.Verbose,
+	})
+	if err != nil {
+		return err
+	}
+
+	return fmt.Errorf("%v", msg)
+}
@@ -0,0 +1,451 @@
+package apply
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/nix-community/nixos-cli/internal/closure"
+	"github.com/nix-community/nixos-cli/internal/cmd/opts"
+	"github.com/nix-community/nixos-cli/internal/configuration"
+	"github.com/nix-community/nixos-cli/internal/hosts"
+	"github.com/nix-community/nixos-cli/internal/logger"
+	"github.com/nix-community/nixos-cli/internal/settings"
+	"github.com/nix-community/nixos-cli/internal/system"
+	"github.com/nix-community/nixos-cli/internal/utils"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+// Default number of configurations to build at the same time.
+const defaultParallelBuilds = 4
+
+// How many lines of build output to show for each failed build.
+const failedBuildOutputLines = 15
+
+// Check if the given flake refs select more than one
+// configuration, and thus need to be built separately.
+func isMultiHostApply(flakeRefs []string) bool {
+	if len(flakeRefs) > 1 {
+		return true
+	}
+
+	for _, ref := range flakeRefs {
+		if hosts.IsPattern(configuration.FlakeRefFromString(ref).System) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type hostBuild struct {
+	Ref *configuration.FlakeRef
+
+	ResultLocation string
+	Output         bytes.Buffer
+	Err            error
+	PushErr        error
+	Duration       time.Duration
+
+	ClosureSize     uint64
+	PrevClosureSize uint64
+	HasPrevious     bool
+}
+
+func (h *hostBuild) Name() string {
+	return h.Ref.System
+}
+
+// Build several configurations from one or more flakes at once, and
+// activate the one matching the local hostname, if any, in the same
+// way as a regular apply.
+func multiHostApplyMain(cmd *cobra.Command, opts *cmdOpts.ApplyOpts) error {
+	log := logger.FromContext(cmd.Context())
+	cfg := settings.FromContext(cmd.Context())
+
+	s := system.NewLocalSystem(log)
+
+	builds, err := resolveHostBuilds(log, s, opts.FlakeRefs, opts.Verbose)
+	if err != nil {
+		return err
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Warnf("failed to determine hostname: %v", err)
+	}
+
+	var local *hostBuild
+	for _, b := range builds {
+		if b.Name() == hostname {
+			local = b
+			break
+		}
+	}
+	activatesLocal := local != nil && !(opts.NoActivate && opts.NoBoot)
+
+	// Building alone does not require root, but activating the local
+	// configuration does. This needs to happen before building, since
+	// activating would re-exec the whole command as root otherwise.
+	if activatesLocal && os.Geteuid() != 0 {
+		err := utils.ExecAsRoot(cfg.RootCommand)
+		if err != nil {
+			log.Errorf("failed to re-exec command as root: %v", err)
+			return err
+		}
+	}
+
+	previousBuilds, err := hosts.ReadBuildRecords()
+	if err != nil {
+		log.Warnf("failed to read previous build results: %v", err)
+		previousBuilds = map[string]hosts.BuildRecord{}
+	}
+
+	log.Step(fmt.Sprintf("Building %d configurations...", len(builds)))
+
+	runHostBuilds(log, cmd, opts, builds)
+
+	failed := 0
+	for _, b := range builds {
+		if b.Err != nil {
+			failed++
+			continue
+		}
+
+		if opts.Dry {
+			continue
+		}
+
+		if c, err := closure.Query(s, b.ResultLocation, opts.Verbose); err != nil {
+			log.Warnf("failed to query closure size of %v: %v", b.Name(), err)
+		} else {
+			b.ClosureSize = c.Size()
+		}
+
+		// Different flakes can have configurations with the same
+		// name, so builds are recorded by their full flake ref.
+		key := b.Ref.String()
+
+		if previous, ok := previousBuilds[key]; ok && previous.ClosureSize > 0 && b.ClosureSize > 0 {
+			b.PrevClosureSize = previous.ClosureSize
+			b.HasPrevious = true
+		}
+
+		previousBuilds[key] = hosts.BuildRecord{
+			Path:        b.ResultLocation,
+			ClosureSize: b.ClosureSize,
+			Time:        time.Now(),
+		}
+	}
+
+	// Only root can write to the state directory, and builds are
+	// not worth escalating for, so they are not recorded otherwise.
+	if !opts.Dry && os.Geteuid() == 0 {
+		if err := hosts.WriteBuildRecords(previousBuilds); err != nil {
+			log.Warnf("failed to record build results: %v", err)
+		}
+	}
+
+	pushTo := opts.PushTo
+	if pushTo == "" {
+		pushTo = cfg.Apply.PushTo
+	}
+	if pushTo != "" && !opts.Dry {
+		log.Step("Pushing closures...")
+
+		for _, b := range builds {
+			// The local configuration is pushed when it is applied.
+			if b.Err != nil || (activatesLocal && b == local) {
+				continue
+			}
+
+			log.Infof("pushing %v", b.Name())
+
+			err := pushClosure(log, s, b.ResultLocation, &closure.PushOptions{
+				To:         pushTo,
+				SigningKey: cfg.Apply.PushSigningKey,
+				Verbose:    opts.Verbose,
+			})
+			if err != nil {
+				b.PushErr = err
+				failed++
+			}
+		}
+	}
+
+	for _, b := range builds {
+		event := map[string]any{
+			"host":         b.Name(),
+			"flake":        b.Ref.URI,
+			"path":         b.ResultLocation,
+			"success":      b.Err == nil,
+			"closure_size": b.ClosureSize,
+			"duration_ms":  b.Duration.Milliseconds(),
+		}
+		if b.HasPrevious {
+			event["closure_size_change"] = int64(b.ClosureSize) - int64(b.PrevClosureSize)
+		}
+		if b.Err != nil {
+			event["error"] = b.Err.Error()
+		} else if b.PushErr != nil {
+			event["error"] = b.PushErr.Error()
+		}
+		log.Event("host_build", event)
+	}
+
+	// The summary is already given by the events above
+	// when using machine-readable output.
+	if log.OutputFormat() == logger.OutputFormatText {
+		log.Printf("\n")
+		printHostBuildSummary(builds, hostname)
+	}
+	printFailedHostBuilds(log, builds)
+
+	activationErr := activateLocalHost(log, cmd, opts, local, hostname)
+
+	if failed > 0 {
+		msg := fmt.Sprintf("%d of %d configurations failed", failed, len(builds))
+		log.Error(msg)
+		return fmt.Errorf("%v", msg)
+	}
+
+	return activationErr
+}
+
+// Expand all flake refs into the configurations they select. Refs
+// without a configuration name select the local hostname, as usual.
+func resolveHostBuilds(log *logger.Logger, s system.CommandRunner, flakeRefs []string, verbose bool) ([]*hostBuild, error) {
+	builds := []*hostBuild{}
+	seen := map[string]bool{}
+	systemsByURI := map[string][]string{}
+
+	for _, refStr := range flakeRefs {
+		ref := configuration.FlakeRefFromString(refStr)
+		ref.SetBuilder(s)
+
+		var names []string
+
+		if hosts.IsPattern(ref.System) {
+			systems, ok := systemsByURI[ref.URI]
+			if !ok {
+				var err error
+				systems, err = ref.ListSystems(verbose)
+				if err != nil {
+					log.Errorf("failed to list configurations in %v: %v", ref.URI, err)
+					if evalErr, ok := err.(*configuration.AttributeEvaluationError); ok {
+						log.Print(evalErr.EvaluationOutput)
+					}
+					return nil, err
+				}
+				systemsByURI[ref.URI] = systems
+			}
+
+			matches, err := hosts.Match(systems, ref.System)
+			if err != nil {
+				log.Errorf("invalid pattern '%v': %v", ref.System, err)
+				return nil, err
+			}
+			if len(matches) == 0 {
+				msg := fmt.Sprintf("no configurations in %v match '%v'", ref.URI, ref.System)
+				log.Error(msg)
+				return nil, fmt.Errorf("%v", msg)
+			}
+			names = matches
+		} else if err := ref.InferSystemFromHostnameIfNeeded(); err != nil {
+			log.Errorf("failed to infer hostname: %v", err)
+			return nil, err
+		} else {
+			names = []string{ref.System}
+		}
+
+		for _, name := range names {
+			hostRef := &configuration.FlakeRef{URI: ref.URI, System: name, Builder: s}
+
+			key := hostRef.String()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			builds = append(builds, &hostBuild{Ref: hostRef})
+		}
+	}
+
+	return builds, nil
+}
+
+// Build all configurations, with at most opts.Parallel builds running at
+// once. Build output is collected for each build, since output from
+// several builds at the same time would be unreadable.
+func runHostBuilds(log *logger.Logger, cmd *cobra.Command, opts *cmdOpts.ApplyOpts, builds []*hostBuild) {
+	parallel := opts.Parallel
+	if parallel <= 0 {
+		parallel = defaultParallelBuilds
+	}
+
+	semaphore := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+
+	for _, b := range builds {
+		wg.Add(1)
+
+		go func(b *hostBuild) {
+			defer wg.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			start := time.Now()
+
+			b.ResultLocation, b.Err = b.Ref.BuildSystem(configuration.SystemBuildTypeSystem, &configuration.SystemBuildOptions{
+				DryBuild: opts.Dry,
+				Verbose:  opts.Verbose,
+				Stderr:   &b.Output,
+
+				CmdFlags: cmd.Flags(),
+				NixOpts:  &opts.NixOptions,
+			})
+
+			b.Duration = time.Since(start)
+
+			if b.Err != nil {
+				log.Errorf("failed to build %v", b.Name())
+			} else {
+				log.Infof("built %v in %v", b.Name(), b.Duration.Round(time.Second))
+			}
+		}(b)
+	}
+
+	wg.Wait()
+}
+
+func printHostBuildSummary(builds []*hostBuild, hostname string) {
+	data := make([][]string, 0, len(builds))
+
+	for _, b := range builds {
+		host := b.Name()
+		if host == hostname {
+			host += " (local)"
+		}
+
+		result := color.GreenString("built")
+		if b.Err != nil {
+			result = color.RedString("failed")
+		} else if b.PushErr != nil {
+			result = color.RedString("push failed")
+		}
+
+		size := "-"
+		change := "-"
+		if b.ClosureSize > 0 {
+			size = utils.FormatBytes(b.ClosureSize)
+		}
+		if b.HasPrevious {
+			change = closure.FormatSizeDelta(int64(b.ClosureSize) - int64(b.PrevClosureSize))
+		}
+
+		errorMsg := ""
+		if b.Err != nil {
+			errorMsg = lastErrorLine(b.Output.String())
+			if errorMsg == "" {
+				errorMsg = b.Err.Error()
+			}
+		} else if b.PushErr != nil {
+			errorMsg = b.PushErr.Error()
+		}
+
+		data = append(data, []string{
+			host,
+			b.Ref.URI,
+			result,
+			size,
+			change,
+			b.Duration.Round(time.Second).String(),
+			errorMsg,
+		})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Host", "Flake", "Result", "Closure Size", "Change", "Duration", "Error"})
+	table.SetAutoWrapText(false)
+	table.SetAutoFormatHeaders(true)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetCenterSeparator("")
+	table.SetColumnSeparator("")
+	table.SetRowSeparator("")
+	table.SetHeaderLine(false)
+	table.SetBorder(false)
+	table.SetTablePadding("\t")
+	table.SetNoWhiteSpace(true)
+	table.AppendBulk(data)
+	table.Render()
+}
+
+func printFailedHostBuilds(log *logger.Logger, builds []*hostBuild) {
+	for _, b := range builds {
+		if b.Err == nil {
+			continue
+		}
+
+		output := strings.TrimSpace(b.Output.String())
+		if output == "" {
+			continue
+		}
+
+		lines := strings.Split(output, "\n")
+		if len(lines) > failedBuildOutputLines {
+			lines = lines[len(lines)-failedBuildOutputLines:]
+		}
+
+		log.Printf("\n")
+		log.Errorf("build output for %v:", b.Name())
+		log.Print(strings.Join(lines, "\n"))
+	}
+}
+
+// Find the last line of build output that starts with "error:",
+// which is usually the most relevant part of a failed build.
+func lastErrorLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(line, "error:") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "error:"))
+		}
+	}
+	return ""
+}
+
+// Activate the configuration for this machine through the regular apply
+// path, so that it is handled exactly like a single-host apply. It has
+// already been built, so this only needs to evaluate it again.
+func activateLocalHost(log *logger.Logger, cmd *cobra.Command, opts *cmdOpts.ApplyOpts, local *hostBuild, hostname string) error {
+	if opts.NoActivate && opts.NoBoot {
+		return nil
+	}
+
+	if local == nil {
+		log.Infof("no configuration matches the local hostname %v, skipping activation", hostname)
+		return nil
+	}
+
+	if local.Err != nil {
+		log.Warnf("configuration for the local hostname %v failed to build, skipping activation", hostname)
+		return nil
+	}
+
+	log.Printf("\n")
+	log.Infof("activating configuration for the local hostname %v", hostname)
+
+	localOpts := *opts
+	localOpts.FlakeRef = local.Ref.String()
+	localOpts.FlakeRefs = nil
+
+	return applyMain(cmd, &localOpts)
+}
@@ -0,0 +1,48 @@
+package apply
+
+import (
+	"github.com/nix-community/nixos-cli/internal/closure"
+	"github.com/nix-community/nixos-cli/internal/logger"
+	"github.com/nix-community/nixos-cli/internal/system"
+	"github.com/nix-community/nixos-cli/internal/utils"
+)
+
+// Copy a built closure to a binary cache, and report
+// which paths had to be uploaded.
+func pushClosure(log *logger.Logger, s system.CommandRunner, resultLocation string, opts *closure.PushOptions) error {
+	result, err := closure.Push(s, resultLocation, opts)
+	if err != nil {
+		log.Errorf("failed to push closure: %v", err)
+		return err
+	}
+
+	log.Event("push", map[string]any{
+		"to":            opts.To,
+		"path":          resultLocation,
+		"signed":        opts.SigningKey != "",
+		"uploaded":      result.Uploaded,
+		"uploaded_size": result.UploadedSize,
+		"present":       len(result.Present),
+	})
+
+	if result.PresenceUnknown {
+		log.Infof("copied closure to %v", opts.To)
+		return nil
+	}
+
+	if len(result.Uploaded) == 0 {
+		log.Infof("nothing to upload, all paths are already present in %v", opts.To)
+		return nil
+	}
+
+	log.Infof("copied closure to %v: uploaded: %d (%v), already present: %d",
+		opts.To, len(result.Uploaded), utils.FormatBytes(result.UploadedSize), len(result.Present))
+
+	if opts.Verbose {
+		for _, path := range result.Uploaded {
+			log.Infof("  %v", path)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package apply
+
+import (
+	"time"
+
+	"github.com/nix-community/nixos-cli/internal/activation"
+	"github.com/nix-community/nixos-cli/internal/logger"
+	"github.com/nix-community/nixos-cli/internal/system"
+)
+
+// Value of --reboot-if-needed when no delay is given.
+const rebootImmediately = "now"
+
+// Check if the newly activated configuration requires a reboot to
+// take full effect, and report why. Failing to check is not fatal,
+// since activation itself has already succeeded at this point.
+func reportRebootRequired(log *logger.Logger, s system.System, resultLocation string, action activation.SwitchToConfigurationAction) []activation.RebootReason {
+	bootOnly := action == activation.SwitchToConfigurationActionBoot
+
+	reasons, err := activation.CheckRebootRequired(s, resultLocation, bootOnly)
+	if err != nil {
+		log.Warnf("failed to check if a reboot is required: %v", err)
+		return nil
+	}
+
+	log.Event("reboot_check", map[string]any{
+		"required": len(reasons) > 0,
+		"reasons":  reasons,
+	})
+
+	if len(reasons) > 0 {
+		log.Warn("a reboot is required for all changes to take effect:")
+		for _, reason := range reasons {
+			log.Warnf("  %v", reason)
+		}
+	}
+
+	return reasons
+}
+
+func rebootAfterActivation(log *logger.Logger, s system.System, delay time.Duration, verbose bool) error {
+	// shutdown(8) only schedules reboots with minute precision.
+	if rounded := delay.Truncate(time.Minute); rounded < delay {
+		delay = rounded + time.Minute
+	}
+
+	if delay > 0 {
+		log.Step("Scheduling reboot...")
+	} else {
+		log.Step("Rebooting...")
+	}
+
+	if err := activation.Reboot(s, delay, verbose); err != nil {
+		log.Errorf("failed to reboot: %v", err)
+		return err
+	}
+
+	if delay > 0 {
+		log.Infof("the system will reboot in %v, run `shutdown -c` to cancel", delay)
+	}
+
+	return nil
+}
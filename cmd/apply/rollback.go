@@ -0,0 +1,93 @@
+package apply
+
+import (
+	"fmt"
+
+	"github.com/nix-community/nixos-cli/internal/activation"
+	"github.com/nix-community/nixos-cli/internal/generation"
+	"github.com/nix-community/nixos-cli/internal/logger"
+	"github.com/nix-community/nixos-cli/internal/system"
+)
+
+// Reasons for rolling back, as reported in `rollback` events.
+const (
+	rollbackReasonActivationFailed   = "activation_failed"
+	rollbackReasonHealthCheckFailed  = "health_check_failed"
+	rollbackReasonActivationRejected = "activation_rejected"
+)
+
+type rollbackOptions struct {
+	Profile string
+	// The generation to roll back to.
+	Generation uint64
+	// Whether the running system has been switched to the new generation,
+	// and needs to be switched back with `Action`. Otherwise, only the
+	// profile is rolled back.
+	Reactivate bool
+	Action     activation.SwitchToConfigurationAction
+	// Whether or not a rollback watchdog was scheduled by
+	// --confirm-timeout for this activation. If so, it is used
+	// to roll back, so that it cannot fire again later.
+	HasWatchdog bool
+	Verbose     bool
+}
+
+// Roll back to a previous generation after a failed or rejected activation.
+// All of `nixos apply` rolls back through here, so that every failure is
+// handled the same way. Deciding whether to roll back at all (such as
+// when automatic rollback is disabled) is left to the caller.
+func rollbackActivation(log *logger.Logger, s system.System, reason string, opts *rollbackOptions) error {
+	log.Step("Rolling back to previous generation...")
+	log.Event("rollback", map[string]any{
+		"generation": opts.Generation,
+		"reason":     reason,
+	})
+
+	// The watchdog already knows how to restore the previous generation.
+	if opts.HasWatchdog {
+		err := activation.TriggerRollbackWatchdog(s, opts.Verbose)
+		if err == nil {
+			return nil
+		}
+		log.Warnf("failed to roll back using the rollback watchdog: %v", err)
+		log.Info("rolling back directly instead")
+	}
+
+	err := activation.SetNixProfileGeneration(s, opts.Profile, opts.Generation, opts.Verbose)
+	if err == nil && opts.Reactivate {
+		err = switchToGeneration(s, opts.Profile, opts.Generation, opts.Action, opts.Verbose)
+	}
+
+	if err != nil {
+		log.Errorf("failed to roll back: %v", err)
+		log.Info("make sure to rollback the system manually before deleting anything!")
+		return err
+	}
+
+	return nil
+}
+
+// Activate an existing generation of a profile, using its default
+// specialisation if it has one.
+func switchToGeneration(s system.System, profile string, genNumber uint64, action activation.SwitchToConfigurationAction, verbose bool) error {
+	generationLink := fmt.Sprintf("%s-%d-link", generation.GetProfileDirectoryFromName(profile), genNumber)
+
+	return activation.SwitchToConfiguration(s, generationLink, action, &activation.SwitchToConfigurationOptions{
+		Verbose:        verbose,
+		Specialisation: rollbackSpecialisation(s, profile, genNumber),
+	})
+}
+
+// Find the default specialisation of a generation that is being rolled
+// back to. Both direct rollbacks and the rollback watchdog use this, so
+// that they activate the same configuration.
+func rollbackSpecialisation(s system.System, profile string, genNumber uint64) string {
+	generationLink := fmt.Sprintf("%s-%d-link", generation.GetProfileDirectoryFromName(profile), genNumber)
+
+	specialisation, err := activation.FindDefaultSpecialisationFromConfig(s, generationLink)
+	if err != nil || !activation.VerifySpecialisationExists(s, generationLink, specialisation) {
+		return ""
+	}
+
+	return specialisation
+}
@@ -0,0 +1,72 @@
+package apply
+
+import (
+	"github.com/nix-community/nixos-cli/internal/closure"
+	"github.com/nix-community/nixos-cli/internal/constants"
+	"github.com/nix-community/nixos-cli/internal/generation"
+	"github.com/nix-community/nixos-cli/internal/logger"
+	"github.com/nix-community/nixos-cli/internal/system"
+	"github.com/nix-community/nixos-cli/internal/utils"
+)
+
+// Show how the closure size of a built configuration compares to the
+// currently running system, and warn if it grows by more than
+// `threshold`. The size of the built configuration is returned so that
+// it can be recorded once it becomes a generation.
+func compareClosureSize(log *logger.Logger, s system.CommandRunner, resultLocation string, threshold string, verbose bool) *generation.ClosureSize {
+	after, err := closure.Query(s, resultLocation, verbose)
+	if err != nil {
+		log.Warnf("failed to query closure size: %v", err)
+		return nil
+	}
+
+	size := generation.ClosureSizeFromClosure(resultLocation, after)
+
+	before, err := closure.Query(s, constants.CurrentSystem, verbose)
+	if err != nil {
+		// There is nothing to compare to for the first generation.
+		if verbose {
+			log.Infof("unable to query closure size of current system: %v", err)
+		}
+		log.Event("closure_size", map[string]any{
+			"path":  resultLocation,
+			"size":  size.NarSize,
+			"paths": size.PathCount,
+		})
+		log.Infof("closure size: %v, %v paths", utils.FormatBytes(size.NarSize), size.PathCount)
+		return size
+	}
+
+	beforeSize := before.Size()
+	delta := int64(size.NarSize) - int64(beforeSize)
+	pathDelta := size.PathCount - len(before.Paths)
+
+	log.Event("closure_size", map[string]any{
+		"path":             resultLocation,
+		"size":             size.NarSize,
+		"paths":            size.PathCount,
+		"previous_size":    beforeSize,
+		"previous_paths":   len(before.Paths),
+		"size_delta":       delta,
+		"path_count_delta": pathDelta,
+	})
+
+	log.Infof("closure size: %v (%v), %v paths (%+d)",
+		utils.FormatBytes(size.NarSize), closure.FormatSizeDelta(delta), size.PathCount, pathDelta)
+
+	if threshold == "" {
+		return size
+	}
+
+	t, err := closure.ParseGrowthThreshold(threshold)
+	if err != nil {
+		log.Warnf("invalid closure growth threshold: %v", err)
+		return size
+	}
+
+	if t.Exceeded(beforeSize, size.NarSize) {
+		log.Warnf("closure grows by %v, which is more than the threshold of %v", closure.FormatSizeDelta(delta), t)
+	}
+
+	return size
+}
@@ -0,0 +1,110 @@
+package apply
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/nix-community/nixos-cli/internal/configuration"
+	"github.com/nix-community/nixos-cli/internal/flake"
+	"github.com/nix-community/nixos-cli/internal/git"
+	"github.com/nix-community/nixos-cli/internal/logger"
+	"github.com/nix-community/nixos-cli/internal/system"
+)
+
+// Value of --update when no inputs are given, which
+// means that all inputs of the flake are updated.
+const updateAllInputs = "*"
+
+const lockFilename = "flake.lock"
+
+type updateInputsOptions struct {
+	Inputs  []string
+	Commit  bool
+	Verbose bool
+}
+
+// Update the inputs of the flake in the current directory, and report
+// which inputs changed. If requested, the updated lock file is then
+// committed to the git repository that contains it.
+func updateFlakeInputs(log *logger.Logger, s system.CommandRunner, opts *updateInputsOptions) error {
+	before, err := flake.ReadLockFile(lockFilename)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Errorf("failed to read %v: %v", lockFilename, err)
+		return err
+	}
+
+	var argv []string
+	if slices.Contains(opts.Inputs, updateAllInputs) {
+		argv = []string{"nix", "flake", "update"}
+	} else {
+		// `nix flake lock --update-input` works with all Nix
+		// versions, unlike the positional inputs that newer
+		// versions of `nix flake update` accept.
+		argv = []string{"nix", "flake", "lock"}
+		for _, input := range opts.Inputs {
+			argv = append(argv, "--update-input", input)
+		}
+	}
+
+	if opts.Verbose {
+		s.Logger().CmdArray(argv)
+	}
+
+	cmd := system.NewCommand(argv[0], argv[1:]...)
+	if _, err := s.Run(cmd); err != nil {
+		log.Errorf("failed to update flake inputs: %v", err)
+		return err
+	}
+
+	after, err := flake.ReadLockFile(lockFilename)
+	if err != nil {
+		log.Errorf("failed to read updated %v: %v", lockFilename, err)
+		return err
+	}
+
+	changes := flake.CompareLockFiles(before, after)
+	flake.CountInputCommits(changes)
+
+	log.Event("flake_update", map[string]any{
+		"inputs":  opts.Inputs,
+		"changes": changes,
+	})
+
+	if log.OutputFormat() == logger.OutputFormatText {
+		var report bytes.Buffer
+		_ = flake.WriteInputChangesText(&report, changes)
+		log.Print(strings.TrimRight(report.String(), "\n"))
+	}
+
+	if !opts.Commit || len(changes) == 0 {
+		return nil
+	}
+
+	hash, err := git.CommitFile(lockFilename, flake.LockFileCommitMessage(changes))
+	if err != nil {
+		if errors.Is(err, git.ErrOtherChangesStaged) {
+			log.Warnf("not committing %v, since other changes are already staged", lockFilename)
+		} else {
+			log.Warnf("failed to commit %v: %v", lockFilename, err)
+		}
+		return nil
+	}
+
+	log.Infof("committed updated %v as %v", lockFilename, hash[:12])
+
+	return nil
+}
+
+// Read the lock file of the flake that is being built. Flakes in the
+// configuration directory, which is the current working directory at
+// this point, are read directly, while other flakes are resolved by Nix.
+func readFlakeLock(s system.CommandRunner, flakeRef *configuration.FlakeRef, configIsDirectory bool) (*flake.LockFile, error) {
+	if configIsDirectory {
+		return flake.ReadLockFile(lockFilename)
+	}
+
+	return flake.LoadLockFile(s, flakeRef.URI)
+}
@@ -0,0 +1,133 @@
+package eval
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nix-community/nixos-cli/internal/build"
+	"github.com/nix-community/nixos-cli/internal/cmd/nixopts"
+	"github.com/nix-community/nixos-cli/internal/cmd/opts"
+	"github.com/nix-community/nixos-cli/internal/cmd/utils"
+	"github.com/nix-community/nixos-cli/internal/configuration"
+	"github.com/nix-community/nixos-cli/internal/logger"
+	"github.com/nix-community/nixos-cli/internal/settings"
+)
+
+func EvalCommand() *cobra.Command {
+	opts := cmdOpts.EvalOpts{}
+
+	cmd := cobra.Command{
+		Use:   "eval [flags] {ATTR}",
+		Short: "Evaluate an attribute of the system configuration",
+		Long:  "Evaluate an attribute path inside the system configuration's `config` and print its value.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.ExactArgs(1)(cmd, args); err != nil {
+				return err
+			}
+
+			opts.Attribute = args[0]
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmdUtils.CommandErrorHandler(evalMain(cmd, &opts))
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Apply, "apply", "", "Apply the Nix function `expr` to the value before printing it")
+	cmd.Flags().StringVar(&opts.Attr, "attr", "", "Evaluate the configuration at attribute `path` in --file")
+	cmd.Flags().StringVar(&opts.File, "file", "", "Evaluate the configuration from a Nix `file` or directory")
+	cmd.Flags().BoolVarP(&opts.DisplayJson, "json", "j", false, "Print the value in JSON format")
+	cmd.Flags().BoolVarP(&opts.Raw, "raw", "r", false, "Print the value, which must be a string, without quotes or escaping")
+
+	if buildOpts.Flake == "true" {
+		cmd.Flags().StringVarP(&opts.FlakeRef, "flake", "f", "", "Flake ref to evaluate the configuration from")
+	}
+
+	nixopts.AddQuietNixOption(&cmd, &opts.NixOptions.Quiet)
+	nixopts.AddShowTraceNixOption(&cmd, &opts.NixOptions.ShowTrace)
+	nixopts.AddRefreshNixOption(&cmd, &opts.NixOptions.Refresh)
+	nixopts.AddImpureNixOption(&cmd, &opts.NixOptions.Impure)
+	nixopts.AddOfflineNixOption(&cmd, &opts.NixOptions.Offline)
+	nixopts.AddNoNetNixOption(&cmd, &opts.NixOptions.NoNet)
+	nixopts.AddOptionNixOption(&cmd, &opts.NixOptions.Options)
+	nixopts.AddIncludesNixOption(&cmd, &opts.NixPathIncludes)
+
+	if buildOpts.Flake == "true" {
+		nixopts.AddNoUpdateLockFileNixOption(&cmd, &opts.NixOptions.NoUpdateLockFile)
+		nixopts.AddNoWriteLockFileNixOption(&cmd, &opts.NixOptions.NoWriteLockFile)
+		nixopts.AddNoUseRegistriesNixOption(&cmd, &opts.NixOptions.NoUseRegistries)
+		nixopts.AddOverrideInputNixOption(&cmd, &opts.NixOptions.OverrideInputs)
+	}
+
+	cmd.MarkFlagsMutuallyExclusive("json", "raw")
+	if buildOpts.Flake == "true" {
+		cmd.MarkFlagsMutuallyExclusive("flake", "file")
+		cmd.MarkFlagsMutuallyExclusive("flake", "attr")
+	}
+
+	cmdUtils.SetHelpFlagText(&cmd)
+	cmd.SetHelpTemplate(cmd.HelpTemplate() + `
+Arguments:
+  [ATTR]  Attribute path to evaluate, relative to 'config'
+`)
+
+	return &cmd
+}
+
+func evalMain(cmd *cobra.Command, opts *cmdOpts.EvalOpts) error {
+	log := logger.FromContext(cmd.Context())
+	cfg := settings.FromContext(cmd.Context())
+
+	var nixConfig configuration.Configuration
+	if opts.FlakeRef != "" {
+		f := configuration.FlakeRefFromString(opts.FlakeRef)
+		if err := f.InferSystemFromHostnameIfNeeded(); err != nil {
+			log.Errorf("failed to infer hostname: %v", err)
+			return err
+		}
+		nixConfig = f
+	} else if opts.File != "" || opts.Attr != "" {
+		c, err := configuration.FileConfigurationFromPath(opts.File, opts.Attr, opts.NixPathIncludes)
+		if err != nil {
+			log.Errorf("failed to find configuration: %v", err)
+			return err
+		}
+		nixConfig = c
+	} else {
+		c, err := configuration.FindConfiguration(log, cfg, opts.NixPathIncludes, false)
+		if err != nil {
+			log.Errorf("failed to find configuration: %v", err)
+			return err
+		}
+		nixConfig = c
+	}
+
+	value, err := nixConfig.EvalAttribute(opts.Attribute, &configuration.EvalOptions{
+		JSON:     opts.DisplayJson,
+		Raw:      opts.Raw,
+		Apply:    opts.Apply,
+		CmdFlags: cmd.Flags(),
+		NixOpts:  &opts.NixOptions,
+	})
+	if err != nil {
+		log.Errorf("failed to evaluate %v", opts.Attribute)
+		if evalErr, ok := err.(*configuration.AttributeEvaluationError); ok && evalErr.EvaluationOutput != "" {
+			log.Print(evalErr.EvaluationOutput)
+		}
+		return err
+	}
+
+	// Raw values are printed exactly as they are, in the
+	// same way as `nix eval --raw` does.
+	if opts.Raw {
+		fmt.Fprint(os.Stdout, *value)
+		return nil
+	}
+
+	fmt.Println(*value)
+
+	return nil
+}
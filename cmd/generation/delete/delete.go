@@ -16,6 +16,8 @@ import (
 	"github.com/nix-community/nixos-cli/internal/cmd/utils"
 	"github.com/nix-community/nixos-cli/internal/constants"
 	"github.com/nix-community/nixos-cli/internal/generation"
+	"github.com/nix-community/nixos-cli/internal/history"
+	"github.com/nix-community/nixos-cli/internal/hooks"
 	"github.com/nix-community/nixos-cli/internal/logger"
 	"github.com/nix-community/nixos-cli/internal/settings"
 	"github.com/nix-community/nixos-cli/internal/system"
@@ -89,6 +91,7 @@ func GenerationDeleteCommand(genOpts *cmdOpts.GenerationOpts) *cobra.Command {
 	cmd.Flags().StringVarP(&opts.OlderThan, "older-than", "o", "", "Delete all generations older than `period`")
 	cmd.Flags().UintSliceVarP(&opts.Keep, "keep", "k", nil, "Always keep this `gen`, can be specified many times")
 	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "Show verbose logging")
+	cmd.Flags().BoolVar(&opts.WaitForLock, "wait", false, "Wait for other operations in progress to finish")
 	cmd.Flags().BoolVarP(&opts.AlwaysConfirm, "yes", "y", false, "Automatically confirm generation deletion")
 
 	_ = cmd.RegisterFlagCompletionFunc("from", generation.CompleteGenerationNumberFlag(&genOpts.ProfileName))
@@ -109,7 +112,7 @@ The 'period' parameter in --older-than is a systemd.time(7) span
 	return &cmd
 }
 
-func generationDeleteMain(cmd *cobra.Command, genOpts *cmdOpts.GenerationOpts, opts *cmdOpts.GenerationDeleteOpts) error {
+func generationDeleteMain(cmd *cobra.Command, genOpts *cmdOpts.GenerationOpts, opts *cmdOpts.GenerationDeleteOpts) (err error) {
 	log := logger.FromContext(cmd.Context())
 	cfg := settings.FromContext(cmd.Context())
 	s := system.NewLocalSystem(log)
@@ -128,12 +131,18 @@ func generationDeleteMain(cmd *cobra.Command, genOpts *cmdOpts.GenerationOpts, o
 		}
 	}
 
+	operationLock, err := cmdUtils.AcquireOperationLock(log, "/", opts.WaitForLock)
+	if err != nil {
+		return err
+	}
+	defer cmdUtils.ReleaseOperationLock(log, operationLock)
+
 	generations, err := genUtils.LoadGenerations(log, genOpts.ProfileName, false)
 	if err != nil {
 		return err
 	}
 
-	gensToDelete, err := resolveGenerationsToDelete(generations, opts)
+	gensToDelete, gensPinned, err := resolveGenerationsToDelete(generations, opts)
 	if err != nil {
 		log.Errorf("%v", err)
 
@@ -141,6 +150,9 @@ func generationDeleteMain(cmd *cobra.Command, genOpts *cmdOpts.GenerationOpts, o
 		case GenerationResolveMinError:
 			log.Info("keeping all generations")
 		case GenerationResolveNoneFoundError:
+			for _, g := range gensPinned {
+				log.Infof("generation %v is %v", g.Number, g.Pin.Describe())
+			}
 			log.Info("there is nothing to do; exiting")
 		}
 		return err
@@ -154,6 +166,15 @@ func generationDeleteMain(cmd *cobra.Command, genOpts *cmdOpts.GenerationOpts, o
 	log.Printf("\nThere will be %v generations remaining on this machine.", remainingGenCount)
 	log.Print()
 
+	if len(gensPinned) > 0 {
+		log.Print("The following generations are pinned, and will be kept:")
+		log.Print()
+		for _, g := range gensPinned {
+			log.Printf("  %v: %v", g.Number, g.Pin.Describe())
+		}
+		log.Print()
+	}
+
 	if !opts.AlwaysConfirm {
 		confirm, err := cmdUtils.ConfirmationInput("Proceed?")
 		if err != nil {
@@ -166,6 +187,42 @@ func generationDeleteMain(cmd *cobra.Command, genOpts *cmdOpts.GenerationOpts, o
 		}
 	}
 
+	hookCtx := &hooks.Context{
+		Command:     "generation delete",
+		Profile:     genOpts.ProfileName,
+		Generations: make([]uint64, len(gensToDelete)),
+	}
+	for i, g := range gensToDelete {
+		hookCtx.Generations[i] = g.Number
+	}
+	for _, g := range generations {
+		if g.IsCurrent {
+			hookCtx.OldGeneration = g.Number
+		}
+	}
+
+	historyRecord := history.NewRecord("generation delete", genOpts.ProfileName)
+	historyRecord.Action = "delete"
+	historyRecord.PreviousGeneration = hookCtx.OldGeneration
+	historyRecord.DeletedGenerations = hookCtx.Generations
+	defer func() { cmdUtils.WriteHistoryRecord(log, s, historyRecord, err) }()
+
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		hookCtx.Error = err
+		if err := hooks.Run(s, &cfg.Hooks, hooks.StageOnFailure, hookCtx, opts.Verbose); err != nil {
+			log.Warnf("%v", err)
+		}
+	}()
+
+	if err := hooks.Run(s, &cfg.Hooks, hooks.StagePreDelete, hookCtx, opts.Verbose); err != nil {
+		log.Errorf("%v", err)
+		return err
+	}
+
 	log.Step("Deleting generations...")
 
 	profileDirectory := generation.GetProfileDirectoryFromName(genOpts.ProfileName)
@@ -174,6 +231,14 @@ func generationDeleteMain(cmd *cobra.Command, genOpts *cmdOpts.GenerationOpts, o
 		return err
 	}
 
+	// Metadata of deleted generations would otherwise be picked up
+	// by new generations once their numbers are reused.
+	for _, g := range gensToDelete {
+		if err := generation.RemoveGenerationMetadata(genOpts.ProfileName, g.Number); err != nil {
+			log.Warnf("failed to remove metadata for generation %v: %v", g.Number, err)
+		}
+	}
+
 	log.Step("Regenerating boot menu entries...")
 
 	if err := regenerateBootMenu(s, opts.Verbose); err != nil {
@@ -188,6 +253,10 @@ func generationDeleteMain(cmd *cobra.Command, genOpts *cmdOpts.GenerationOpts, o
 		return err
 	}
 
+	if err := hooks.Run(s, &cfg.Hooks, hooks.StagePostDelete, hookCtx, opts.Verbose); err != nil {
+		log.Warnf("%v", err)
+	}
+
 	log.Print("Success!")
 
 	return nil
@@ -16,7 +16,11 @@ type generationSet map[uint64]present
 // evil type system hack to avoid typing struct{} all the time
 type present struct{}
 
-func resolveGenerationsToDelete(generations []generation.Generation, opts *cmdOpts.GenerationDeleteOpts) ([]generation.Generation, error) {
+// Resolve which generations to delete from the given constraints. Pinned
+// generations are always kept, like the current generation, and the
+// pinned generations that would have been deleted otherwise are also
+// returned, even if there is nothing else to delete.
+func resolveGenerationsToDelete(generations []generation.Generation, opts *cmdOpts.GenerationDeleteOpts) ([]generation.Generation, []generation.Generation, error) {
 	currentGenIdx := slices.IndexFunc(generations, func(g generation.Generation) bool {
 		return g.IsCurrent
 	})
@@ -28,14 +32,14 @@ func resolveGenerationsToDelete(generations []generation.Generation, opts *cmdOp
 	totalGenerations := uint64(len(generations))
 
 	if totalGenerations == 0 {
-		return nil, fmt.Errorf("no generations exist in profile")
+		return nil, nil, fmt.Errorf("no generations exist in profile")
 	}
 	if totalGenerations == 1 {
-		return nil, fmt.Errorf("only one generations exists in profile, cannot delete the current generation")
+		return nil, nil, fmt.Errorf("only one generations exists in profile, cannot delete the current generation")
 	}
 
 	if opts.MinimumToKeep > 0 && opts.MinimumToKeep >= totalGenerations {
-		return nil, GenerationResolveMinError{ExpectedMinimum: opts.MinimumToKeep, AvailableGenerations: totalGenerations}
+		return nil, nil, GenerationResolveMinError{ExpectedMinimum: opts.MinimumToKeep, AvailableGenerations: totalGenerations}
 	}
 
 	gensToKeep := make(generationSet, len(opts.Keep))
@@ -44,6 +48,13 @@ func resolveGenerationsToDelete(generations []generation.Generation, opts *cmdOp
 	}
 	gensToKeep[currentGen.Number] = present{}
 
+	gensPinned := make(generationSet)
+	for _, v := range generations {
+		if v.Pin != nil {
+			gensPinned[v.Number] = present{}
+		}
+	}
+
 	gensToRemove := make(generationSet, len(opts.Remove))
 	for _, v := range opts.Remove {
 		gensToRemove[uint64(v)] = present{}
@@ -65,13 +76,13 @@ func resolveGenerationsToDelete(generations []generation.Generation, opts *cmdOp
 			}
 
 			if lowerBound > upperBound {
-				return nil, GenerationResolveBoundsError{LowerBound: lowerBound, UpperBound: upperBound}
+				return nil, nil, GenerationResolveBoundsError{LowerBound: lowerBound, UpperBound: upperBound}
 			}
 			if upperBound > generations[len(generations)-1].Number || upperBound < generations[0].Number {
-				return nil, GenerationResolveRangeError{InvalidBound: upperBound}
+				return nil, nil, GenerationResolveRangeError{InvalidBound: upperBound}
 			}
 			if lowerBound < generations[0].Number || lowerBound > generations[len(generations)-1].Number {
-				return nil, GenerationResolveRangeError{InvalidBound: lowerBound}
+				return nil, nil, GenerationResolveRangeError{InvalidBound: lowerBound}
 			}
 
 			for _, v := range generations {
@@ -98,6 +109,27 @@ func resolveGenerationsToDelete(generations []generation.Generation, opts *cmdOp
 		delete(gensToRemove, g)
 	}
 
+	// Generations that are kept explicitly do not need
+	// to be reported as being kept because of a pin.
+	saved := []generation.Generation{}
+	for _, g := range generations {
+		if _, ok := gensPinned[g.Number]; !ok {
+			continue
+		}
+		if _, ok := gensToRemove[g.Number]; ok {
+			saved = append(saved, g)
+			delete(gensToRemove, g.Number)
+		}
+	}
+
+	// If it is not known whether a generation is pinned,
+	// it is not safe to delete it.
+	for _, g := range generations {
+		if _, ok := gensToRemove[g.Number]; ok && g.PinError != nil {
+			return nil, saved, GenerationResolvePinError{Generation: g.Number, Err: g.PinError}
+		}
+	}
+
 	remainingGenCount := uint64(len(generations) - len(gensToRemove))
 	if opts.MinimumToKeep > 0 && remainingGenCount < opts.MinimumToKeep {
 		for j := range generations {
@@ -114,7 +146,7 @@ func resolveGenerationsToDelete(generations []generation.Generation, opts *cmdOp
 	}
 
 	if len(gensToRemove) == 0 {
-		return nil, GenerationResolveNoneFoundError{}
+		return nil, saved, GenerationResolveNoneFoundError{}
 	}
 
 	result := make([]generation.Generation, 0, len(gensToRemove))
@@ -129,7 +161,7 @@ func resolveGenerationsToDelete(generations []generation.Generation, opts *cmdOp
 	sort.Slice(result, func(i, j int) bool {
 		return result[i].Number < result[j].Number
 	})
-	return result, nil
+	return result, saved, nil
 }
 
 type GenerationResolveMinError struct {
@@ -158,6 +190,19 @@ func (e GenerationResolveRangeError) Error() string {
 	return fmt.Sprintf("bound '%v' is not within the range of available generations", e.InvalidBound)
 }
 
+type GenerationResolvePinError struct {
+	Generation uint64
+	Err        error
+}
+
+func (e GenerationResolvePinError) Error() string {
+	return fmt.Sprintf("unable to determine if generation %v is pinned: %v", e.Generation, e.Err)
+}
+
+func (e GenerationResolvePinError) Unwrap() error {
+	return e.Err
+}
+
 type GenerationResolveNoneFoundError struct{}
 
 func (e GenerationResolveNoneFoundError) Error() string {
@@ -84,7 +84,7 @@ func TestResolveGenerationsToDelete(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			result, err := resolveGenerationsToDelete(generations, test.opts)
+			result, _, err := resolveGenerationsToDelete(generations, test.opts)
 
 			if test.expectErr != nil {
 				if !errors.Is(err, test.expectErr) {
@@ -107,3 +107,99 @@ func TestResolveGenerationsToDelete(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveGenerationsToDeletePinned(t *testing.T) {
+	generations := []generation.Generation{
+		{Number: 1, Pin: &generation.Pin{Reason: "known good"}},
+		{Number: 2},
+		{Number: 3, Pin: &generation.Pin{}},
+		{Number: 4, IsCurrent: true},
+	}
+
+	tests := []struct {
+		name        string
+		opts        *cmdOpts.GenerationDeleteOpts
+		expect      []uint64
+		expectSaved []uint64
+		expectErr   error
+	}{
+		{
+			name:        "Delete all generations",
+			opts:        &cmdOpts.GenerationDeleteOpts{All: true},
+			expect:      []uint64{2},
+			expectSaved: []uint64{1, 3},
+		},
+		{
+			name:        "Pinned generation that is kept explicitly",
+			opts:        &cmdOpts.GenerationDeleteOpts{All: true, Keep: []uint{1}},
+			expect:      []uint64{2},
+			expectSaved: []uint64{3},
+		},
+		{
+			name:        "Only pinned generations",
+			opts:        &cmdOpts.GenerationDeleteOpts{Remove: []uint{1, 3}},
+			expectSaved: []uint64{1, 3},
+			expectErr:   GenerationResolveNoneFoundError{},
+		},
+		{
+			name:        "Unrelated generations",
+			opts:        &cmdOpts.GenerationDeleteOpts{Remove: []uint{2}},
+			expect:      []uint64{2},
+			expectSaved: []uint64{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, saved, err := resolveGenerationsToDelete(generations, test.opts)
+
+			if test.expectErr != nil {
+				if !errors.Is(err, test.expectErr) {
+					t.Errorf("expected error %v, got %v", test.expectErr, err)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			resultNumbers := []uint64{}
+			for _, g := range result {
+				resultNumbers = append(resultNumbers, g.Number)
+			}
+			if test.expect != nil && !reflect.DeepEqual(test.expect, resultNumbers) {
+				t.Errorf("expected %v, got %v", test.expect, resultNumbers)
+			}
+
+			savedNumbers := []uint64{}
+			for _, g := range saved {
+				savedNumbers = append(savedNumbers, g.Number)
+			}
+			if !reflect.DeepEqual(test.expectSaved, savedNumbers) {
+				t.Errorf("expected saved %v, got %v", test.expectSaved, savedNumbers)
+			}
+		})
+	}
+}
+
+func TestResolveGenerationsToDeleteUnreadablePin(t *testing.T) {
+	pinErr := errors.New("permission denied")
+
+	generations := []generation.Generation{
+		{Number: 1},
+		{Number: 2, PinError: pinErr},
+		{Number: 3, IsCurrent: true},
+	}
+
+	_, _, err := resolveGenerationsToDelete(generations, &cmdOpts.GenerationDeleteOpts{All: true})
+	if !errors.Is(err, pinErr) {
+		t.Errorf("expected error %v, got %v", pinErr, err)
+	}
+
+	// Generations that are not deleted do not need to be checked.
+	result, _, err := resolveGenerationsToDelete(generations, &cmdOpts.GenerationDeleteOpts{Remove: []uint{1}})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(result) != 1 || result[0].Number != 1 {
+		t.Errorf("expected [1], got %v", result)
+	}
+}
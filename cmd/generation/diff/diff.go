@@ -1,15 +1,19 @@
 package diff
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strconv"
 
 	"github.com/spf13/cobra"
 
+	"github.com/nix-community/nixos-cli/internal/closure"
 	"github.com/nix-community/nixos-cli/internal/cmd/opts"
 	"github.com/nix-community/nixos-cli/internal/cmd/utils"
 	"github.com/nix-community/nixos-cli/internal/constants"
+	"github.com/nix-community/nixos-cli/internal/flake"
 	"github.com/nix-community/nixos-cli/internal/generation"
 	"github.com/nix-community/nixos-cli/internal/logger"
 	"github.com/nix-community/nixos-cli/internal/settings"
@@ -48,6 +52,8 @@ func GenerationDiffCommand(genOpts *cmdOpts.GenerationOpts) *cobra.Command {
 		},
 	}
 
+	cmd.Flags().BoolVarP(&opts.Inputs, "inputs", "i", false, "Show differences in flake inputs as well")
+	cmd.Flags().BoolVarP(&opts.DisplayJson, "json", "j", false, "Display differences in JSON format")
 	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "Show verbose logging")
 
 	cmd.SetHelpTemplate(cmd.HelpTemplate() + `
@@ -73,6 +79,42 @@ func generationDiffMain(cmd *cobra.Command, genOpts *cmdOpts.GenerationOpts, opt
 	beforeDirectory := filepath.Join(profileDirectory, fmt.Sprintf("%v-%v-link", genOpts.ProfileName, opts.Before))
 	afterDirectory := filepath.Join(profileDirectory, fmt.Sprintf("%v-%v-link", genOpts.ProfileName, opts.After))
 
+	var inputChanges []flake.InputChange
+	if opts.Inputs {
+		changes, err := diffInputs(log, genOpts.ProfileName, uint64(opts.Before), uint64(opts.After))
+		if err != nil {
+			return err
+		}
+		inputChanges = changes
+	}
+
+	if opts.DisplayJson {
+		diff, err := generation.DiffClosures(s, beforeDirectory, afterDirectory, opts.Verbose)
+		if err != nil {
+			log.Errorf("failed to compare generations: %v", err)
+			return err
+		}
+
+		if !opts.Inputs {
+			return closure.WriteJSON(os.Stdout, diff)
+		}
+
+		bytes, _ := json.MarshalIndent(map[string]any{
+			"inputs":  inputChanges,
+			"closure": diff,
+		}, "", "  ")
+		fmt.Printf("%v\n", string(bytes))
+
+		return nil
+	}
+
+	if opts.Inputs && inputChanges != nil {
+		if err := flake.WriteInputChangesText(os.Stdout, inputChanges); err != nil {
+			return err
+		}
+		fmt.Println()
+	}
+
 	err := generation.RunDiffCommand(log, s, beforeDirectory, afterDirectory, &generation.DiffCommandOptions{
 		UseNvd:  cfg.UseNvd,
 		Verbose: opts.Verbose,
@@ -84,3 +126,37 @@ func generationDiffMain(cmd *cobra.Command, genOpts *cmdOpts.GenerationOpts, opt
 
 	return nil
 }
+
+// Compare the flake lock files that were recorded for two generations.
+// If either generation has no recorded lock file, nil is returned.
+func diffInputs(log *logger.Logger, profile string, before uint64, after uint64) ([]flake.InputChange, error) {
+	locks := make([]*flake.LockFile, 2)
+
+	profileDirectory := generation.GetProfileDirectoryFromName(profile)
+
+	for i, number := range []uint64{before, after} {
+		storePath, err := filepath.EvalSymlinks(fmt.Sprintf("%s-%d-link", profileDirectory, number))
+		if err != nil {
+			log.Errorf("failed to resolve generation %v: %v", number, err)
+			return nil, err
+		}
+
+		lock, err := generation.ReadFlakeLock(profile, number, storePath)
+		if err != nil {
+			log.Errorf("failed to read flake lock file for generation %v: %v", number, err)
+			return nil, err
+		}
+
+		if lock == nil {
+			log.Warnf("no flake lock file was recorded for generation %v, not comparing inputs", number)
+			return nil, nil
+		}
+
+		locks[i] = lock
+	}
+
+	changes := flake.CompareLockFiles(locks[0], locks[1])
+	flake.CountInputCommits(changes)
+
+	return changes, nil
+}
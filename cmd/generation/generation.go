@@ -10,8 +10,11 @@ import (
 	genDeleteCmd "github.com/nix-community/nixos-cli/cmd/generation/delete"
 	genDiffCmd "github.com/nix-community/nixos-cli/cmd/generation/diff"
 	genListCmd "github.com/nix-community/nixos-cli/cmd/generation/list"
+	genPinCmd "github.com/nix-community/nixos-cli/cmd/generation/pin"
 	genRollbackCmd "github.com/nix-community/nixos-cli/cmd/generation/rollback"
 	genSwitchCmd "github.com/nix-community/nixos-cli/cmd/generation/switch"
+	genTagCmd "github.com/nix-community/nixos-cli/cmd/generation/tag"
+	genUnpinCmd "github.com/nix-community/nixos-cli/cmd/generation/unpin"
 )
 
 func GenerationCommand() *cobra.Command {
@@ -28,8 +31,11 @@ func GenerationCommand() *cobra.Command {
 	cmd.AddCommand(genDeleteCmd.GenerationDeleteCommand(&opts))
 	cmd.AddCommand(genDiffCmd.GenerationDiffCommand(&opts))
 	cmd.AddCommand(genListCmd.GenerationListCommand(&opts))
+	cmd.AddCommand(genPinCmd.GenerationPinCommand(&opts))
 	cmd.AddCommand(genSwitchCmd.GenerationSwitchCommand(&opts))
+	cmd.AddCommand(genTagCmd.GenerationTagCommand(&opts))
 	cmd.AddCommand(genRollbackCmd.GenerationRollbackCommand(&opts))
+	cmd.AddCommand(genUnpinCmd.GenerationUnpinCommand(&opts))
 
 	cmdUtils.SetHelpFlagText(&cmd)
 
@@ -1,19 +1,24 @@
 package list
 
 import (
+	"cmp"
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/nix-community/nixos-cli/cmd/generation/shared"
+	"github.com/nix-community/nixos-cli/internal/closure"
 	"github.com/nix-community/nixos-cli/internal/cmd/opts"
 	"github.com/nix-community/nixos-cli/internal/cmd/utils"
 	"github.com/nix-community/nixos-cli/internal/generation"
 	"github.com/nix-community/nixos-cli/internal/logger"
+	"github.com/nix-community/nixos-cli/internal/settings"
+	"github.com/nix-community/nixos-cli/internal/utils"
 	"github.com/olekukonko/tablewriter"
 )
 
@@ -31,6 +36,7 @@ func GenerationListCommand(genOpts *cmdOpts.GenerationOpts) *cobra.Command {
 
 	cmd.Flags().BoolVarP(&opts.DisplayJson, "json", "j", false, "Display in JSON format")
 	cmd.Flags().BoolVarP(&opts.DisplayTable, "table", "t", false, "Display in table format")
+	cmd.Flags().BoolVarP(&opts.ComputeSizes, "compute-sizes", "s", false, "Compute and cache closure sizes that are not cached yet")
 
 	cmdUtils.SetHelpFlagText(&cmd)
 
@@ -39,12 +45,27 @@ func GenerationListCommand(genOpts *cmdOpts.GenerationOpts) *cobra.Command {
 
 func generationListMain(cmd *cobra.Command, genOpts *cmdOpts.GenerationOpts, opts *cmdOpts.GenerationListOpts) error {
 	log := logger.FromContext(cmd.Context())
+	cfg := settings.FromContext(cmd.Context())
+
+	// Computing closure sizes is slow, so they are only
+	// useful if they can be cached, which requires root.
+	if opts.ComputeSizes && os.Geteuid() != 0 {
+		err := utils.ExecAsRoot(cfg.RootCommand)
+		if err != nil {
+			log.Errorf("failed to re-exec command as root: %v", err)
+			return err
+		}
+	}
 
 	generations, err := genUtils.LoadGenerations(log, genOpts.ProfileName, true)
 	if err != nil {
 		return err
 	}
 
+	if opts.ComputeSizes {
+		genUtils.LoadClosureSizes(log, genOpts.ProfileName, generations)
+	}
+
 	if opts.DisplayTable {
 		displayTable(generations)
 		return nil
@@ -57,7 +78,7 @@ func generationListMain(cmd *cobra.Command, genOpts *cmdOpts.GenerationOpts, opt
 		return nil
 	}
 
-	err = generationUI(log, genOpts.ProfileName, generations)
+	err = generationUI(log, genOpts.ProfileName, generations, opts.ComputeSizes)
 	if err != nil {
 		log.Errorf("error running generation TUI: %v", err)
 		return err
@@ -66,24 +87,69 @@ func generationListMain(cmd *cobra.Command, genOpts *cmdOpts.GenerationOpts, opt
 	return nil
 }
 
+// Get the change in closure size of each generation since the
+// generation before it, keyed by generation number. Generations
+// are only included if the sizes of both are known.
+func closureSizeDeltas(generations []generation.Generation) map[uint64]int64 {
+	sorted := slices.Clone(generations)
+	slices.SortFunc(sorted, func(a, b generation.Generation) int {
+		return cmp.Compare(a.Number, b.Number)
+	})
+
+	deltas := make(map[uint64]int64)
+
+	for i := 1; i < len(sorted); i++ {
+		before, after := sorted[i-1].ClosureSize, sorted[i].ClosureSize
+		if before == nil || after == nil {
+			continue
+		}
+		deltas[sorted[i].Number] = int64(after.NarSize) - int64(before.NarSize)
+	}
+
+	return deltas
+}
+
 func displayTable(generations []generation.Generation) {
 	data := make([][]string, len(generations))
+	deltas := closureSizeDeltas(generations)
 
 	for i, v := range generations {
+		gitCommit := ""
+		if v.GitProvenance != nil {
+			gitCommit = v.GitProvenance.ShortCommit()
+		}
+
+		size, paths, growth := "", "", ""
+		if v.ClosureSize != nil {
+			size = utils.FormatBytes(v.ClosureSize.NarSize)
+			paths = fmt.Sprintf("%v", v.ClosureSize.PathCount)
+		}
+		if delta, ok := deltas[v.Number]; ok {
+			growth = closure.FormatSizeDelta(delta)
+		}
+
+		description, _, _ := strings.Cut(v.Description, "\n")
+
 		data[i] = []string{
 			fmt.Sprintf("%v", v.Number),
 			fmt.Sprintf("%v", v.IsCurrent),
+			fmt.Sprintf("%v", v.Pin != nil),
 			fmt.Sprintf("%v", v.CreationDate.Format(time.ANSIC)),
 			v.NixosVersion,
 			v.NixpkgsRevision,
 			v.ConfigurationRevision,
+			gitCommit,
 			v.KernelVersion,
+			size,
+			paths,
+			growth,
 			strings.Join(v.Specialisations, ","),
+			description,
 		}
 	}
 
 	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"Number", "Current", "Date", "NixOS Version", "Nixpkgs Version", "Config Version", "Kernel Version", "Specialisations"})
+	table.SetHeader([]string{"Number", "Current", "Pinned", "Date", "NixOS Version", "Nixpkgs Version", "Config Version", "Git Commit", "Kernel Version", "Size", "Paths", "Growth", "Specialisations", "Description"})
 	table.SetAutoWrapText(false)
 	table.SetAutoFormatHeaders(true)
 	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
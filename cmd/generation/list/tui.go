@@ -1,6 +1,7 @@
 package list
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
@@ -10,13 +11,18 @@ import (
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/muesli/termenv"
 	"github.com/nix-community/nixos-cli/cmd/generation/shared"
+	"github.com/nix-community/nixos-cli/internal/closure"
 	"github.com/nix-community/nixos-cli/internal/cmd/utils"
 	"github.com/nix-community/nixos-cli/internal/generation"
 	"github.com/nix-community/nixos-cli/internal/logger"
+	"github.com/nix-community/nixos-cli/internal/system"
+	"github.com/nix-community/nixos-cli/internal/utils"
 )
 
 var (
@@ -34,6 +40,8 @@ var (
 	currentItemStyle  = lipgloss.NewStyle().MarginLeft(4).PaddingLeft(1).Foreground(ansiGreen).Border(lipgloss.NormalBorder(), false, false, false, true).BorderForeground(ansiGreen)
 	selectedItemStyle = lipgloss.NewStyle().MarginLeft(4).PaddingLeft(1).Foreground(ansiYellow).Border(lipgloss.NormalBorder(), false, false, false, true).BorderForeground(ansiYellow)
 	attrStyle         = lipgloss.NewStyle().Foreground(ansiCyan)
+	titleStyle        = lipgloss.NewStyle().MarginLeft(2).Background(ansiRed).Foreground(ansiWhite)
+	helpStyle         = list.DefaultStyles().HelpStyle.PaddingLeft(4)
 	boldStyle         = lipgloss.NewStyle().Bold(true)
 	italicStyle       = lipgloss.NewStyle().Italic(true)
 )
@@ -41,6 +49,8 @@ var (
 type generationItem struct {
 	Generation generation.Generation
 	Selected   bool
+	// Change in closure size since the previous generation, if known
+	SizeDelta *int64
 }
 
 func (i generationItem) FilterValue() string {
@@ -50,7 +60,7 @@ func (i generationItem) FilterValue() string {
 
 type generationItemDelegate struct{}
 
-func (d generationItemDelegate) Height() int { return 7 }
+func (d generationItemDelegate) Height() int { return 9 }
 
 func (d generationItemDelegate) Spacing() int { return 1 }
 
@@ -68,6 +78,9 @@ func (d generationItemDelegate) Render(w io.Writer, m list.Model, index int, lis
 	if g.IsCurrent {
 		current = " (active)"
 	}
+	if g.Pin != nil {
+		current += " (pinned)"
+	}
 
 	str := boldStyle.Render(fmt.Sprintf("%v%v", g.Number, current))
 	if len(g.Description) > 0 {
@@ -85,11 +98,24 @@ func (d generationItemDelegate) Render(w io.Writer, m list.Model, index int, lis
 		nixpkgsRev = italicStyle.Render("(unknown)")
 	}
 
+	gitCommit := italicStyle.Render("(unknown)")
+	if g.GitProvenance != nil {
+		gitCommit = g.GitProvenance.Describe()
+	}
+
 	kernelVersion := g.KernelVersion
 	if kernelVersion == "" {
 		kernelVersion = italicStyle.Render("(unknown)")
 	}
 
+	closureSize := italicStyle.Render("(unknown)")
+	if g.ClosureSize != nil {
+		closureSize = fmt.Sprintf("%v, %v paths", utils.FormatBytes(g.ClosureSize.NarSize), g.ClosureSize.PathCount)
+		if i.SizeDelta != nil {
+			closureSize += fmt.Sprintf(" (%v)", closure.FormatSizeDelta(*i.SizeDelta))
+		}
+	}
+
 	var specialisations string
 	if len(g.Specialisations) > 0 {
 		specialisations = strings.Join(g.Specialisations, ", ")
@@ -101,7 +127,9 @@ func (d generationItemDelegate) Render(w io.Writer, m list.Model, index int, lis
 	str += fmt.Sprintf("\n%s    :: %s", attrStyle.Render("Creation Date"), g.CreationDate.Format(time.ANSIC))
 	str += fmt.Sprintf("\n%s :: %s", attrStyle.Render("Nixpkgs Revision"), nixpkgsRev)
 	str += fmt.Sprintf("\n%s  :: %s", attrStyle.Render("Config Revision"), cfgRev)
+	str += fmt.Sprintf("\n%s       :: %s", attrStyle.Render("Git Commit"), gitCommit)
 	str += fmt.Sprintf("\n%s   :: %s", attrStyle.Render("Kernel Version"), kernelVersion)
+	str += fmt.Sprintf("\n%s     :: %s", attrStyle.Render("Closure Size"), closureSize)
 	str += fmt.Sprintf("\n%s  :: %s", attrStyle.Render("Specialisations"), specialisations)
 
 	fn := itemStyle.Render
@@ -139,10 +167,116 @@ type deleteAction struct {
 
 func (a deleteAction) Type() string { return "delete" }
 
+type tagAction struct {
+	Generation  uint64
+	Description string
+}
+
+func (a tagAction) Type() string { return "tag" }
+
 type model struct {
 	list    list.Model
+	log     *logger.Logger
 	profile string
 	action  endAction
+
+	width  int
+	height int
+
+	// Differences between the active generation
+	// and the selected one, when they are shown
+	showingDiff bool
+	diffTitle   string
+	diffView    viewport.Model
+
+	// New description of the selected generation, when it is being edited
+	editingTag    bool
+	tagGeneration generation.Generation
+	tagInput      textinput.Model
+}
+
+type diffMsg struct {
+	title   string
+	content string
+	err     error
+}
+
+func (m model) loadDiff(before uint64, after uint64) tea.Cmd {
+	profileDirectory := generation.GetProfileDirectoryFromName(m.profile)
+	beforeLink := fmt.Sprintf("%s-%d-link", profileDirectory, before)
+	afterLink := fmt.Sprintf("%s-%d-link", profileDirectory, after)
+
+	return func() tea.Msg {
+		msg := diffMsg{title: fmt.Sprintf("Changes from generation %d to %d", before, after)}
+
+		diff, err := generation.DiffClosures(system.NewLocalSystem(m.log), beforeLink, afterLink, false)
+		if err != nil {
+			msg.err = err
+			return msg
+		}
+
+		var buf bytes.Buffer
+		msg.err = closure.WriteText(&buf, diff)
+		msg.content = buf.String()
+
+		return msg
+	}
+}
+
+func (m model) updateDiffView(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "q", "esc":
+			m.showingDiff = false
+			return m, nil
+		case "ctrl+c":
+			m.action = quitAction{}
+			return m, tea.Quit
+		}
+	}
+
+	var cmd tea.Cmd
+	m.diffView, cmd = m.diffView.Update(msg)
+	return m, cmd
+}
+
+func (m model) editTag(g generation.Generation) (tea.Model, tea.Cmd) {
+	input := textinput.New()
+	input.Prompt = "Description: "
+	input.PromptStyle = lipgloss.NewStyle().Foreground(ansiBlue).Bold(true)
+	input.Width = max(m.width-20, 20)
+	input.SetValue(g.Description)
+
+	m.editingTag = true
+	m.tagGeneration = g
+	m.tagInput = input
+
+	return m, m.tagInput.Focus()
+}
+
+func (m model) updateTagInput(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "enter":
+			m.editingTag = false
+			description := m.tagInput.Value()
+			if description == m.tagGeneration.Description {
+				return m, nil
+			}
+			m.action = tagAction{Generation: m.tagGeneration.Number, Description: description}
+			return m, tea.Quit
+		case "esc":
+			m.editingTag = false
+			return m, nil
+		case "ctrl+c":
+			m.action = quitAction{}
+			return m, tea.Quit
+		}
+	}
+
+	var cmd tea.Cmd
+	m.tagInput, cmd = m.tagInput.Update(msg)
+	return m, cmd
 }
 
 func (m model) Init() tea.Cmd {
@@ -152,10 +286,34 @@ func (m model) Init() tea.Cmd {
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
 		m.list.SetWidth(msg.Width)
 		m.list.SetHeight(msg.Height - 1)
+		m.diffView.Width = msg.Width
+		m.diffView.Height = max(msg.Height-4, 1)
+		return m, nil
+
+	case diffMsg:
+		if msg.err != nil {
+			return m, m.list.NewStatusMessage(fmt.Sprintf("failed to compare generations: %v", msg.err))
+		}
+
+		m.diffView = viewport.New(m.width, max(m.height-4, 1))
+		m.diffView.SetContent(msg.content)
+		m.diffTitle = msg.title
+		m.showingDiff = true
 		return m, nil
+	}
+
+	if m.showingDiff {
+		return m.updateDiffView(msg)
+	}
 
+	if m.editingTag {
+		return m.updateTagInput(msg)
+	}
+
+	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		if m.list.FilterState() == list.Filtering {
 			break
@@ -186,8 +344,34 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, tea.Quit
 			}
 
+		case "c":
+			selected := m.list.SelectedItem().(generationItem).Generation
+
+			var current *generation.Generation
+			for _, v := range m.list.Items() {
+				if g := v.(generationItem).Generation; g.IsCurrent {
+					current = &g
+					break
+				}
+			}
+
+			if current == nil || current.Number == selected.Number {
+				return m, m.list.NewStatusMessage("select a generation other than the active one to compare")
+			}
+
+			return m, tea.Batch(
+				m.list.NewStatusMessage(fmt.Sprintf("comparing generation %d to %d...", current.Number, selected.Number)),
+				m.loadDiff(current.Number, selected.Number),
+			)
+
+		case "t":
+			return m.editTag(m.list.SelectedItem().(generationItem).Generation)
+
 		case tea.KeySpace.String():
 			i := m.list.SelectedItem().(generationItem)
+			if i.Generation.Pin != nil {
+				return m, m.list.NewStatusMessage(fmt.Sprintf("generation %d is %v", i.Generation.Number, i.Generation.Pin.Describe()))
+			}
 			if !i.Generation.IsCurrent {
 				i.Selected = !i.Selected
 				m.list.SetItem(m.list.Index(), i)
@@ -223,6 +407,25 @@ func runGenerationSwitchCmd(log *logger.Logger, generation uint64, profile strin
 	return cmd.Run()
 }
 
+func runGenerationTagCmd(log *logger.Logger, generation uint64, description string, profile string) error {
+	argv := []string{os.Args[0], "generation", "-p", profile, "tag"}
+	if description == "" {
+		argv = append(argv, "--clear", fmt.Sprintf("%v", generation))
+	} else {
+		argv = append(argv, "--", fmt.Sprintf("%v", generation), description)
+	}
+
+	cmd := exec.Command(argv[0], argv[1:]...)
+
+	log.CmdArray(argv)
+
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+
+	return cmd.Run()
+}
+
 func runGenerationDeleteCmd(log *logger.Logger, generations []uint64, profile string) error {
 	argv := []string{os.Args[0], "generation", "-p", profile, "delete"}
 	for _, v := range generations {
@@ -246,23 +449,39 @@ func (m model) View() string {
 		return ""
 	}
 
+	if m.showingDiff {
+		return "\n" + titleStyle.Render(m.diffTitle) + "\n\n" + m.diffView.View() + "\n" + helpStyle.Render("↑/↓: scroll • q/esc: back")
+	}
+
+	if m.editingTag {
+		title := titleStyle.Render(fmt.Sprintf("Editing description of generation %d", m.tagGeneration.Number))
+		input := lipgloss.NewStyle().PaddingLeft(4).Render(m.tagInput.View())
+		return "\n" + title + "\n\n" + input + "\n\n" + helpStyle.Render("enter: save (empty restores the original) • esc: cancel")
+	}
+
 	return "\n" + m.list.View()
 }
 
 func newGenerationList(generations []generation.Generation) list.Model {
+	deltas := closureSizeDeltas(generations)
+
 	items := make([]list.Item, len(generations))
 	for i, v := range generations {
-		items[i] = generationItem{
+		item := generationItem{
 			Generation: v,
 			Selected:   false,
 		}
+		if delta, ok := deltas[v.Number]; ok {
+			item.SizeDelta = &delta
+		}
+		items[i] = item
 	}
 
 	l := list.New(items, generationItemDelegate{}, 0, 0)
 
 	l.Title = "NixOS Generations"
 
-	l.Styles.Title = lipgloss.NewStyle().MarginLeft(2).Background(ansiRed).Foreground(ansiWhite)
+	l.Styles.Title = titleStyle
 	l.Styles.PaginationStyle = list.DefaultStyles().PaginationStyle.PaddingLeft(4)
 	l.Styles.HelpStyle = list.DefaultStyles().HelpStyle.PaddingLeft(4).PaddingBottom(1)
 	l.Styles.StatusBar = lipgloss.NewStyle().PaddingLeft(4).PaddingBottom(1).Foreground(ansiMagenta)
@@ -287,13 +506,21 @@ func newGenerationList(generations []generation.Generation) list.Model {
 				key.WithKeys("d"),
 				key.WithHelp("d", "delete selected generations"),
 			),
+			key.NewBinding(
+				key.WithKeys("c"),
+				key.WithHelp("c", "compare with active generation"),
+			),
+			key.NewBinding(
+				key.WithKeys("t"),
+				key.WithHelp("t", "edit description"),
+			),
 		}
 	}
 
 	return l
 }
 
-func generationUI(log *logger.Logger, profile string, generations []generation.Generation) error {
+func generationUI(log *logger.Logger, profile string, generations []generation.Generation, computeSizes bool) error {
 	closeLogFile, _ := cmdUtils.ConfigureBubbleTeaLogger("genlist")
 	defer closeLogFile()
 
@@ -301,6 +528,7 @@ func generationUI(log *logger.Logger, profile string, generations []generation.G
 
 	m := model{
 		list:    l,
+		log:     log,
 		profile: profile,
 	}
 
@@ -319,6 +547,8 @@ func generationUI(log *logger.Logger, profile string, generations []generation.G
 			err = runGenerationSwitchCmd(log, a.Generation, profile)
 		case deleteAction:
 			err = runGenerationDeleteCmd(log, a.Generations, profile)
+		case tagAction:
+			err = runGenerationTagCmd(log, a.Generation, a.Description, profile)
 		}
 
 		if err != nil {
@@ -336,6 +566,9 @@ func generationUI(log *logger.Logger, profile string, generations []generation.G
 		if err != nil {
 			return err
 		}
+		if computeSizes {
+			genUtils.LoadClosureSizes(log, profile, reloadedGenerations)
+		}
 		m.list = newGenerationList(reloadedGenerations)
 		m.action = nil
 		clearScreen()
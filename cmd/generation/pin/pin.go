@@ -0,0 +1,108 @@
+package pin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nix-community/nixos-cli/internal/cmd/opts"
+	"github.com/nix-community/nixos-cli/internal/cmd/utils"
+	"github.com/nix-community/nixos-cli/internal/generation"
+	"github.com/nix-community/nixos-cli/internal/logger"
+	"github.com/nix-community/nixos-cli/internal/settings"
+	"github.com/nix-community/nixos-cli/internal/system"
+	"github.com/nix-community/nixos-cli/internal/utils"
+)
+
+func GenerationPinCommand(genOpts *cmdOpts.GenerationOpts) *cobra.Command {
+	opts := cmdOpts.GenerationPinOpts{}
+
+	cmd := cobra.Command{
+		Use:   "pin [flags] {GEN}",
+		Short: "Protect a generation from deletion",
+		Long:  "Pin a generation so that it is never deleted by `nixos generation delete`.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.ExactArgs(1)(cmd, args); err != nil {
+				return err
+			}
+
+			gen, err := strconv.ParseInt(args[0], 10, 32)
+			if err != nil {
+				return fmt.Errorf("{GEN} must be integer value, got '%v'", args[0])
+			}
+			opts.Generation = uint(gen)
+
+			return nil
+		},
+		ValidArgsFunction: generation.CompleteGenerationNumber(&genOpts.ProfileName, 1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmdUtils.CommandErrorHandler(generationPinMain(cmd, genOpts, &opts))
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Reason, "reason", "r", "", "Record why this generation is pinned")
+
+	cmdUtils.SetHelpFlagText(&cmd)
+	cmd.SetHelpTemplate(cmd.HelpTemplate() + `
+Arguments:
+    [GEN]       Generation number
+`)
+
+	return &cmd
+}
+
+func generationPinMain(cmd *cobra.Command, genOpts *cmdOpts.GenerationOpts, opts *cmdOpts.GenerationPinOpts) error {
+	log := logger.FromContext(cmd.Context())
+	cfg := settings.FromContext(cmd.Context())
+	s := system.NewLocalSystem(log)
+
+	if os.Geteuid() != 0 {
+		err := utils.ExecAsRoot(cfg.RootCommand)
+		if err != nil {
+			log.Errorf("failed to re-exec command as root: %v", err)
+			return err
+		}
+	}
+
+	number := uint64(opts.Generation)
+	generationLink := fmt.Sprintf("%s-%d-link", generation.GetProfileDirectoryFromName(genOpts.ProfileName), number)
+
+	storePath, err := filepath.EvalSymlinks(generationLink)
+	if err != nil {
+		if os.IsNotExist(err) {
+			msg := fmt.Sprintf("generation %v not found", number)
+			log.Error(msg)
+			return fmt.Errorf("%v", msg)
+		}
+
+		log.Errorf("failed to access generation link: %v", err)
+		return err
+	}
+
+	existing, err := generation.ReadPin(genOpts.ProfileName, number, storePath)
+	if err != nil {
+		log.Warnf("failed to read existing pin: %v", err)
+	} else if existing != nil {
+		log.Infof("generation %v was already %v, replacing pin", number, existing.Describe())
+	}
+
+	pin := &generation.Pin{
+		StorePath: storePath,
+		Reason:    opts.Reason,
+		User:      utils.GetInvokingUser(),
+		Time:      time.Now(),
+	}
+
+	if err := generation.WritePin(s, genOpts.ProfileName, number, pin); err != nil {
+		log.Errorf("failed to pin generation %v: %v", number, err)
+		return err
+	}
+
+	log.Printf("Pinned generation %v.", number)
+
+	return nil
+}
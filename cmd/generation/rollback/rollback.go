@@ -14,6 +14,8 @@ import (
 	"github.com/nix-community/nixos-cli/internal/cmd/utils"
 	"github.com/nix-community/nixos-cli/internal/constants"
 	"github.com/nix-community/nixos-cli/internal/generation"
+	"github.com/nix-community/nixos-cli/internal/history"
+	"github.com/nix-community/nixos-cli/internal/hooks"
 	"github.com/nix-community/nixos-cli/internal/logger"
 	"github.com/nix-community/nixos-cli/internal/settings"
 	"github.com/nix-community/nixos-cli/internal/system"
@@ -35,6 +37,7 @@ func GenerationRollbackCommand(genOpts *cmdOpts.GenerationOpts) *cobra.Command {
 	cmd.Flags().BoolVarP(&opts.Dry, "dry", "d", false, "Show what would be activated, but do not activate")
 	cmd.Flags().StringVarP(&opts.Specialisation, "specialisation", "s", "", "Activate the specialisation with `name`")
 	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "Show verbose logging")
+	cmd.Flags().BoolVar(&opts.WaitForLock, "wait", false, "Wait for other operations in progress to finish")
 	cmd.Flags().BoolVarP(&opts.AlwaysConfirm, "yes", "y", false, "Automatically confirm activation")
 
 	_ = cmd.RegisterFlagCompletionFunc("specialisation", completeSpecialisationFlag(genOpts.ProfileName))
@@ -44,7 +47,7 @@ func GenerationRollbackCommand(genOpts *cmdOpts.GenerationOpts) *cobra.Command {
 	return &cmd
 }
 
-func generationRollbackMain(cmd *cobra.Command, genOpts *cmdOpts.GenerationOpts, opts *cmdOpts.GenerationRollbackOpts) error {
+func generationRollbackMain(cmd *cobra.Command, genOpts *cmdOpts.GenerationOpts, opts *cmdOpts.GenerationRollbackOpts) (err error) {
 	log := logger.FromContext(cmd.Context())
 	cfg := settings.FromContext(cmd.Context())
 	s := system.NewLocalSystem(log)
@@ -57,6 +60,14 @@ func generationRollbackMain(cmd *cobra.Command, genOpts *cmdOpts.GenerationOpts,
 		}
 	}
 
+	if !opts.Dry {
+		operationLock, err := cmdUtils.AcquireOperationLock(log, "/", opts.WaitForLock)
+		if err != nil {
+			return err
+		}
+		defer cmdUtils.ReleaseOperationLock(log, operationLock)
+	}
+
 	// While it is possible to use the `rollback` command, we still need
 	// to find the previous generation number ourselves in order to run
 	// `nvd` or `nix store diff-closures` properly.
@@ -97,7 +108,7 @@ func generationRollbackMain(cmd *cobra.Command, genOpts *cmdOpts.GenerationOpts,
 
 	specialisation := opts.Specialisation
 	if specialisation == "" {
-		defaultSpecialisation, err := activation.FindDefaultSpecialisationFromConfig(generationLink)
+		defaultSpecialisation, err := activation.FindDefaultSpecialisationFromConfig(s, generationLink)
 		if err != nil {
 			log.Warnf("unable to find default specialisation from config: %v", err)
 		} else {
@@ -105,18 +116,57 @@ func generationRollbackMain(cmd *cobra.Command, genOpts *cmdOpts.GenerationOpts,
 		}
 	}
 
-	if !activation.VerifySpecialisationExists(generationLink, specialisation) {
+	if !activation.VerifySpecialisationExists(s, generationLink, specialisation) {
 		log.Warnf("specialisation '%v' does not exist", specialisation)
 		log.Warn("using base configuration without specialisations")
 		specialisation = ""
 	}
 
-	previousGenNumber, err := activation.GetCurrentGenerationNumber(genOpts.ProfileName)
+	previousGenNumber, err := activation.GetCurrentGenerationNumber(s, genOpts.ProfileName)
 	if err != nil {
 		log.Errorf("%v", err)
 		return err
 	}
 
+	// Hooks are meant to run around real changes to the
+	// system, so they are skipped for dry runs entirely.
+	hookSettings := &cfg.Hooks
+	if opts.Dry {
+		hookSettings = &settings.HookSettings{}
+	}
+
+	hookCtx := &hooks.Context{
+		Command:       "generation rollback",
+		Profile:       genOpts.ProfileName,
+		OldGeneration: previousGenNumber,
+		NewGeneration: uint64(previousGen.Number),
+		StorePath:     generationLink,
+	}
+
+	if !opts.Dry {
+		historyRecord := history.NewRecord("generation rollback", genOpts.ProfileName)
+		historyRecord.Action = "switch"
+		historyRecord.PreviousGeneration = previousGenNumber
+		historyRecord.NewGeneration = uint64(previousGen.Number)
+		defer func() { cmdUtils.WriteHistoryRecord(log, s, historyRecord, err) }()
+	}
+
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		hookCtx.Error = err
+		if err := hooks.Run(s, hookSettings, hooks.StageOnFailure, hookCtx, opts.Verbose); err != nil {
+			log.Warnf("%v", err)
+		}
+	}()
+
+	if err := hooks.Run(s, hookSettings, hooks.StagePreActivate, hookCtx, opts.Verbose); err != nil {
+		log.Errorf("%v", err)
+		return err
+	}
+
 	if !opts.Dry {
 		log.Step("Setting system profile...")
 
@@ -169,6 +219,10 @@ func generationRollbackMain(cmd *cobra.Command, genOpts *cmdOpts.GenerationOpts,
 		return err
 	}
 
+	if err := hooks.Run(s, hookSettings, hooks.StagePostActivate, hookCtx, opts.Verbose); err != nil {
+		log.Warnf("%v", err)
+	}
+
 	return nil
 }
 
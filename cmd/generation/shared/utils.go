@@ -1,8 +1,11 @@
 package genUtils
 
 import (
+	"fmt"
+
 	"github.com/nix-community/nixos-cli/internal/generation"
 	"github.com/nix-community/nixos-cli/internal/logger"
+	"github.com/nix-community/nixos-cli/internal/system"
 )
 
 func LoadGenerations(log *logger.Logger, profileName string, reverse bool) ([]generation.Generation, error) {
@@ -28,3 +31,26 @@ func LoadGenerations(log *logger.Logger, profileName string, reverse bool) ([]ge
 
 	return generations, nil
 }
+
+// Compute the closure sizes of any generations that do not have
+// a cached closure size yet, and cache them if possible. This queries
+// every path in each closure, so only do this when asked to.
+func LoadClosureSizes(log *logger.Logger, profileName string, generations []generation.Generation) {
+	s := system.NewLocalSystem(log)
+	profileDirectory := generation.GetProfileDirectoryFromName(profileName)
+
+	for i := range generations {
+		g := &generations[i]
+		if g.ClosureSize != nil {
+			continue
+		}
+
+		generationLink := fmt.Sprintf("%s-%d-link", profileDirectory, g.Number)
+
+		size, err := generation.LoadClosureSize(s, profileName, g.Number, generationLink, false)
+		if err != nil {
+			log.Warnf("failed to compute closure size for generation %v: %v", g.Number, err)
+		}
+		g.ClosureSize = size
+	}
+}
@@ -0,0 +1,121 @@
+package tag
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nix-community/nixos-cli/internal/cmd/opts"
+	"github.com/nix-community/nixos-cli/internal/cmd/utils"
+	"github.com/nix-community/nixos-cli/internal/generation"
+	"github.com/nix-community/nixos-cli/internal/logger"
+	"github.com/nix-community/nixos-cli/internal/settings"
+	"github.com/nix-community/nixos-cli/internal/system"
+	"github.com/nix-community/nixos-cli/internal/utils"
+)
+
+func GenerationTagCommand(genOpts *cmdOpts.GenerationOpts) *cobra.Command {
+	opts := cmdOpts.GenerationTagOpts{}
+
+	cmd := cobra.Command{
+		Use:   "tag [flags] {GEN} [DESC]",
+		Short: "Change the description of a generation",
+		Long:  "Change the description of an existing generation, without rebuilding it.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.RangeArgs(1, 2)(cmd, args); err != nil {
+				return err
+			}
+
+			gen, err := strconv.ParseInt(args[0], 10, 32)
+			if err != nil {
+				return fmt.Errorf("{GEN} must be integer value, got '%v'", args[0])
+			}
+			opts.Generation = uint(gen)
+
+			if opts.Clear {
+				if len(args) > 1 {
+					return fmt.Errorf("[DESC] cannot be specified with --clear")
+				}
+				return nil
+			}
+
+			if len(args) < 2 {
+				return fmt.Errorf("[DESC] is required, unless --clear is specified")
+			}
+			opts.Description = args[1]
+
+			return nil
+		},
+		ValidArgsFunction: generation.CompleteGenerationNumber(&genOpts.ProfileName, 1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmdUtils.CommandErrorHandler(generationTagMain(cmd, genOpts, &opts))
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.Clear, "clear", "c", false, "Restore the description that the generation was built with")
+
+	cmdUtils.SetHelpFlagText(&cmd)
+	cmd.SetHelpTemplate(cmd.HelpTemplate() + `
+Arguments:
+    [GEN]       Generation number
+    [DESC]      New description of the generation
+`)
+
+	return &cmd
+}
+
+func generationTagMain(cmd *cobra.Command, genOpts *cmdOpts.GenerationOpts, opts *cmdOpts.GenerationTagOpts) error {
+	log := logger.FromContext(cmd.Context())
+	cfg := settings.FromContext(cmd.Context())
+	s := system.NewLocalSystem(log)
+
+	if os.Geteuid() != 0 {
+		err := utils.ExecAsRoot(cfg.RootCommand)
+		if err != nil {
+			log.Errorf("failed to re-exec command as root: %v", err)
+			return err
+		}
+	}
+
+	number := uint64(opts.Generation)
+	generationLink := fmt.Sprintf("%s-%d-link", generation.GetProfileDirectoryFromName(genOpts.ProfileName), number)
+
+	storePath, err := filepath.EvalSymlinks(generationLink)
+	if err != nil {
+		if os.IsNotExist(err) {
+			msg := fmt.Sprintf("generation %v not found", number)
+			log.Error(msg)
+			return fmt.Errorf("%v", msg)
+		}
+
+		log.Errorf("failed to access generation link: %v", err)
+		return err
+	}
+
+	if opts.Clear {
+		if err := generation.RemoveDescription(genOpts.ProfileName, number); err != nil {
+			log.Errorf("failed to clear description of generation %v: %v", number, err)
+			return err
+		}
+
+		log.Printf("Restored the original description of generation %v.", number)
+		return nil
+	}
+
+	overlay := &generation.DescriptionOverlay{
+		StorePath:   storePath,
+		Description: opts.Description,
+	}
+
+	if err := generation.WriteDescription(s, genOpts.ProfileName, number, overlay); err != nil {
+		log.Errorf("failed to set description of generation %v: %v", number, err)
+		return err
+	}
+
+	log.Printf("Set description of generation %v.", number)
+
+	return nil
+}
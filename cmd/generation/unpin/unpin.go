@@ -0,0 +1,89 @@
+package unpin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nix-community/nixos-cli/internal/cmd/opts"
+	"github.com/nix-community/nixos-cli/internal/cmd/utils"
+	"github.com/nix-community/nixos-cli/internal/generation"
+	"github.com/nix-community/nixos-cli/internal/logger"
+	"github.com/nix-community/nixos-cli/internal/settings"
+	"github.com/nix-community/nixos-cli/internal/utils"
+)
+
+func GenerationUnpinCommand(genOpts *cmdOpts.GenerationOpts) *cobra.Command {
+	opts := cmdOpts.GenerationUnpinOpts{}
+
+	cmd := cobra.Command{
+		Use:   "unpin {GEN}",
+		Short: "Allow a pinned generation to be deleted again",
+		Long:  "Remove the pin from a generation, so that it can be deleted by `nixos generation delete` again.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.ExactArgs(1)(cmd, args); err != nil {
+				return err
+			}
+
+			gen, err := strconv.ParseInt(args[0], 10, 32)
+			if err != nil {
+				return fmt.Errorf("{GEN} must be integer value, got '%v'", args[0])
+			}
+			opts.Generation = uint(gen)
+
+			return nil
+		},
+		ValidArgsFunction: generation.CompleteGenerationNumber(&genOpts.ProfileName, 1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmdUtils.CommandErrorHandler(generationUnpinMain(cmd, genOpts, &opts))
+		},
+	}
+
+	cmdUtils.SetHelpFlagText(&cmd)
+	cmd.SetHelpTemplate(cmd.HelpTemplate() + `
+Arguments:
+    [GEN]       Generation number
+`)
+
+	return &cmd
+}
+
+func generationUnpinMain(cmd *cobra.Command, genOpts *cmdOpts.GenerationOpts, opts *cmdOpts.GenerationUnpinOpts) error {
+	log := logger.FromContext(cmd.Context())
+	cfg := settings.FromContext(cmd.Context())
+
+	if os.Geteuid() != 0 {
+		err := utils.ExecAsRoot(cfg.RootCommand)
+		if err != nil {
+			log.Errorf("failed to re-exec command as root: %v", err)
+			return err
+		}
+	}
+
+	number := uint64(opts.Generation)
+	generationLink := fmt.Sprintf("%s-%d-link", generation.GetProfileDirectoryFromName(genOpts.ProfileName), number)
+
+	// Pins of generations that no longer exist can still be removed.
+	storePath, _ := filepath.EvalSymlinks(generationLink)
+
+	pin, err := generation.ReadPin(genOpts.ProfileName, number, storePath)
+	if err != nil {
+		log.Warnf("failed to read pin: %v", err)
+	} else if pin == nil && storePath != "" {
+		msg := fmt.Sprintf("generation %v is not pinned", number)
+		log.Error(msg)
+		return fmt.Errorf("%v", msg)
+	}
+
+	if err := generation.RemovePin(genOpts.ProfileName, number); err != nil {
+		log.Errorf("failed to unpin generation %v: %v", number, err)
+		return err
+	}
+
+	log.Printf("Unpinned generation %v.", number)
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/nix-community/nixos-cli/internal/cmd/opts"
+	"github.com/nix-community/nixos-cli/internal/cmd/utils"
+	"github.com/nix-community/nixos-cli/internal/history"
+	"github.com/nix-community/nixos-cli/internal/logger"
+	timeUtils "github.com/nix-community/nixos-cli/internal/time"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+func HistoryCommand() *cobra.Command {
+	opts := cmdOpts.HistoryOpts{}
+
+	cmd := cobra.Command{
+		Use:   "history",
+		Short: "Show history of system changes",
+		Long:  "Show the history of commands that applied, switched, rolled back, or deleted generations.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmdUtils.CommandErrorHandler(historyMain(cmd, &opts))
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.DisplayJson, "json", "j", false, "Display in JSON format")
+	cmd.Flags().IntVarP(&opts.Limit, "limit", "n", 0, "Only show the last `N` matching entries")
+	cmd.Flags().StringVar(&opts.Since, "since", "", "Only show entries newer than `PERIOD` (systemd.time span)")
+	cmd.Flags().StringVar(&opts.User, "user", "", "Only show entries invoked by `USER`")
+	cmd.Flags().StringVar(&opts.Command, "command", "", "Only show entries for `COMMAND` (e.g. \"apply\")")
+	cmd.Flags().StringVarP(&opts.Profile, "profile", "p", "", "Only show entries for the profile `NAME`")
+	cmd.Flags().BoolVar(&opts.OnlyFailed, "failed", false, "Only show entries for commands that failed")
+
+	cmdUtils.SetHelpFlagText(&cmd)
+
+	return &cmd
+}
+
+func historyMain(cmd *cobra.Command, opts *cmdOpts.HistoryOpts) error {
+	log := logger.FromContext(cmd.Context())
+
+	if opts.Limit < 0 {
+		msg := "--limit must be a positive number"
+		log.Error(msg)
+		return fmt.Errorf("%v", msg)
+	}
+
+	filter := history.Filter{
+		User:       opts.User,
+		Command:    opts.Command,
+		Profile:    opts.Profile,
+		OnlyFailed: opts.OnlyFailed,
+	}
+
+	if opts.Since != "" {
+		period, err := timeUtils.DurationFromTimeSpan(opts.Since)
+		if err != nil {
+			log.Errorf("invalid value for --since: %v", err)
+			return err
+		}
+		filter.Since = time.Now().Add(-period)
+	}
+
+	records, skipped, err := history.Read()
+	if err != nil {
+		log.Errorf("failed to read history: %v", err)
+		return err
+	}
+	if skipped > 0 {
+		log.Warnf("skipped %v malformed entries in %v", skipped, history.JournalFile)
+	}
+
+	matching := []history.Record{}
+	for i := range records {
+		if filter.Matches(&records[i]) {
+			matching = append(matching, records[i])
+		}
+	}
+
+	if opts.Limit > 0 && len(matching) > opts.Limit {
+		matching = matching[len(matching)-opts.Limit:]
+	}
+
+	if opts.DisplayJson {
+		bytes, _ := json.MarshalIndent(matching, "", "  ")
+		fmt.Printf("%v\n", string(bytes))
+		return nil
+	}
+
+	if len(matching) == 0 {
+		log.Info("no matching history entries found")
+		return nil
+	}
+
+	displayTable(matching)
+
+	return nil
+}
+
+func formatGenerations(r *history.Record) string {
+	if len(r.DeletedGenerations) > 0 {
+		return fmt.Sprintf("%v deleted", len(r.DeletedGenerations))
+	}
+
+	prev := "?"
+	if r.PreviousGeneration != 0 {
+		prev = fmt.Sprintf("%v", r.PreviousGeneration)
+	}
+
+	if r.NewGeneration == 0 {
+		return prev
+	}
+
+	return fmt.Sprintf("%v → %v", prev, r.NewGeneration)
+}
+
+func displayTable(records []history.Record) {
+	failedStyle := color.New(color.FgRed)
+
+	data := make([][]string, len(records))
+
+	for i := range records {
+		r := &records[i]
+
+		status := string(r.Status)
+		if r.Status == history.StatusFailure {
+			status = failedStyle.Sprint(status)
+		}
+
+		duration := (time.Duration(r.Duration) * time.Millisecond).Round(time.Second)
+
+		data[i] = []string{
+			r.Time.Local().Format(time.DateTime),
+			r.User,
+			r.Command,
+			r.Action,
+			r.Profile,
+			formatGenerations(r),
+			status,
+			duration.String(),
+			r.Tag,
+		}
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Time", "User", "Command", "Action", "Profile", "Generation", "Status", "Duration", "Tag"})
+	table.SetAutoWrapText(false)
+	table.SetAutoFormatHeaders(true)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetCenterSeparator("")
+	table.SetColumnSeparator("")
+	table.SetRowSeparator("")
+	table.SetHeaderLine(false)
+	table.SetBorder(false)
+	table.SetTablePadding("\t")
+	table.SetNoWhiteSpace(true)
+	table.AppendBulk(data)
+	table.Render()
+}
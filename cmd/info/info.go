@@ -3,6 +3,7 @@ package info
 import (
 	"encoding/json"
 	"fmt"
+	"path/filepath"
 	"strings"
 
 	"github.com/fatih/color"
@@ -12,6 +13,8 @@ import (
 	"github.com/nix-community/nixos-cli/internal/constants"
 	"github.com/nix-community/nixos-cli/internal/generation"
 	"github.com/nix-community/nixos-cli/internal/logger"
+	"github.com/nix-community/nixos-cli/internal/system"
+	"github.com/nix-community/nixos-cli/internal/utils"
 	"github.com/spf13/cobra"
 )
 
@@ -44,15 +47,16 @@ const (
 
 func infoMain(cmd *cobra.Command, opts *cmdOpts.InfoOpts) error {
 	log := logger.FromContext(cmd.Context())
+	s := system.NewLocalSystem(log)
 
 	// Only support the `system` profile for now.
-	currentGenNumber, err := activation.GetCurrentGenerationNumber("system")
+	currentGenNumber, err := activation.GetCurrentGenerationNumber(s, "system")
 	if err != nil {
 		log.Warnf("failed to determine current generation number: %v", err)
 		return err
 	}
 
-	currentGen, err := generation.GenerationFromDirectory(constants.CurrentSystem, currentGenNumber)
+	currentGen, err := generation.GenerationFromDirectory(constants.CurrentSystem, "system", currentGenNumber)
 	if err != nil {
 		log.Warnf("failed to collect generations: %v", err)
 		return err
@@ -60,8 +64,55 @@ func infoMain(cmd *cobra.Command, opts *cmdOpts.InfoOpts) error {
 	currentGen.Number = currentGenNumber
 	currentGen.IsCurrent = true
 
+	// The current system may be a specialisation, so metadata is
+	// looked up for the generation itself to match what was recorded.
+	generationLink := fmt.Sprintf("%s-%d-link", generation.GetProfileDirectoryFromName("system"), currentGenNumber)
+
+	if storePath, err := filepath.EvalSymlinks(generationLink); err != nil {
+		log.Warnf("failed to resolve generation link: %v", err)
+	} else {
+		healthCheck, err := generation.ReadHealthCheckResult("system", currentGenNumber, storePath)
+		if err != nil {
+			log.Warnf("failed to read health check result: %v", err)
+		}
+		currentGen.HealthCheck = healthCheck
+
+		gitProvenance, err := generation.ReadGitProvenance("system", currentGenNumber, storePath)
+		if err != nil {
+			log.Warnf("failed to read git provenance: %v", err)
+		}
+		currentGen.GitProvenance = gitProvenance
+	}
+
+	closureSize, err := generation.LoadClosureSize(s, "system", currentGenNumber, generationLink, false)
+	if err != nil {
+		log.Warnf("failed to compute closure size: %v", err)
+	}
+	currentGen.ClosureSize = closureSize
+
+	currentSystem, err := system.Readlink(s, constants.CurrentSystem)
+	if err != nil {
+		log.Warnf("failed to resolve current system: %v", err)
+		currentSystem = constants.CurrentSystem
+	}
+
+	rebootReasons, err := activation.CheckRebootRequired(s, currentSystem, false)
+	if err != nil {
+		log.Warnf("failed to check if a reboot is required: %v", err)
+	}
+
 	if opts.DisplayJson {
-		bytes, _ := json.MarshalIndent(currentGen, "", "  ")
+		output := struct {
+			*generation.Generation
+			RebootRequired bool                      `json:"reboot_required"`
+			RebootReasons  []activation.RebootReason `json:"reboot_reasons"`
+		}{
+			Generation:     currentGen,
+			RebootRequired: len(rebootReasons) > 0,
+			RebootReasons:  rebootReasons,
+		}
+
+		bytes, _ := json.MarshalIndent(output, "", "  ")
 		fmt.Printf("%v\n", string(bytes))
 		return nil
 	}
@@ -71,14 +122,14 @@ func infoMain(cmd *cobra.Command, opts *cmdOpts.InfoOpts) error {
 		return nil
 	}
 
-	prettyPrintGenInfo(currentGen)
+	prettyPrintGenInfo(currentGen, rebootReasons)
 
 	return nil
 }
 
 var titleColor = color.New(color.Bold, color.Italic)
 
-func prettyPrintGenInfo(g *generation.Generation) {
+func prettyPrintGenInfo(g *generation.Generation, rebootReasons []activation.RebootReason) {
 	version := g.NixosVersion
 	if version == "" {
 		version = "NixOS (unknown version)"
@@ -111,6 +162,21 @@ func prettyPrintGenInfo(g *generation.Generation) {
 	}
 	fmt.Println(configVersion)
 
+	printKey("Git Commit")
+	gitCommit := color.New(color.Italic).Sprint("(unknown)")
+	if g.GitProvenance != nil {
+		gitCommit = g.GitProvenance.Describe()
+		if g.GitProvenance.Dirty {
+			gitCommit = color.YellowString("%v", gitCommit)
+		}
+	}
+	fmt.Println(gitCommit)
+
+	if g.GitProvenance != nil && g.GitProvenance.RemoteURL != "" {
+		printKey("Git Remote")
+		fmt.Println(g.GitProvenance.RemoteURL)
+	}
+
 	printKey("Kernel Version")
 	kernelVersion := g.KernelVersion
 	if kernelVersion == "" {
@@ -124,12 +190,42 @@ func prettyPrintGenInfo(g *generation.Generation) {
 		specialisations = color.New(color.Italic).Sprint("(none)")
 	}
 	fmt.Println(specialisations)
+
+	printKey("Closure Size")
+	closureSize := color.New(color.Italic).Sprint("(unknown)")
+	if g.ClosureSize != nil {
+		closureSize = fmt.Sprintf("%v, %v paths", utils.FormatBytes(g.ClosureSize.NarSize), g.ClosureSize.PathCount)
+	}
+	fmt.Println(closureSize)
+
+	printKey("Health Check")
+	healthCheck := color.New(color.Italic).Sprint("(not run)")
+	if g.HealthCheck != nil {
+		if g.HealthCheck.Passed {
+			healthCheck = color.GreenString("passed")
+		} else {
+			healthCheck = color.RedString("failed (%v)", strings.Join(g.HealthCheck.Failures, "; "))
+		}
+	}
+	fmt.Println(healthCheck)
+
+	printKey("Reboot Required")
+	if len(rebootReasons) == 0 {
+		fmt.Println("no")
+	} else {
+		reasons := make([]string, 0, len(rebootReasons))
+		for _, reason := range rebootReasons {
+			reasons = append(reasons, reason.String())
+		}
+		fmt.Println(color.YellowString("yes (%v)", strings.Join(reasons, "; ")))
+	}
 }
 
 func getKeyMaxLength() int {
 	strings := []string{
 		"Generation", "Description", "NixOS Version", "Nixpkgs Version",
-		"Config Version", "Kernel Version", "Specialisations",
+		"Config Version", "Git Commit", "Git Remote", "Kernel Version", "Specialisations",
+		"Closure Size", "Health Check", "Reboot Required",
 	}
 
 	maxLength := 0
@@ -0,0 +1,231 @@
+package inputs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/nix-community/nixos-cli/internal/cmd/opts"
+	"github.com/nix-community/nixos-cli/internal/cmd/utils"
+	"github.com/nix-community/nixos-cli/internal/configuration"
+	"github.com/nix-community/nixos-cli/internal/flake"
+	"github.com/nix-community/nixos-cli/internal/logger"
+	"github.com/nix-community/nixos-cli/internal/settings"
+	"github.com/nix-community/nixos-cli/internal/system"
+	timeUtils "github.com/nix-community/nixos-cli/internal/time"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+func InputsCommand() *cobra.Command {
+	opts := cmdOpts.InputsOpts{}
+
+	cmd := cobra.Command{
+		Use:   "inputs [flags] [FLAKE-REF]",
+		Short: "Show the locked inputs of a flake",
+		Long:  "Show the locked inputs of the configuration flake, and check how old they are.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.MaximumNArgs(1)(cmd, args); err != nil {
+				return err
+			}
+			if len(args) > 0 {
+				opts.FlakeRef = args[0]
+			}
+			return nil
+		},
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if opts.MaxAge != "" {
+				if _, err := timeUtils.DurationFromTimeSpan(opts.MaxAge); err != nil {
+					return fmt.Errorf("invalid value for --max-age: %v", err.Error())
+				}
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmdUtils.CommandErrorHandler(inputsMain(cmd, &opts))
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.DisplayJson, "json", "j", false, "Display in JSON format")
+	cmd.Flags().StringSliceVarP(&opts.Inputs, "input", "i", nil, "Only show the input with `name` (can be specified multiple times)")
+	cmd.Flags().StringVar(&opts.MaxAge, "max-age", "", "Fail if any direct input was last modified longer than `period` ago")
+	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "Show verbose logging")
+
+	cmd.SetHelpTemplate(cmd.HelpTemplate() + `
+Arguments:
+    [FLAKE-REF]  Flake ref to show inputs of (default: $NIXOS_CONFIG)
+`)
+
+	cmdUtils.SetHelpFlagText(&cmd)
+
+	return &cmd
+}
+
+type inputInfo struct {
+	flake.Input
+	LastModified *time.Time `json:"last_modified,omitempty"`
+	// Only set when --max-age is specified
+	Stale bool `json:"stale"`
+}
+
+func inputsMain(cmd *cobra.Command, opts *cmdOpts.InputsOpts) error {
+	log := logger.FromContext(cmd.Context())
+	cfg := settings.FromContext(cmd.Context())
+	s := system.NewLocalSystem(log)
+
+	var flakeRef *configuration.FlakeRef
+	if opts.FlakeRef != "" {
+		flakeRef = configuration.FlakeRefFromString(opts.FlakeRef)
+	} else {
+		f, err := configuration.FlakeRefFromEnv(cfg.ConfigLocation)
+		if err != nil {
+			log.Errorf("failed to find configuration: %v", err)
+			return err
+		}
+		flakeRef = f
+	}
+
+	if opts.Verbose {
+		log.Infof("reading lock file for flake %v", flakeRef.URI)
+	}
+
+	lock, err := flake.LoadLockFile(s, flakeRef.URI)
+	if err != nil {
+		log.Errorf("failed to load lock file: %v", err)
+		return err
+	}
+
+	var maxAge time.Duration
+	if opts.MaxAge != "" {
+		// This is validated during argument parsing, so no need to check for errors.
+		maxAge, _ = timeUtils.DurationFromTimeSpan(opts.MaxAge)
+	}
+
+	now := time.Now()
+
+	inputs := []inputInfo{}
+	for _, input := range lock.Inputs() {
+		if len(opts.Inputs) > 0 && !slices.Contains(opts.Inputs, input.Path) {
+			continue
+		}
+
+		info := inputInfo{Input: input}
+
+		if input.Locked != nil {
+			if lastModified := input.Locked.LastModifiedTime(); !lastModified.IsZero() {
+				info.LastModified = &lastModified
+
+				// Followed and transitive inputs are not checked, since
+				// they are not directly controlled by this flake.
+				if maxAge > 0 && input.IsDirect() && input.Follows == "" {
+					info.Stale = now.Sub(lastModified) > maxAge
+				}
+			}
+		}
+
+		inputs = append(inputs, info)
+	}
+
+	for _, name := range opts.Inputs {
+		if !slices.ContainsFunc(inputs, func(i inputInfo) bool { return i.Path == name }) {
+			msg := fmt.Sprintf("input '%v' not found in lock file", name)
+			log.Error(msg)
+			return fmt.Errorf("%v", msg)
+		}
+	}
+
+	if opts.DisplayJson {
+		bytes, _ := json.MarshalIndent(inputs, "", "  ")
+		fmt.Printf("%v\n", string(bytes))
+	} else {
+		displayTable(inputs, now)
+	}
+
+	staleCount := 0
+	for _, input := range inputs {
+		if input.Stale {
+			staleCount++
+			if !opts.DisplayJson {
+				log.Warnf("input '%v' was last modified %v ago", input.Path, formatAge(now.Sub(*input.LastModified)))
+			}
+		}
+	}
+
+	if staleCount > 0 {
+		msg := fmt.Sprintf("%d inputs are older than %v", staleCount, opts.MaxAge)
+		if staleCount == 1 {
+			msg = fmt.Sprintf("1 input is older than %v", opts.MaxAge)
+		}
+		log.Error(msg)
+		return fmt.Errorf("%v", msg)
+	}
+
+	return nil
+}
+
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%d minutes", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%d hours", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%d days", int(d.Hours()/24))
+	}
+}
+
+func displayTable(inputs []inputInfo, now time.Time) {
+	staleStyle := color.New(color.FgRed)
+
+	data := make([][]string, len(inputs))
+
+	for i, input := range inputs {
+		var inputType, rev, lastModified, age string
+
+		if input.Locked != nil && input.Follows == "" {
+			inputType = input.Locked.Type
+			rev = input.Locked.ShortRev()
+		}
+
+		if input.LastModified != nil && input.Follows == "" {
+			lastModified = input.LastModified.Local().Format(time.DateOnly)
+			age = formatAge(now.Sub(*input.LastModified))
+			if input.Stale {
+				age = staleStyle.Sprint(age)
+			}
+		}
+
+		follows := ""
+		if input.Follows != "" {
+			follows = input.Follows
+		}
+
+		data[i] = []string{
+			input.Path,
+			inputType,
+			rev,
+			lastModified,
+			age,
+			follows,
+		}
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Input", "Type", "Revision", "Last Modified", "Age", "Follows"})
+	table.SetAutoWrapText(false)
+	table.SetAutoFormatHeaders(true)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetCenterSeparator("")
+	table.SetColumnSeparator("")
+	table.SetRowSeparator("")
+	table.SetHeaderLine(false)
+	table.SetBorder(false)
+	table.SetTablePadding("\t")
+	table.SetNoWhiteSpace(true)
+	table.AppendBulk(data)
+	table.Render()
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/nix-community/nixos-cli/internal/configuration"
 	"github.com/nix-community/nixos-cli/internal/constants"
 	"github.com/nix-community/nixos-cli/internal/logger"
+	"github.com/nix-community/nixos-cli/internal/settings"
 	"github.com/nix-community/nixos-cli/internal/system"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
@@ -64,6 +65,7 @@ func InstallCommand() *cobra.Command {
 		},
 	}
 
+	cmd.Flags().StringVar(&opts.BuildHost, "build-host", "", "Realise the system on a remote `host` over SSH")
 	cmd.Flags().StringVarP(&opts.Channel, "channel", "c", "", "Use derivation at `path` as the 'nixos' channel to copy")
 	cmd.Flags().BoolVar(&opts.NoBootloader, "no-bootloader", false, "Do not install bootloader on device")
 	cmd.Flags().BoolVar(&opts.NoChannelCopy, "no-channel-copy", false, "Do not copy over a NixOS channel")
@@ -71,6 +73,7 @@ func InstallCommand() *cobra.Command {
 	cmd.Flags().StringVarP(&opts.Root, "root", "r", "/mnt", "Treat `dir` as the root for installation")
 	cmd.Flags().StringVarP(&opts.SystemClosure, "system", "s", "", "Install system from system closure at `path`")
 	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "Show verbose logging")
+	cmd.Flags().BoolVar(&opts.WaitForLock, "wait", false, "Wait for other operations in progress to finish")
 
 	nixopts.AddQuietNixOption(&cmd, &opts.NixOptions.Quiet)
 	nixopts.AddPrintBuildLogsNixOption(&cmd, &opts.NixOptions.PrintBuildLogs)
@@ -328,6 +331,7 @@ func setRootPassword(s system.CommandRunner, mountpoint string, verbose bool) er
 
 func installMain(cmd *cobra.Command, opts *cmdOpts.InstallOpts) error {
 	log := logger.FromContext(cmd.Context())
+	cfg := settings.FromContext(cmd.Context())
 	s := system.NewLocalSystem(log)
 
 	if !s.IsNixOS() {
@@ -345,6 +349,13 @@ func installMain(cmd *cobra.Command, opts *cmdOpts.InstallOpts) error {
 	if err := validateMountpoint(log, mountpoint); err != nil {
 		return err
 	}
+
+	operationLock, err := cmdUtils.AcquireOperationLock(log, mountpoint, opts.WaitForLock)
+	if err != nil {
+		return err
+	}
+	defer cmdUtils.ReleaseOperationLock(log, operationLock)
+
 	tmpDirname, err := os.MkdirTemp(mountpoint, "system")
 	if err != nil {
 		log.Errorf("failed to create temporary directory: %v", err)
@@ -409,11 +420,23 @@ func installMain(cmd *cobra.Command, opts *cmdOpts.InstallOpts) error {
 		opts.NixOptions.Includes = append(opts.NixOptions.Includes, fmt.Sprintf("nixos-config=%s", c.ConfigDirname))
 	}
 	systemBuildOptions := configuration.SystemBuildOptions{
-		Verbose:   opts.Verbose,
-		CmdFlags:  cmd.Flags(),
-		NixOpts:   opts.NixOptions,
-		Env:       envMap,
-		ExtraArgs: []string{"--extra-substituters", defaultExtraSubstituters},
+		Verbose:      opts.Verbose,
+		ShowProgress: cfg.Apply.ShowProgress && log.OutputFormat() == logger.OutputFormatText,
+		CmdFlags:     cmd.Flags(),
+		NixOpts:      opts.NixOptions,
+		Env:          envMap,
+		ExtraArgs:    []string{"--extra-substituters", defaultExtraSubstituters},
+	}
+
+	if opts.BuildHost != "" {
+		buildHost, err := system.NewSSHSystem(log, opts.BuildHost, &system.SSHSystemOptions{})
+		if err != nil {
+			log.Errorf("failed to initialize connection to build host: %v", err)
+			return err
+		}
+		defer buildHost.Close()
+
+		systemBuildOptions.BuildHost = buildHost
 	}
 
 	log.Step("Building system...")
@@ -8,13 +8,14 @@ import (
 
 	"github.com/nix-community/nixos-cli/internal/configuration"
 	"github.com/nix-community/nixos-cli/internal/constants"
+	"github.com/nix-community/nixos-cli/internal/flake"
 	"github.com/nix-community/nixos-cli/internal/system"
 )
 
 const (
 	flakeOptionsCacheExpr = `let
-  flake = builtins.getFlake "%s";
-  system = flake.nixosConfigurations."%s";
+  flake = builtins.getFlake %s;
+  system = flake.%s;
   inherit (system) pkgs;
   inherit (pkgs) lib;
 
@@ -25,9 +26,9 @@ const (
 in
   jsonFormat.generate "options-cache.json" optionsList
 `
-	legacyOptionsCacheExpr = `let
-  system = import <nixpkgs/nixos> {};
-  inherit (system) pkgs;
+	nixFileOptionsCacheExpr = `let
+  system = %s;
+  pkgs = system.pkgs or system.config._module.args.pkgs;
   inherit (pkgs) lib;
 
   optionsList' = lib.optionAttrSetToDocList system.options;
@@ -46,9 +47,18 @@ func buildOptionCache(s system.CommandRunner, cfg configuration.Configuration) (
 
 	switch v := cfg.(type) {
 	case *configuration.FlakeRef:
-		argv = append(argv, fmt.Sprintf(flakeOptionsCacheExpr, v.URI, v.System))
+		// builtins.getFlake only accepts absolute paths.
+		if err := v.MakeAbsolute(); err != nil {
+			return "", err
+		}
+		argv = append(argv, fmt.Sprintf(flakeOptionsCacheExpr, flake.QuoteString(v.URI), v.SystemAttribute()))
 	case *configuration.LegacyConfiguration:
-		argv = append(argv, legacyOptionsCacheExpr)
+		argv = append(argv, fmt.Sprintf(nixFileOptionsCacheExpr, "import <nixpkgs/nixos> {}"))
+		for _, v := range v.Includes {
+			argv = append(argv, "-I", v)
+		}
+	case *configuration.FileConfiguration:
+		argv = append(argv, fmt.Sprintf(nixFileOptionsCacheExpr, v.SystemExpression()))
 		for _, v := range v.Includes {
 			argv = append(argv, "-I", v)
 		}
@@ -51,6 +51,8 @@ func OptionCommand() *cobra.Command {
 		},
 	}
 
+	cmd.Flags().StringVar(&opts.Attr, "attr", "", "Load options from the configuration at attribute `path` in --file")
+	cmd.Flags().StringVar(&opts.File, "file", "", "Load options from the configuration in a Nix `file` or directory")
 	cmd.Flags().BoolVarP(&opts.DisplayJson, "json", "j", false, "Output information in JSON format")
 	cmd.Flags().BoolVarP(&opts.Interactive, "interactive", "i", false, "Show interactive search TUI for options")
 	cmd.Flags().BoolVarP(&opts.NoUseCache, "no-cache", "n", false, "Do not attempt to use prebuilt option cache")
@@ -64,6 +66,10 @@ func OptionCommand() *cobra.Command {
 	nixopts.AddIncludesNixOption(&cmd, &opts.NixPathIncludes)
 
 	cmd.MarkFlagsMutuallyExclusive("json", "interactive", "value-only")
+	if buildOpts.Flake == "true" {
+		cmd.MarkFlagsMutuallyExclusive("flake", "file")
+		cmd.MarkFlagsMutuallyExclusive("flake", "attr")
+	}
 
 	cmdUtils.SetHelpFlagText(&cmd)
 	cmd.SetHelpTemplate(cmd.HelpTemplate() + `
@@ -97,6 +103,13 @@ func optionMain(cmd *cobra.Command, opts *cmdOpts.OptionOpts) error {
 			log.Errorf("failed to infer hostname: %v", err)
 			return err
 		}
+	} else if opts.File != "" || opts.Attr != "" {
+		c, err := configuration.FileConfigurationFromPath(opts.File, opts.Attr, opts.NixPathIncludes)
+		if err != nil {
+			log.Errorf("failed to find configuration: %v", err)
+			return err
+		}
+		nixosConfig = c
 	} else {
 		c, err := configuration.FindConfiguration(log, cfg, opts.NixPathIncludes, false)
 		if err != nil {
@@ -153,7 +166,7 @@ func optionMain(cmd *cobra.Command, opts *cmdOpts.OptionOpts) error {
 	}
 
 	var evaluator option.EvaluatorFunc = func(optionName string) (string, error) {
-		value, err := nixosConfig.EvalAttribute(optionName)
+		value, err := nixosConfig.EvalAttribute(optionName, nil)
 		realValue := ""
 		if value != nil {
 			realValue = *value
@@ -11,6 +11,7 @@ import (
 	"github.com/nix-community/nixos-cli/internal/cmd/opts"
 	"github.com/nix-community/nixos-cli/internal/cmd/utils"
 	"github.com/nix-community/nixos-cli/internal/configuration"
+	"github.com/nix-community/nixos-cli/internal/flake"
 	"github.com/nix-community/nixos-cli/internal/logger"
 	"github.com/nix-community/nixos-cli/internal/settings"
 	"github.com/spf13/cobra"
@@ -42,6 +43,12 @@ func ReplCommand() *cobra.Command {
 			}
 			return cobra.NoArgs(cmd, args)
 		},
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if (opts.File != "" || opts.Attr != "") && opts.FlakeRef != "" {
+				return fmt.Errorf("--file and --attr cannot be used with a flake ref")
+			}
+			return nil
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return cmdUtils.CommandErrorHandler(replMain(cmd, &opts))
 		},
@@ -49,6 +56,9 @@ func ReplCommand() *cobra.Command {
 
 	cmdUtils.SetHelpFlagText(&cmd)
 
+	cmd.Flags().StringVar(&opts.Attr, "attr", "", "Load the configuration at attribute `path` in --file")
+	cmd.Flags().StringVar(&opts.File, "file", "", "Load the configuration from a Nix `file` or directory")
+
 	nixopts.AddIncludesNixOption(&cmd, &opts.NixPathIncludes)
 
 	if buildOpts.Flake == "true" {
@@ -63,8 +73,8 @@ Arguments:
 
 const (
 	flakeReplExpr = `let
-  flake = builtins.getFlake "%s";
-  system = flake.nixosConfigurations."%s";
+  flake = builtins.getFlake %s;
+  system = flake.%s;
   motd = ''
 %s'';
   scope =
@@ -88,6 +98,23 @@ in
  builtins.seq system builtins.trace motd system
 `
 
+	fileReplExpr = `let
+  system = %s;
+  motd = ''
+%s'';
+  scope =
+    if system ? _module then
+      system._module.args
+      // system._module.specialArgs
+      // {
+        inherit (system) config options;
+      }
+    else
+      system;
+in
+  builtins.seq scope builtins.trace motd scope
+`
+
 	flakeMotdTemplate = `This Nix REPL has been automatically loaded with a NixOS configuration.
 
 Configuration :: %s
@@ -122,6 +149,24 @@ Tab completion can be used to browse around all of these attributes.
 Use the %s command to reload the configuration after it has
 been changed.
 
+Use %s to see all available repl commands.
+`
+
+	fileMotdTemplate = `This Nix REPL has been automatically loaded with a NixOS configuration.
+
+Configuration :: %s
+
+The following values have been added to the toplevel scope:
+  - %s :: Configured option values
+  - %s :: Option data and associated metadata
+  - %s :: %s package set
+  - Any additional arguments in %s and %s
+
+Tab completion can be used to browse around all of these attributes.
+
+Use the %s command to reload the configuration after it has
+been changed.
+
 Use %s to see all available repl commands.
 `
 )
@@ -133,6 +178,13 @@ func replMain(cmd *cobra.Command, opts *cmdOpts.ReplOpts) error {
 	var nixConfig configuration.Configuration
 	if opts.FlakeRef != "" {
 		nixConfig = configuration.FlakeRefFromString(opts.FlakeRef)
+	} else if opts.File != "" || opts.Attr != "" {
+		c, err := configuration.FileConfigurationFromPath(opts.File, opts.Attr, opts.NixPathIncludes)
+		if err != nil {
+			log.Errorf("failed to find configuration: %v", err)
+			return err
+		}
+		nixConfig = c
 	} else {
 		c, err := configuration.FindConfiguration(log, cfg, opts.NixPathIncludes, false)
 		if err != nil {
@@ -155,6 +207,12 @@ func replMain(cmd *cobra.Command, opts *cmdOpts.ReplOpts) error {
 			log.Errorf("failed to exec nix repl: %v", err)
 			return err
 		}
+	case *configuration.FileConfiguration:
+		err := execFileRepl(c)
+		if err != nil {
+			log.Errorf("failed to exec nix repl: %v", err)
+			return err
+		}
 	}
 
 	return nil
@@ -181,9 +239,32 @@ func execLegacyRepl(includes []string, impure bool) error {
 	return err
 }
 
+func execFileRepl(c *configuration.FileConfiguration) error {
+	motd := formatFileMotd(c)
+	expr := fmt.Sprintf(fileReplExpr, c.SystemExpression(), motd)
+
+	argv := []string{"nix", "repl", "--expr", expr}
+	for _, v := range c.Includes {
+		argv = append(argv, "-I", v)
+	}
+
+	nixCommandPath, err := exec.LookPath("nix")
+	if err != nil {
+		return err
+	}
+
+	err = syscall.Exec(nixCommandPath, argv, os.Environ())
+	return err
+}
+
 func execFlakeRepl(flakeRef *configuration.FlakeRef) error {
+	// builtins.getFlake only accepts absolute paths.
+	if err := flakeRef.MakeAbsolute(); err != nil {
+		return err
+	}
+
 	motd := formatFlakeMotd(flakeRef)
-	expr := fmt.Sprintf(flakeReplExpr, flakeRef.URI, flakeRef.System, motd)
+	expr := fmt.Sprintf(flakeReplExpr, flake.QuoteString(flakeRef.URI), flakeRef.SystemAttribute(), motd)
 
 	argv := []string{"nix", "repl", "--expr", expr}
 
@@ -197,10 +278,8 @@ func execFlakeRepl(flakeRef *configuration.FlakeRef) error {
 }
 
 func formatFlakeMotd(ref *configuration.FlakeRef) string {
-	flakeRef := fmt.Sprintf("%s#%s", ref.URI, ref.System)
-
 	return fmt.Sprintf(flakeMotdTemplate,
-		color.CyanString(flakeRef),
+		color.CyanString(ref.String()),
 		color.MagentaString("flake"),
 		color.MagentaString("config"),
 		color.MagentaString("options"),
@@ -222,3 +301,15 @@ func formatLegacyMotd() string {
 		color.MagentaString(":?"),
 	)
 }
+
+func formatFileMotd(c *configuration.FileConfiguration) string {
+	return fmt.Sprintf(fileMotdTemplate,
+		color.CyanString(c.String()),
+		color.MagentaString("config"),
+		color.MagentaString("options"),
+		color.MagentaString("pkgs"), color.CyanString("nixpkgs"),
+		color.MagentaString("_module.args"), color.MagentaString("_module.specialArgs"),
+		color.MagentaString(":r"),
+		color.MagentaString(":?"),
+	)
+}
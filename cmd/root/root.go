@@ -13,18 +13,23 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/nix-community/nixos-cli/internal/cmd/opts"
+	"github.com/nix-community/nixos-cli/internal/cmd/utils"
 
 	applyCmd "github.com/nix-community/nixos-cli/cmd/apply"
 	completionCmd "github.com/nix-community/nixos-cli/cmd/completion"
 	enterCmd "github.com/nix-community/nixos-cli/cmd/enter"
+	evalCmd "github.com/nix-community/nixos-cli/cmd/eval"
 	featuresCmd "github.com/nix-community/nixos-cli/cmd/features"
 	generationCmd "github.com/nix-community/nixos-cli/cmd/generation"
+	historyCmd "github.com/nix-community/nixos-cli/cmd/history"
 	infoCmd "github.com/nix-community/nixos-cli/cmd/info"
 	initCmd "github.com/nix-community/nixos-cli/cmd/init"
+	inputsCmd "github.com/nix-community/nixos-cli/cmd/inputs"
 	installCmd "github.com/nix-community/nixos-cli/cmd/install"
 	manualCmd "github.com/nix-community/nixos-cli/cmd/manual"
 	optionCmd "github.com/nix-community/nixos-cli/cmd/option"
 	replCmd "github.com/nix-community/nixos-cli/cmd/repl"
+	specialisationCmd "github.com/nix-community/nixos-cli/cmd/specialisation"
 )
 
 const helpTemplate = `Usage:{{if .Runnable}}
@@ -93,11 +98,24 @@ func mainCommand() (*cobra.Command, error) {
 				}
 			}
 
+			outputFormat, err := logger.ParseOutputFormat(opts.OutputFormat)
+			if err != nil {
+				return err
+			}
+			log.SetOutputFormat(outputFormat)
+
 			errs := cfg.Validate()
 			for _, err := range errs {
 				log.Warn(err.Error())
 			}
 
+			if outputFormat == logger.OutputFormatJSON {
+				log.Event("start", map[string]any{
+					"command": cmd.CommandPath(),
+					"args":    args,
+				})
+			}
+
 			// Now that we have the real color settings from parsing
 			// the configuration and command-line arguments, set it.
 			//
@@ -115,8 +133,13 @@ func mainCommand() (*cobra.Command, error) {
 
 			return nil
 		},
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			log.Finish(nil)
+		},
 	}
 
+	cmdUtils.OnCommandError(log.Finish)
+
 	cmd.SetContext(cmdCtx)
 
 	cmd.SetHelpCommand(&cobra.Command{Hidden: true})
@@ -130,20 +153,29 @@ func mainCommand() (*cobra.Command, error) {
 
 	cmd.PersistentFlags().BoolVar(&opts.ColorAlways, "color-always", false, "Always color output when possible")
 	cmd.PersistentFlags().StringToStringVar(&opts.ConfigValues, "config", map[string]string{}, "Set a configuration `key=value`")
+	cmd.PersistentFlags().StringVar(&opts.OutputFormat, "output-format", string(logger.OutputFormatText), "Format of log output, either 'text' or machine-readable 'json'")
 
 	_ = cmd.RegisterFlagCompletionFunc("config", settings.CompleteConfigFlag)
+	_ = cmd.RegisterFlagCompletionFunc("output-format", cobra.FixedCompletions([]string{"text", "json"}, cobra.ShellCompDirectiveNoFileComp))
 
 	cmd.AddCommand(applyCmd.ApplyCommand(cfg))
+	cmd.AddCommand(applyCmd.AutoUpgradeCommand())
 	cmd.AddCommand(completionCmd.CompletionCommand())
 	cmd.AddCommand(enterCmd.EnterCommand())
+	cmd.AddCommand(evalCmd.EvalCommand())
 	cmd.AddCommand(featuresCmd.FeatureCommand())
 	cmd.AddCommand(generationCmd.GenerationCommand())
+	cmd.AddCommand(historyCmd.HistoryCommand())
 	cmd.AddCommand(infoCmd.InfoCommand())
 	cmd.AddCommand(initCmd.InitCommand())
+	if buildVars.Flake == "true" {
+		cmd.AddCommand(inputsCmd.InputsCommand())
+	}
 	cmd.AddCommand(installCmd.InstallCommand())
 	cmd.AddCommand(manualCmd.ManualCommand())
 	cmd.AddCommand(optionCmd.OptionCommand())
 	cmd.AddCommand(replCmd.ReplCommand())
+	cmd.AddCommand(specialisationCmd.SpecialisationCommand())
 
 	for alias, resolved := range cfg.Aliases {
 		err := addAliasCmd(&cmd, alias, resolved)
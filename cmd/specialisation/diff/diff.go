@@ -0,0 +1,112 @@
+package diff
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nix-community/nixos-cli/cmd/specialisation/shared"
+	"github.com/nix-community/nixos-cli/internal/activation"
+	"github.com/nix-community/nixos-cli/internal/closure"
+	"github.com/nix-community/nixos-cli/internal/cmd/opts"
+	"github.com/nix-community/nixos-cli/internal/cmd/utils"
+	"github.com/nix-community/nixos-cli/internal/generation"
+	"github.com/nix-community/nixos-cli/internal/logger"
+	"github.com/nix-community/nixos-cli/internal/settings"
+	"github.com/nix-community/nixos-cli/internal/system"
+)
+
+func SpecialisationDiffCommand(specOpts *cmdOpts.SpecialisationOpts) *cobra.Command {
+	opts := cmdOpts.SpecialisationDiffOpts{}
+
+	cmd := cobra.Command{
+		Use:   "diff [flags] {NAME}",
+		Short: "Show what a specialisation changes",
+		Long:  "Display what paths differ between a specialisation and its base configuration.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.ExactArgs(1)(cmd, args); err != nil {
+				return err
+			}
+
+			opts.Specialisation = args[0]
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmdUtils.CommandErrorHandler(specialisationDiffMain(cmd, specOpts, &opts))
+		},
+	}
+
+	cmd.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return []string{}, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		generationLink := generation.GetProfileDirectoryFromName(specOpts.ProfileName)
+		if opts.Generation != 0 {
+			log := logger.FromContext(cmd.Context())
+			link, _, err := specUtils.ResolveGenerationLink(log, system.NewLocalSystem(log), specOpts.ProfileName, uint64(opts.Generation))
+			if err != nil {
+				return []string{}, cobra.ShellCompDirectiveNoFileComp
+			}
+			generationLink = link
+		}
+
+		return generation.CompleteSpecialisationFlag(generationLink)(cmd, args, toComplete)
+	}
+
+	cmd.Flags().UintVarP(&opts.Generation, "generation", "g", 0, "Use specialisation of generation `number` instead of the current one")
+	cmd.Flags().BoolVarP(&opts.DisplayJson, "json", "j", false, "Display differences in JSON format")
+	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "Show verbose logging")
+
+	_ = cmd.RegisterFlagCompletionFunc("generation", generation.CompleteGenerationNumberFlag(&specOpts.ProfileName))
+
+	cmd.SetHelpTemplate(cmd.HelpTemplate() + `
+Arguments:
+  [NAME]  Name of specialisation to compare with its base configuration
+`)
+	cmdUtils.SetHelpFlagText(&cmd)
+
+	return &cmd
+}
+
+func specialisationDiffMain(cmd *cobra.Command, specOpts *cmdOpts.SpecialisationOpts, opts *cmdOpts.SpecialisationDiffOpts) error {
+	log := logger.FromContext(cmd.Context())
+	cfg := settings.FromContext(cmd.Context())
+	s := system.NewLocalSystem(log)
+
+	generationLink, genNumber, err := specUtils.ResolveGenerationLink(log, s, specOpts.ProfileName, uint64(opts.Generation))
+	if err != nil {
+		return err
+	}
+
+	if opts.Specialisation == "" || !activation.VerifySpecialisationExists(s, generationLink, opts.Specialisation) {
+		msg := fmt.Sprintf("specialisation '%v' does not exist in generation %v", opts.Specialisation, genNumber)
+		log.Error(msg)
+		return fmt.Errorf("%v", msg)
+	}
+
+	specialisationPath := specUtils.SpecialisationPath(generationLink, opts.Specialisation)
+
+	if opts.DisplayJson {
+		diff, err := generation.DiffClosures(s, generationLink, specialisationPath, opts.Verbose)
+		if err != nil {
+			log.Errorf("failed to compare specialisation: %v", err)
+			return err
+		}
+
+		return closure.WriteJSON(os.Stdout, diff)
+	}
+
+	err = generation.RunDiffCommand(log, s, generationLink, specialisationPath, &generation.DiffCommandOptions{
+		UseNvd:  cfg.UseNvd,
+		Verbose: opts.Verbose,
+	})
+	if err != nil {
+		log.Errorf("failed to run diff command: %v", err)
+		return err
+	}
+
+	return nil
+}
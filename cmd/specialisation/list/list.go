@@ -0,0 +1,146 @@
+package list
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/nix-community/nixos-cli/cmd/specialisation/shared"
+	"github.com/nix-community/nixos-cli/internal/activation"
+	"github.com/nix-community/nixos-cli/internal/cmd/opts"
+	"github.com/nix-community/nixos-cli/internal/cmd/utils"
+	"github.com/nix-community/nixos-cli/internal/constants"
+	"github.com/nix-community/nixos-cli/internal/generation"
+	"github.com/nix-community/nixos-cli/internal/logger"
+	"github.com/nix-community/nixos-cli/internal/system"
+)
+
+func SpecialisationListCommand(specOpts *cmdOpts.SpecialisationOpts) *cobra.Command {
+	opts := cmdOpts.SpecialisationListOpts{}
+
+	cmd := cobra.Command{
+		Use:   "list",
+		Short: "List the specialisations of a generation",
+		Long:  "List the specialisations of the current or a given generation, and which one is active.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmdUtils.CommandErrorHandler(specialisationListMain(cmd, specOpts, &opts))
+		},
+	}
+
+	cmd.Flags().UintVarP(&opts.Generation, "generation", "g", 0, "List specialisations of generation `number` instead of the current one")
+	cmd.Flags().BoolVarP(&opts.DisplayJson, "json", "j", false, "Display in JSON format")
+
+	_ = cmd.RegisterFlagCompletionFunc("generation", generation.CompleteGenerationNumberFlag(&specOpts.ProfileName))
+
+	cmdUtils.SetHelpFlagText(&cmd)
+
+	return &cmd
+}
+
+type specialisationInfo struct {
+	// Name of the specialisation, or empty for the base configuration
+	Name    string `json:"name"`
+	Base    bool   `json:"base"`
+	Path    string `json:"path"`
+	Active  bool   `json:"active"`
+	Default bool   `json:"default"`
+}
+
+func specialisationListMain(cmd *cobra.Command, specOpts *cmdOpts.SpecialisationOpts, opts *cmdOpts.SpecialisationListOpts) error {
+	log := logger.FromContext(cmd.Context())
+	s := system.NewLocalSystem(log)
+
+	generationLink, number, err := specUtils.ResolveGenerationLink(log, s, specOpts.ProfileName, uint64(opts.Generation))
+	if err != nil {
+		return err
+	}
+
+	specialisations, err := generation.CollectSpecialisations(generationLink)
+	if err != nil {
+		log.Errorf("failed to collect specialisations: %v", err)
+		return err
+	}
+
+	activeSpecialisation, isActive, err := generation.FindActiveSpecialisation(generationLink, constants.CurrentSystem)
+	if err != nil {
+		log.Warnf("unable to determine active specialisation: %v", err)
+	}
+
+	// Generations built without nixos-cli settings have no default
+	// specialisation, so a missing configuration is not an error.
+	defaultSpecialisation, _ := activation.FindDefaultSpecialisationFromConfig(s, generationLink)
+
+	infos := make([]specialisationInfo, 0, len(specialisations)+1)
+	for _, name := range append([]string{""}, specialisations...) {
+		path := specUtils.SpecialisationPath(generationLink, name)
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			path = resolved
+		}
+
+		infos = append(infos, specialisationInfo{
+			Name:    name,
+			Base:    name == "",
+			Path:    path,
+			Active:  isActive && name == activeSpecialisation,
+			Default: name == defaultSpecialisation,
+		})
+	}
+
+	if opts.DisplayJson {
+		bytes, _ := json.MarshalIndent(map[string]any{
+			"generation":      number,
+			"specialisations": infos,
+		}, "", "  ")
+		fmt.Printf("%v\n", string(bytes))
+
+		return nil
+	}
+
+	if len(specialisations) == 0 {
+		log.Infof("generation %v has no specialisations", number)
+		return nil
+	}
+
+	displayTable(infos)
+
+	return nil
+}
+
+func displayTable(infos []specialisationInfo) {
+	data := make([][]string, len(infos))
+
+	for i, v := range infos {
+		name := v.Name
+		if v.Base {
+			name = "(base)"
+		}
+
+		data[i] = []string{
+			name,
+			fmt.Sprintf("%v", v.Active),
+			fmt.Sprintf("%v", v.Default),
+			v.Path,
+		}
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Name", "Active", "Default", "Store Path"})
+	table.SetAutoWrapText(false)
+	table.SetAutoFormatHeaders(true)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetCenterSeparator("")
+	table.SetColumnSeparator("")
+	table.SetRowSeparator("")
+	table.SetHeaderLine(false)
+	table.SetBorder(false)
+	table.SetTablePadding("\t")
+	table.SetNoWhiteSpace(true)
+	table.AppendBulk(data)
+	table.Render()
+}
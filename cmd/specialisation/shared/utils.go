@@ -0,0 +1,54 @@
+package specUtils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nix-community/nixos-cli/internal/activation"
+	"github.com/nix-community/nixos-cli/internal/constants"
+	"github.com/nix-community/nixos-cli/internal/logger"
+	"github.com/nix-community/nixos-cli/internal/system"
+)
+
+// Find the generation link for a generation number in a profile,
+// using the current generation if the number is 0.
+func ResolveGenerationLink(log *logger.Logger, s system.System, profileName string, number uint64) (string, uint64, error) {
+	if number == 0 {
+		current, err := activation.GetCurrentGenerationNumber(s, profileName)
+		if err != nil {
+			log.Errorf("%v", err)
+			return "", 0, err
+		}
+		number = current
+	}
+
+	profileDirectory := constants.NixProfileDirectory
+	if profileName != "system" {
+		profileDirectory = constants.NixSystemProfileDirectory
+	}
+	generationLink := filepath.Join(profileDirectory, fmt.Sprintf("%v-%v-link", profileName, number))
+
+	if _, err := os.Stat(generationLink); err != nil {
+		if os.IsNotExist(err) {
+			msg := fmt.Sprintf("generation %v not found", number)
+			log.Error(msg)
+			return "", 0, fmt.Errorf("%v", msg)
+		}
+
+		log.Errorf("failed to access generation link: %v", err)
+		return "", 0, err
+	}
+
+	return generationLink, number, nil
+}
+
+// Find the path of a specialisation inside a generation, or the
+// generation itself for the base configuration.
+func SpecialisationPath(generationLink string, specialisation string) string {
+	if specialisation == "" {
+		return generationLink
+	}
+
+	return filepath.Join(generationLink, "specialisation", specialisation)
+}
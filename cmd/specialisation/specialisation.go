@@ -0,0 +1,35 @@
+package specialisation
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/nix-community/nixos-cli/internal/cmd/opts"
+	"github.com/nix-community/nixos-cli/internal/cmd/utils"
+	"github.com/nix-community/nixos-cli/internal/generation"
+
+	specDiffCmd "github.com/nix-community/nixos-cli/cmd/specialisation/diff"
+	specListCmd "github.com/nix-community/nixos-cli/cmd/specialisation/list"
+	specSwitchCmd "github.com/nix-community/nixos-cli/cmd/specialisation/switch"
+)
+
+func SpecialisationCommand() *cobra.Command {
+	opts := cmdOpts.SpecialisationOpts{}
+
+	cmd := cobra.Command{
+		Use:   "specialisation {command}",
+		Short: "Manage NixOS specialisations",
+		Long:  "Inspect and switch between the specialisations of NixOS generations.",
+	}
+
+	cmd.PersistentFlags().StringVarP(&opts.ProfileName, "profile", "p", "system", "System profile to use")
+
+	cmd.AddCommand(specDiffCmd.SpecialisationDiffCommand(&opts))
+	cmd.AddCommand(specListCmd.SpecialisationListCommand(&opts))
+	cmd.AddCommand(specSwitchCmd.SpecialisationSwitchCommand(&opts))
+
+	cmdUtils.SetHelpFlagText(&cmd)
+
+	_ = cmd.RegisterFlagCompletionFunc("profile", generation.CompleteProfileFlag)
+
+	return &cmd
+}
@@ -0,0 +1,209 @@
+package switch_cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nix-community/nixos-cli/cmd/specialisation/shared"
+	"github.com/nix-community/nixos-cli/internal/activation"
+	"github.com/nix-community/nixos-cli/internal/cmd/opts"
+	"github.com/nix-community/nixos-cli/internal/cmd/utils"
+	"github.com/nix-community/nixos-cli/internal/constants"
+	"github.com/nix-community/nixos-cli/internal/generation"
+	"github.com/nix-community/nixos-cli/internal/history"
+	"github.com/nix-community/nixos-cli/internal/hooks"
+	"github.com/nix-community/nixos-cli/internal/logger"
+	"github.com/nix-community/nixos-cli/internal/settings"
+	"github.com/nix-community/nixos-cli/internal/system"
+	"github.com/nix-community/nixos-cli/internal/utils"
+)
+
+func SpecialisationSwitchCommand(specOpts *cmdOpts.SpecialisationOpts) *cobra.Command {
+	opts := cmdOpts.SpecialisationSwitchOpts{}
+
+	cmd := cobra.Command{
+		Use:   "switch [flags] {NAME}",
+		Short: "Activate a specialisation of the current generation",
+		Long:  "Activate a specialisation of the current generation, or its base configuration.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if opts.Base {
+				if len(args) > 0 {
+					return fmt.Errorf("--base cannot be used with a specialisation name")
+				}
+				return nil
+			}
+
+			if err := cobra.ExactArgs(1)(cmd, args); err != nil {
+				return err
+			}
+
+			opts.Specialisation = args[0]
+
+			return nil
+		},
+		ValidArgsFunction: completeSpecialisationName(specOpts),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmdUtils.CommandErrorHandler(specialisationSwitchMain(cmd, specOpts, &opts))
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.Base, "base", "b", false, "Activate the base configuration without specialisations")
+	cmd.Flags().BoolVarP(&opts.Dry, "dry", "d", false, "Show what would be activated, but do not activate")
+	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "Show verbose logging")
+	cmd.Flags().BoolVar(&opts.WaitForLock, "wait", false, "Wait for other operations in progress to finish")
+	cmd.Flags().BoolVarP(&opts.AlwaysConfirm, "yes", "y", false, "Automatically confirm activation")
+
+	cmdUtils.SetHelpFlagText(&cmd)
+	cmd.SetHelpTemplate(cmd.HelpTemplate() + `
+Arguments:
+    [NAME]      Name of specialisation to activate
+`)
+
+	return &cmd
+}
+
+func completeSpecialisationName(specOpts *cmdOpts.SpecialisationOpts) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return []string{}, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		profileDirectory := generation.GetProfileDirectoryFromName(specOpts.ProfileName)
+
+		return generation.CompleteSpecialisationFlag(profileDirectory)(cmd, args, toComplete)
+	}
+}
+
+func specialisationSwitchMain(cmd *cobra.Command, specOpts *cmdOpts.SpecialisationOpts, opts *cmdOpts.SpecialisationSwitchOpts) (err error) {
+	log := logger.FromContext(cmd.Context())
+	cfg := settings.FromContext(cmd.Context())
+	s := system.NewLocalSystem(log)
+
+	if os.Geteuid() != 0 {
+		err := utils.ExecAsRoot(cfg.RootCommand)
+		if err != nil {
+			log.Errorf("failed to re-exec command as root: %v", err)
+			return err
+		}
+	}
+
+	if !opts.Dry {
+		operationLock, err := cmdUtils.AcquireOperationLock(log, "/", opts.WaitForLock)
+		if err != nil {
+			return err
+		}
+		defer cmdUtils.ReleaseOperationLock(log, operationLock)
+	}
+
+	generationLink, genNumber, err := specUtils.ResolveGenerationLink(log, s, specOpts.ProfileName, 0)
+	if err != nil {
+		return err
+	}
+
+	specialisation := opts.Specialisation
+	if !activation.VerifySpecialisationExists(s, generationLink, specialisation) {
+		msg := fmt.Sprintf("specialisation '%v' does not exist in generation %v", specialisation, genNumber)
+		log.Error(msg)
+		return fmt.Errorf("%v", msg)
+	}
+
+	specialisationPath := specUtils.SpecialisationPath(generationLink, specialisation)
+
+	activeSpecialisation, isActive, err := generation.FindActiveSpecialisation(generationLink, constants.CurrentSystem)
+	if err != nil {
+		log.Warnf("unable to determine active specialisation: %v", err)
+	} else if isActive && activeSpecialisation == specialisation {
+		if specialisation == "" {
+			log.Info("the base configuration is already active")
+		} else {
+			log.Infof("specialisation '%v' is already active", specialisation)
+		}
+	}
+
+	log.Step("Comparing changes...")
+
+	err = generation.RunDiffCommand(log, s, constants.CurrentSystem, specialisationPath, &generation.DiffCommandOptions{
+		UseNvd:  cfg.UseNvd,
+		Verbose: opts.Verbose,
+	})
+	if err != nil {
+		log.Errorf("failed to run diff command: %v", err)
+	}
+
+	if !opts.AlwaysConfirm {
+		log.Printf("\n")
+		confirm, err := cmdUtils.ConfirmationInput("Activate this specialisation?")
+		if err != nil {
+			log.Errorf("failed to get confirmation: %v", err)
+			return err
+		}
+		if !confirm {
+			msg := "confirmation was not given, skipping activation"
+			log.Warn(msg)
+			return fmt.Errorf("%v", msg)
+		}
+	}
+
+	// Hooks are meant to run around real changes to the
+	// system, so they are skipped for dry runs entirely.
+	hookSettings := &cfg.Hooks
+	if opts.Dry {
+		hookSettings = &settings.HookSettings{}
+	}
+
+	hookCtx := &hooks.Context{
+		Command:       "specialisation switch",
+		Profile:       specOpts.ProfileName,
+		OldGeneration: genNumber,
+		NewGeneration: genNumber,
+		StorePath:     specialisationPath,
+	}
+
+	if !opts.Dry {
+		historyRecord := history.NewRecord("specialisation switch", specOpts.ProfileName)
+		historyRecord.Action = "switch"
+		historyRecord.PreviousGeneration = genNumber
+		historyRecord.NewGeneration = genNumber
+		defer func() { cmdUtils.WriteHistoryRecord(log, s, historyRecord, err) }()
+	}
+
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		hookCtx.Error = err
+		if err := hooks.Run(s, hookSettings, hooks.StageOnFailure, hookCtx, opts.Verbose); err != nil {
+			log.Warnf("%v", err)
+		}
+	}()
+
+	if err := hooks.Run(s, hookSettings, hooks.StagePreActivate, hookCtx, opts.Verbose); err != nil {
+		log.Errorf("%v", err)
+		return err
+	}
+
+	log.Step("Activating...")
+
+	var stcAction activation.SwitchToConfigurationAction = activation.SwitchToConfigurationActionSwitch
+	if opts.Dry {
+		stcAction = activation.SwitchToConfigurationActionDryActivate
+	}
+
+	err = activation.SwitchToConfiguration(s, generationLink, stcAction, &activation.SwitchToConfigurationOptions{
+		Verbose:        opts.Verbose,
+		Specialisation: specialisation,
+	})
+	if err != nil {
+		log.Errorf("failed to switch to configuration: %v", err)
+		return err
+	}
+
+	if err := hooks.Run(s, hookSettings, hooks.StagePostActivate, hookCtx, opts.Verbose); err != nil {
+		log.Warnf("%v", err)
+	}
+
+	return nil
+}
@@ -15,9 +15,15 @@ import (
 
 // Parse the generation's `nixos-cli` configuration to find the default specialisation
 // for that generation.
-func FindDefaultSpecialisationFromConfig(generationDirname string) (string, error) {
+func FindDefaultSpecialisationFromConfig(s system.System, generationDirname string) (string, error) {
 	generationCfgFilename := filepath.Join(generationDirname, constants.DefaultConfigLocation)
-	generationCfg, err := settings.ParseSettings(generationCfgFilename)
+
+	contents, err := system.ReadFile(s, generationCfgFilename)
+	if err != nil {
+		return "", err
+	}
+
+	generationCfg, err := settings.ParseSettingsFromBytes(contents)
 	if err != nil {
 		return "", err
 	}
@@ -27,28 +33,25 @@ func FindDefaultSpecialisationFromConfig(generationDirname string) (string, erro
 
 // Make sure a specialisation exists in a given generation and can be activated by
 // checking for the presence of the switch-to-configuration script.
-func VerifySpecialisationExists(generationDirname string, specialisation string) bool {
+func VerifySpecialisationExists(s system.System, generationDirname string, specialisation string) bool {
 	if specialisation == "" {
 		// The base config always exists.
 		return true
 	}
 
 	specialisationStcFilename := filepath.Join(generationDirname, "specialisation", specialisation, "bin", "switch-to-configuration")
-	if _, err := os.Stat(specialisationStcFilename); err != nil {
-		return false
-	}
 
-	return true
+	return system.PathExists(s, specialisationStcFilename)
 }
 
-func EnsureSystemProfileDirectoryExists() error {
+func EnsureSystemProfileDirectoryExists(s system.System) error {
 	// The system profile directory sometimes doesn't exist,
 	// and does need to be manually created if this is the case.
 	// This kinda sucks, since it requires root execution, but
 	// there's not really a better way to ensure that this
 	// profile's directory exists.
 
-	err := os.MkdirAll(constants.NixSystemProfileDirectory, 0o755)
+	err := system.MkdirAll(s, constants.NixSystemProfileDirectory, 0o755)
 	if err != nil {
 		if err != os.ErrExist {
 			return fmt.Errorf("failed to create nix system profile directory: %w", err)
@@ -58,9 +61,9 @@ func EnsureSystemProfileDirectoryExists() error {
 	return nil
 }
 
-func AddNewNixProfile(s system.CommandRunner, profile string, closure string, verbose bool) error {
+func AddNewNixProfile(s system.System, profile string, closure string, verbose bool) error {
 	if profile != "system" {
-		err := EnsureSystemProfileDirectoryExists()
+		err := EnsureSystemProfileDirectoryExists(s)
 		if err != nil {
 			return err
 		}
@@ -81,9 +84,9 @@ func AddNewNixProfile(s system.CommandRunner, profile string, closure string, ve
 	return err
 }
 
-func SetNixProfileGeneration(s system.CommandRunner, profile string, genNumber uint64, verbose bool) error {
+func SetNixProfileGeneration(s system.System, profile string, genNumber uint64, verbose bool) error {
 	if profile != "system" {
-		err := EnsureSystemProfileDirectoryExists()
+		err := EnsureSystemProfileDirectoryExists(s)
 		if err != nil {
 			return err
 		}
@@ -104,14 +107,14 @@ func SetNixProfileGeneration(s system.CommandRunner, profile string, genNumber u
 	return err
 }
 
-func GetCurrentGenerationNumber(profile string) (uint64, error) {
+func GetCurrentGenerationNumber(s system.System, profile string) (uint64, error) {
 	genLinkRegex, err := regexp.Compile(fmt.Sprintf(generation.GenerationLinkTemplateRegex, profile))
 	if err != nil {
 		return 0, fmt.Errorf("failed to compile generation regex: %w", err)
 	}
 
 	profileDirectory := generation.GetProfileDirectoryFromName(profile)
-	currentGenerationLink, err := os.Readlink(profileDirectory)
+	currentGenerationLink, err := system.Readlink(s, profileDirectory)
 	if err != nil {
 		return 0, fmt.Errorf("unable to determine current generation: %v", err)
 	}
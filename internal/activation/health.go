@@ -0,0 +1,180 @@
+package activation
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nix-community/nixos-cli/internal/generation"
+	"github.com/nix-community/nixos-cli/internal/settings"
+	"github.com/nix-community/nixos-cli/internal/system"
+	timeUtils "github.com/nix-community/nixos-cli/internal/time"
+)
+
+// How long to wait between attempts when health checks fail.
+const healthCheckRetryInterval = 2 * time.Second
+
+// Run the configured health checks against a freshly activated system.
+// Failing checks are retried until the configured timeout passes, since
+// services may take a while to start up after activation.
+func RunHealthChecks(s system.System, checks *settings.HealthCheckSettings, verbose bool) *generation.HealthCheckResult {
+	// The timeout has already been validated, and an empty one means
+	// that checks are only run once.
+	timeout, _ := timeUtils.DurationFromTimeSpan(checks.Timeout)
+	deadline := time.Now().Add(timeout)
+
+	for {
+		failures := runHealthChecksOnce(s, checks, verbose)
+
+		if len(failures) == 0 || !time.Now().Add(healthCheckRetryInterval).Before(deadline) {
+			return &generation.HealthCheckResult{
+				Time:     time.Now(),
+				Passed:   len(failures) == 0,
+				Failures: failures,
+			}
+		}
+
+		if verbose {
+			s.Logger().Infof("%d health check(s) failed, retrying in %v", len(failures), healthCheckRetryInterval)
+		}
+
+		time.Sleep(healthCheckRetryInterval)
+	}
+}
+
+func runHealthChecksOnce(s system.System, checks *settings.HealthCheckSettings, verbose bool) []string {
+	failures := []string{}
+
+	if checks.NoFailedUnits {
+		failedUnits, err := getFailedUnits(s, verbose)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("failed to list failed units: %v", err))
+		}
+		for _, unit := range failedUnits {
+			failures = append(failures, fmt.Sprintf("unit %v has failed", unit))
+		}
+	}
+
+	for _, unit := range checks.Units {
+		argv := []string{"systemctl", "is-active", "--quiet", unit}
+
+		if verbose {
+			s.Logger().CmdArray(argv)
+		}
+
+		cmd := system.NewCommand(argv[0], argv[1:]...)
+		cmd.Stdout = nil
+		cmd.Stderr = nil
+
+		if _, err := s.Run(cmd); err != nil {
+			failures = append(failures, fmt.Sprintf("unit %v is not active", unit))
+		}
+	}
+
+	if len(checks.TCPPorts) > 0 {
+		listening, err := getListeningTCPPorts(s, verbose)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("failed to list listening ports: %v", err))
+		} else {
+			for _, port := range checks.TCPPorts {
+				if !listening[port] {
+					failures = append(failures, fmt.Sprintf("nothing is listening on TCP port %v", port))
+				}
+			}
+		}
+	}
+
+	for _, command := range checks.Commands {
+		argv := []string{"sh", "-c", command}
+
+		if verbose {
+			s.Logger().CmdArray(argv)
+		}
+
+		cmd := system.NewCommand(argv[0], argv[1:]...)
+		cmd.Stdin = nil
+		cmd.Stdout = nil
+		cmd.Stderr = nil
+
+		if exitCode, err := s.Run(cmd); err != nil {
+			failures = append(failures, fmt.Sprintf("command `%v` failed with exit code %v", command, exitCode))
+		}
+	}
+
+	return failures
+}
+
+func getFailedUnits(s system.System, verbose bool) ([]string, error) {
+	argv := []string{"systemctl", "list-units", "--state=failed", "--plain", "--no-legend", "--no-pager"}
+
+	if verbose {
+		s.Logger().CmdArray(argv)
+	}
+
+	var stdout bytes.Buffer
+
+	cmd := system.NewCommand(argv[0], argv[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = nil
+
+	if _, err := s.Run(cmd); err != nil {
+		return nil, err
+	}
+
+	units := []string{}
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 {
+			units = append(units, fields[0])
+		}
+	}
+
+	return units, nil
+}
+
+func getListeningTCPPorts(s system.System, verbose bool) (map[int64]bool, error) {
+	argv := []string{"ss", "--no-header", "--listening", "--tcp", "--numeric"}
+
+	if verbose {
+		s.Logger().CmdArray(argv)
+	}
+
+	var stdout bytes.Buffer
+
+	cmd := system.NewCommand(argv[0], argv[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = nil
+
+	if _, err := s.Run(cmd); err != nil {
+		return nil, err
+	}
+
+	ports := make(map[int64]bool)
+
+	// Output lines look like the following, with the local address
+	// being the fourth column:
+	// LISTEN 0 4096 [::]:22 [::]:*
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+
+		localAddress := fields[3]
+		portIdx := strings.LastIndex(localAddress, ":")
+		if portIdx == -1 {
+			continue
+		}
+
+		port, err := strconv.ParseInt(localAddress[portIdx+1:], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		ports[port] = true
+	}
+
+	return ports, nil
+}
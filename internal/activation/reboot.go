@@ -0,0 +1,167 @@
+package activation
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nix-community/nixos-cli/internal/constants"
+	"github.com/nix-community/nixos-cli/internal/system"
+)
+
+// A difference between the booted system and a newer one that
+// only takes effect after rebooting.
+type RebootReason struct {
+	// The part of the system that differs, such as "kernel"
+	Component string `json:"component"`
+	Booted    string `json:"booted"`
+	New       string `json:"new"`
+}
+
+func (r RebootReason) String() string {
+	return fmt.Sprintf("%v changed (%v -> %v)", r.Component, r.Booted, r.New)
+}
+
+// Parts of a system closure that are only loaded at boot, and
+// thus cannot be changed by switching configurations. These
+// are all symlinks into the Nix store.
+var bootComponents = []string{"kernel", "initrd", "kernel-modules", "systemd"}
+
+// Check if the system at the given store path differs from the
+// booted system in a way that only takes effect after a reboot,
+// and return each difference found.
+//
+// If bootOnly is set, the new system is assumed to have only been
+// made the boot default without being activated, so any difference
+// from the currently running system requires a reboot too.
+//
+// Systems that were not booted from a NixOS generation, such
+// as containers, never require a reboot.
+func CheckRebootRequired(s system.System, systemPath string, bootOnly bool) ([]RebootReason, error) {
+	if !system.PathExists(s, constants.BootedSystem) {
+		return nil, nil
+	}
+
+	reasons := []RebootReason{}
+
+	for _, component := range bootComponents {
+		bootedPath := filepath.Join(constants.BootedSystem, component)
+		newPath := filepath.Join(systemPath, component)
+
+		// Older generations may not have all components,
+		// such as the systemd link, so skip those.
+		if !system.PathExists(s, bootedPath) || !system.PathExists(s, newPath) {
+			continue
+		}
+
+		booted, err := system.Readlink(s, bootedPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %v of booted system: %w", component, err)
+		}
+
+		next, err := system.Readlink(s, newPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %v of %v: %w", component, systemPath, err)
+		}
+
+		if booted != next {
+			reasons = append(reasons, RebootReason{
+				Component: component,
+				Booted:    storePathName(booted),
+				New:       storePathName(next),
+			})
+		}
+	}
+
+	bootedParams, err := readKernelParams(s, constants.BootedSystem)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kernel parameters of booted system: %w", err)
+	}
+
+	newParams, err := readKernelParams(s, systemPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kernel parameters of %v: %w", systemPath, err)
+	}
+
+	if bootedParams != newParams {
+		reasons = append(reasons, RebootReason{
+			Component: "kernel-params",
+			Booted:    bootedParams,
+			New:       newParams,
+		})
+	}
+
+	if bootOnly && len(reasons) == 0 {
+		current, err := system.Readlink(s, constants.CurrentSystem)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read current system: %w", err)
+		}
+
+		if current != systemPath {
+			reasons = append(reasons, RebootReason{
+				Component: "configuration",
+				Booted:    storePathName(current),
+				New:       storePathName(systemPath),
+			})
+		}
+	}
+
+	return reasons, nil
+}
+
+func readKernelParams(s system.System, systemPath string) (string, error) {
+	paramsPath := filepath.Join(systemPath, "kernel-params")
+	if !system.PathExists(s, paramsPath) {
+		return "", nil
+	}
+
+	contents, err := system.ReadFile(s, paramsPath)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(contents)), nil
+}
+
+// Get the name of a store path without its hash, such as "linux-6.6.1"
+// for "/nix/store/<hash>-linux-6.6.1/bzImage". Paths outside of the
+// Nix store are returned as-is.
+func storePathName(path string) string {
+	rest, found := strings.CutPrefix(path, "/nix/store/")
+	if !found {
+		return path
+	}
+
+	entry, _, _ := strings.Cut(rest, "/")
+
+	_, name, found := strings.Cut(entry, "-")
+	if !found {
+		return entry
+	}
+
+	return name
+}
+
+// Reboot the system, either immediately or after the given delay.
+// Delayed reboots are scheduled with shutdown(8) at minute precision,
+// and can be cancelled with `shutdown -c`.
+func Reboot(s system.CommandRunner, delay time.Duration, verbose bool) error {
+	var argv []string
+	if delay > 0 {
+		minutes := int64(math.Ceil(delay.Minutes()))
+		argv = []string{"shutdown", "--reboot", fmt.Sprintf("+%d", minutes)}
+	} else {
+		argv = []string{"systemctl", "reboot"}
+	}
+
+	if verbose {
+		s.Logger().CmdArray(argv)
+	}
+
+	cmd := system.NewCommand(argv[0], argv[1:]...)
+	_, err := s.Run(cmd)
+
+	return err
+}
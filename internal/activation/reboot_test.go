@@ -0,0 +1,21 @@
+package activation
+
+import "testing"
+
+func TestStorePathName(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/nix/store/0c1bsmq2cc4z1xpi5dvbxbz5wrqkh3kb-linux-6.6.30/bzImage", "linux-6.6.30"},
+		{"/nix/store/0c1bsmq2cc4z1xpi5dvbxbz5wrqkh3kb-systemd-255.6", "systemd-255.6"},
+		{"/nix/store/0c1bsmq2cc4z1xpi5dvbxbz5wrqkh3kb", "0c1bsmq2cc4z1xpi5dvbxbz5wrqkh3kb"},
+		{"/run/current-system", "/run/current-system"},
+	}
+
+	for _, tt := range tests {
+		if got := storePathName(tt.path); got != tt.want {
+			t.Errorf("storePathName(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
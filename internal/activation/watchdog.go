@@ -0,0 +1,125 @@
+package activation
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/nix-community/nixos-cli/internal/generation"
+	"github.com/nix-community/nixos-cli/internal/system"
+	"github.com/nix-community/nixos-cli/internal/utils"
+)
+
+// The rollback watchdog is a transient systemd timer that reverts to a
+// previous generation unless it is cancelled in time. Using systemd for
+// this ensures that the watchdog keeps running even if the process that
+// scheduled it dies, such as when an SSH session is cut off by a bad
+// network configuration.
+const RollbackWatchdogUnit = "nixos-cli-rollback-watchdog"
+
+type RollbackWatchdogOptions struct {
+	// The profile to roll back.
+	Profile string
+	// The generation to roll back to.
+	Generation uint64
+	// How to activate the previous generation when rolling back.
+	Action SwitchToConfigurationAction
+	// The specialisation of the previous generation to activate, if any.
+	Specialisation string
+	// How long to wait before rolling back.
+	Timeout time.Duration
+	Verbose bool
+}
+
+func ScheduleRollbackWatchdog(s system.System, opts *RollbackWatchdogOptions) error {
+	if IsRollbackWatchdogActive(s) {
+		return fmt.Errorf("a previous activation is still awaiting confirmation")
+	}
+
+	// Clear out any failed units from previous runs, since
+	// systemd-run refuses to reuse unit names otherwise.
+	resetCmd := system.NewCommand("systemctl", "reset-failed", RollbackWatchdogUnit+".service", RollbackWatchdogUnit+".timer")
+	resetCmd.Stdout = nil
+	resetCmd.Stderr = nil
+	_, _ = s.Run(resetCmd)
+
+	profileDirectory := generation.GetProfileDirectoryFromName(opts.Profile)
+	generationLink := fmt.Sprintf("%s-%d-link", profileDirectory, opts.Generation)
+
+	// Services do not have a useful $PATH on NixOS, so use the tools from
+	// the generation being rolled back to, which are known to work.
+	nixEnv := filepath.Join(generationLink, "sw", "bin", "nix-env")
+	stc := filepath.Join(generationLink, "bin", "switch-to-configuration")
+	if opts.Specialisation != "" {
+		stc = filepath.Join(generationLink, "specialisation", opts.Specialisation, "bin", "switch-to-configuration")
+	}
+
+	rollbackScript := fmt.Sprintf("%s && %s",
+		utils.EscapeAndJoinArgs([]string{nixEnv, "--profile", profileDirectory, "--switch-generation", fmt.Sprintf("%d", opts.Generation)}),
+		utils.EscapeAndJoinArgs([]string{stc, opts.Action.String()}),
+	)
+
+	argv := []string{
+		"systemd-run",
+		"--unit", RollbackWatchdogUnit,
+		"--description", fmt.Sprintf("Roll back unconfirmed activation to generation %d", opts.Generation),
+		fmt.Sprintf("--on-active=%ds", int64(opts.Timeout.Seconds())),
+		"--timer-property=AccuracySec=1s",
+		"--property=Type=oneshot",
+		"/bin/sh", "-c", rollbackScript,
+	}
+
+	if opts.Verbose {
+		s.Logger().CmdArray(argv)
+	}
+
+	cmd := system.NewCommand(argv[0], argv[1:]...)
+	_, err := s.Run(cmd)
+
+	return err
+}
+
+func CancelRollbackWatchdog(s system.System, verbose bool) error {
+	argv := []string{"systemctl", "stop", RollbackWatchdogUnit + ".timer"}
+
+	if verbose {
+		s.Logger().CmdArray(argv)
+	}
+
+	cmd := system.NewCommand(argv[0], argv[1:]...)
+	_, err := s.Run(cmd)
+
+	return err
+}
+
+// Roll back immediately, rather than waiting for the watchdog to expire.
+//
+// The service is started before the timer is stopped, since stopping
+// the timer first allows systemd to unload the transient service before
+// it ever ran. The timer is stopped either way, so that the rollback
+// does not run again later.
+func TriggerRollbackWatchdog(s system.System, verbose bool) error {
+	argv := []string{"systemctl", "start", RollbackWatchdogUnit + ".service"}
+
+	if verbose {
+		s.Logger().CmdArray(argv)
+	}
+
+	cmd := system.NewCommand(argv[0], argv[1:]...)
+	_, err := s.Run(cmd)
+
+	if cancelErr := CancelRollbackWatchdog(s, verbose); cancelErr != nil && err == nil {
+		err = cancelErr
+	}
+
+	return err
+}
+
+func IsRollbackWatchdogActive(s system.System) bool {
+	cmd := system.NewCommand("systemctl", "is-active", "--quiet", RollbackWatchdogUnit+".timer")
+	cmd.Stdout = nil
+	cmd.Stderr = nil
+
+	_, err := s.Run(cmd)
+	return err == nil
+}
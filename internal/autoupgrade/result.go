@@ -0,0 +1,101 @@
+package autoupgrade
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nix-community/nixos-cli/internal/constants"
+)
+
+// Location of the result of the last automatic upgrade. Unlike the
+// activation history, only the most recent result is kept.
+const ResultFile = constants.NixOSCLIStateDirectory + "/auto-upgrade.json"
+
+type Status string
+
+const (
+	StatusSuccess Status = "success"
+	StatusFailure Status = "failure"
+	// Another operation was in progress, so the upgrade did not run.
+	StatusSkipped Status = "skipped"
+)
+
+// Exit codes for the `auto-upgrade` command, meant to be
+// checked by monitoring tools and systemd units.
+const (
+	ExitSuccess       = 0
+	ExitFailure       = 1
+	ExitSkipped       = 2
+	ExitRebootPending = 3
+)
+
+type Result struct {
+	Time               time.Time `json:"time"`
+	Status             Status    `json:"status"`
+	Operation          string    `json:"operation"`
+	PreviousGeneration uint64    `json:"previous_generation,omitempty"`
+	NewGeneration      uint64    `json:"new_generation,omitempty"`
+
+	RebootRequired bool     `json:"reboot_required"`
+	RebootReasons  []string `json:"reboot_reasons,omitempty"`
+	Rebooted       bool     `json:"rebooted"`
+
+	Error    string `json:"error,omitempty"`
+	Duration int64  `json:"duration_ms"`
+}
+
+// Check if the upgraded system still needs to be rebooted
+// for all of its changes to take effect.
+func (r *Result) RebootPending() bool {
+	return r.Status == StatusSuccess && r.RebootRequired && !r.Rebooted
+}
+
+func (r *Result) ExitCode() int {
+	switch r.Status {
+	case StatusFailure:
+		return ExitFailure
+	case StatusSkipped:
+		return ExitSkipped
+	}
+
+	if r.RebootPending() {
+		return ExitRebootPending
+	}
+
+	return ExitSuccess
+}
+
+func Write(result *Result) error {
+	contents, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(ResultFile), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(ResultFile, contents, 0o644)
+}
+
+// Read the result of the last automatic upgrade. Returns
+// nil if no automatic upgrade has been run yet.
+func Read() (*Result, error) {
+	contents, err := os.ReadFile(ResultFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var result Result
+	if err := json.Unmarshal(contents, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
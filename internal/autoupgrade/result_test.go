@@ -0,0 +1,25 @@
+package autoupgrade
+
+import "testing"
+
+func TestResultExitCode(t *testing.T) {
+	tests := []struct {
+		name   string
+		result Result
+		want   int
+	}{
+		{"success", Result{Status: StatusSuccess}, ExitSuccess},
+		{"failure", Result{Status: StatusFailure, RebootRequired: true}, ExitFailure},
+		{"skipped", Result{Status: StatusSkipped}, ExitSkipped},
+		{"reboot pending", Result{Status: StatusSuccess, RebootRequired: true}, ExitRebootPending},
+		{"rebooted", Result{Status: StatusSuccess, RebootRequired: true, Rebooted: true}, ExitSuccess},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.result.ExitCode(); got != tt.want {
+				t.Errorf("ExitCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
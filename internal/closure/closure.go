@@ -0,0 +1,96 @@
+package closure
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nix-community/nixos-cli/internal/system"
+)
+
+// The closure of a store path: all of the store paths
+// that it depends on at runtime, including itself.
+type Closure struct {
+	Path string
+	// NAR sizes of all store paths in the closure, in bytes
+	Paths map[string]uint64
+}
+
+func (c *Closure) Size() uint64 {
+	var total uint64
+	for _, size := range c.Paths {
+		total += size
+	}
+	return total
+}
+
+type pathInfo struct {
+	Path    string `json:"path"`
+	NarSize uint64 `json:"narSize"`
+	// Only set by older versions of Nix, for paths that
+	// do not exist in the store that was queried.
+	Valid *bool `json:"valid,omitempty"`
+}
+
+// Query the closure of a store path (or a symlink to one, such as
+// /run/current-system), along with the size of each path in it.
+func Query(s system.CommandRunner, path string, verbose bool) (*Closure, error) {
+	argv := []string{"nix", "--extra-experimental-features", "nix-command", "path-info", "--json", "--recursive", path}
+
+	if verbose {
+		s.Logger().CmdArray(argv)
+	}
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd := system.NewCommand(argv[0], argv[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if _, err := s.Run(cmd); err != nil {
+		return nil, fmt.Errorf("failed to query closure of %v: %v: %s", path, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	paths, err := parsePathInfo(stdout.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse closure of %v: %w", path, err)
+	}
+
+	return &Closure{Path: path, Paths: paths}, nil
+}
+
+// Parse the output of `nix path-info --json`. Nix 2.19 changed this
+// from a list of objects to an object keyed by store path, so both
+// formats must be accepted.
+func parsePathInfo(data []byte) (map[string]uint64, error) {
+	data = bytes.TrimSpace(data)
+	paths := make(map[string]uint64)
+
+	if len(data) > 0 && data[0] == '[' {
+		var infos []pathInfo
+		if err := json.Unmarshal(data, &infos); err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			if info.Valid != nil && !*info.Valid {
+				continue
+			}
+			paths[info.Path] = info.NarSize
+		}
+		return paths, nil
+	}
+
+	var infos map[string]*pathInfo
+	if err := json.Unmarshal(data, &infos); err != nil {
+		return nil, err
+	}
+	for path, info := range infos {
+		// Invalid paths are included as null values.
+		if info == nil {
+			continue
+		}
+		paths[path] = info.NarSize
+	}
+
+	return paths, nil
+}
@@ -0,0 +1,152 @@
+package closure
+
+import (
+	"testing"
+)
+
+func TestParseStorePathName(t *testing.T) {
+	tests := []struct {
+		path    string
+		name    string
+		version string
+	}{
+		{"/nix/store/aaaa-hello-2.12.1", "hello", "2.12.1"},
+		{"/nix/store/aaaa-hello-2.12.1-man", "hello", "2.12.1"},
+		{"/nix/store/aaaa-openssl-3.0.13-lib64", "openssl", "3.0.13"},
+		{"/nix/store/aaaa-etc-os-release", "etc-os-release", ""},
+		{"/nix/store/aaaa-nixos-system-nixos-24.05", "nixos-system-nixos", "24.05"},
+		{"/nix/store/aaaa-python3.11-requests-2.31.0", "python3.11-requests", "2.31.0"},
+	}
+
+	for _, tt := range tests {
+		name, version := ParseStorePathName(tt.path)
+		if name != tt.name || version != tt.version {
+			t.Errorf("ParseStorePathName(%q) = (%q, %q), expected (%q, %q)", tt.path, name, version, tt.name, tt.version)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a        string
+		b        string
+		expected int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "2.3", -1},
+		{"2.1", "2.3", -1},
+		{"2.3", "2.3.1", -1},
+		{"2.3.1", "2.3a", 1},
+		{"2.3pre1", "2.3", -1},
+		{"2.3", "2.3pre1", 1},
+		{"1.10", "1.9", 1},
+		{"", "1", -1},
+	}
+
+	for _, tt := range tests {
+		if result := CompareVersions(tt.a, tt.b); result != tt.expected {
+			t.Errorf("CompareVersions(%q, %q) = %d, expected %d", tt.a, tt.b, result, tt.expected)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	before := &Closure{
+		Path: "before",
+		Paths: map[string]uint64{
+			"/nix/store/aaaa-hello-2.12":     100,
+			"/nix/store/bbbb-removed-1.0":    50,
+			"/nix/store/cccc-bash-5.2":       300,
+			"/nix/store/dddd-rebuilt-1.0":    1000,
+			"/nix/store/eeee-unchanged-1.0":  10,
+			"/nix/store/ffff-downgraded-2.0": 20,
+		},
+	}
+	after := &Closure{
+		Path: "after",
+		Paths: map[string]uint64{
+			"/nix/store/gggg-hello-2.13":     120,
+			"/nix/store/hhhh-added-0.1":      70,
+			"/nix/store/cccc-bash-5.2":       300,
+			"/nix/store/iiii-rebuilt-1.0":    2000,
+			"/nix/store/eeee-unchanged-1.0":  10,
+			"/nix/store/jjjj-downgraded-1.9": 20,
+		},
+	}
+
+	diff := Compare(before, after)
+
+	expected := map[string]ChangeType{
+		"added":      ChangeAdded,
+		"downgraded": ChangeDowngraded,
+		"hello":      ChangeUpgraded,
+		"rebuilt":    ChangeSizeChanged,
+		"removed":    ChangeRemoved,
+	}
+
+	if len(diff.Changes) != len(expected) {
+		t.Fatalf("expected %d changes, got %d: %+v", len(expected), len(diff.Changes), diff.Changes)
+	}
+
+	for i, c := range diff.Changes {
+		if i > 0 && diff.Changes[i-1].Name > c.Name {
+			t.Errorf("changes are not sorted by name")
+		}
+		if expected[c.Name] != c.Type {
+			t.Errorf("expected %v to be %v, got %v", c.Name, expected[c.Name], c.Type)
+		}
+	}
+
+	if diff.SizeDelta() != 1040 {
+		t.Errorf("expected size delta of 1040, got %d", diff.SizeDelta())
+	}
+}
+
+func TestParsePathInfo(t *testing.T) {
+	oldFormat := `[{"path":"/nix/store/aaaa-hello-2.12","narSize":100},{"path":"/nix/store/bbbb-glibc-2.39","narSize":200},{"path":"/nix/store/cccc-invalid","valid":false}]`
+	newFormat := `{"/nix/store/aaaa-hello-2.12":{"narSize":100},"/nix/store/bbbb-glibc-2.39":{"narSize":200},"/nix/store/cccc-invalid":null}`
+
+	for _, data := range []string{oldFormat, newFormat} {
+		paths, err := parsePathInfo([]byte(data))
+		if err != nil {
+			t.Fatalf("failed to parse path info: %v", err)
+		}
+		if len(paths) != 2 || paths["/nix/store/bbbb-glibc-2.39"] != 200 {
+			t.Errorf("unexpected paths: %v", paths)
+		}
+	}
+}
+
+func TestGrowthThreshold(t *testing.T) {
+	tests := []struct {
+		threshold string
+		before    uint64
+		after     uint64
+		exceeded  bool
+	}{
+		{"10%", 1000, 1100, false},
+		{"10%", 1000, 1101, true},
+		{"10%", 1000, 900, false},
+		{"1KiB", 1000, 2024, false},
+		{"1K", 1000, 2025, true},
+		{"1.5 GiB", 0, 1 << 30, false},
+		{"0", 1000, 1001, true},
+	}
+
+	for _, tt := range tests {
+		threshold, err := ParseGrowthThreshold(tt.threshold)
+		if err != nil {
+			t.Fatalf("ParseGrowthThreshold(%q) returned error: %v", tt.threshold, err)
+		}
+
+		if exceeded := threshold.Exceeded(tt.before, tt.after); exceeded != tt.exceeded {
+			t.Errorf("%q: Exceeded(%d, %d) = %v, expected %v", tt.threshold, tt.before, tt.after, exceeded, tt.exceeded)
+		}
+	}
+
+	for _, invalid := range []string{"", "%", "-5%", "abc", "10X", "10 MBs"} {
+		if _, err := ParseGrowthThreshold(invalid); err == nil {
+			t.Errorf("ParseGrowthThreshold(%q) did not return an error", invalid)
+		}
+	}
+}
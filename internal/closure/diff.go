@@ -0,0 +1,172 @@
+package closure
+
+import (
+	"slices"
+	"sort"
+)
+
+type ChangeType string
+
+const (
+	ChangeAdded      ChangeType = "added"
+	ChangeRemoved    ChangeType = "removed"
+	ChangeUpgraded   ChangeType = "upgraded"
+	ChangeDowngraded ChangeType = "downgraded"
+	// Versions changed, but neither set of versions is newer,
+	// such as when a package with multiple versions loses one.
+	ChangeVersionChanged ChangeType = "changed"
+	// Versions are the same, but the size of the package changed,
+	// usually because it was rebuilt with different dependencies.
+	ChangeSizeChanged ChangeType = "size_changed"
+)
+
+type PackageChange struct {
+	Name           string     `json:"name"`
+	Type           ChangeType `json:"type"`
+	VersionsBefore []string   `json:"versions_before"`
+	VersionsAfter  []string   `json:"versions_after"`
+	SizeBefore     uint64     `json:"size_before"`
+	SizeAfter      uint64     `json:"size_after"`
+}
+
+func (c *PackageChange) SizeDelta() int64 {
+	return int64(c.SizeAfter) - int64(c.SizeBefore)
+}
+
+type Diff struct {
+	Before      string          `json:"before"`
+	After       string          `json:"after"`
+	PathsBefore int             `json:"paths_before"`
+	PathsAfter  int             `json:"paths_after"`
+	SizeBefore  uint64          `json:"size_before"`
+	SizeAfter   uint64          `json:"size_after"`
+	Changes     []PackageChange `json:"changes"`
+}
+
+func (d *Diff) SizeDelta() int64 {
+	return int64(d.SizeAfter) - int64(d.SizeBefore)
+}
+
+// Get all changes of the given types, in the same order.
+func (d *Diff) ChangesOfType(types ...ChangeType) []PackageChange {
+	changes := []PackageChange{}
+	for _, c := range d.Changes {
+		if slices.Contains(types, c.Type) {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+type packageGroup struct {
+	versions map[string]struct{}
+	size     uint64
+}
+
+func (g *packageGroup) sortedVersions() []string {
+	if g == nil {
+		return []string{}
+	}
+
+	versions := make([]string, 0, len(g.versions))
+	for v := range g.versions {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return CompareVersions(versions[i], versions[j]) < 0
+	})
+
+	return versions
+}
+
+func groupByPackage(c *Closure) map[string]*packageGroup {
+	groups := make(map[string]*packageGroup)
+
+	for path, size := range c.Paths {
+		name, version := ParseStorePathName(path)
+
+		group, ok := groups[name]
+		if !ok {
+			group = &packageGroup{versions: make(map[string]struct{})}
+			groups[name] = group
+		}
+
+		group.versions[version] = struct{}{}
+		group.size += size
+	}
+
+	return groups
+}
+
+// Compare two closures, grouping their store paths by package name.
+// Changes are sorted by package name.
+func Compare(before *Closure, after *Closure) *Diff {
+	beforeGroups := groupByPackage(before)
+	afterGroups := groupByPackage(after)
+
+	names := make(map[string]struct{})
+	for name := range beforeGroups {
+		names[name] = struct{}{}
+	}
+	for name := range afterGroups {
+		names[name] = struct{}{}
+	}
+
+	diff := &Diff{
+		Before:      before.Path,
+		After:       after.Path,
+		PathsBefore: len(before.Paths),
+		PathsAfter:  len(after.Paths),
+		SizeBefore:  before.Size(),
+		SizeAfter:   after.Size(),
+		Changes:     []PackageChange{},
+	}
+
+	for name := range names {
+		b, a := beforeGroups[name], afterGroups[name]
+
+		change := PackageChange{
+			Name:           name,
+			VersionsBefore: b.sortedVersions(),
+			VersionsAfter:  a.sortedVersions(),
+		}
+		if b != nil {
+			change.SizeBefore = b.size
+		}
+		if a != nil {
+			change.SizeAfter = a.size
+		}
+
+		switch {
+		case b == nil:
+			change.Type = ChangeAdded
+		case a == nil:
+			change.Type = ChangeRemoved
+		case !slices.Equal(change.VersionsBefore, change.VersionsAfter):
+			// Versions are sorted, so the last one is the newest.
+			newestBefore := change.VersionsBefore[len(change.VersionsBefore)-1]
+			newestAfter := change.VersionsAfter[len(change.VersionsAfter)-1]
+
+			switch CompareVersions(newestBefore, newestAfter) {
+			case -1:
+				change.Type = ChangeUpgraded
+			case 1:
+				change.Type = ChangeDowngraded
+			default:
+				change.Type = ChangeVersionChanged
+			}
+		case change.SizeBefore != change.SizeAfter:
+			change.Type = ChangeSizeChanged
+		default:
+			continue
+		}
+
+		diff.Changes = append(diff.Changes, change)
+	}
+
+	sort.Slice(diff.Changes, func(i, j int) bool {
+		return diff.Changes[i].Name < diff.Changes[j].Name
+	})
+
+	return diff
+}
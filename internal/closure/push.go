@@ -0,0 +1,120 @@
+package closure
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+
+	"github.com/nix-community/nixos-cli/internal/system"
+)
+
+type PushOptions struct {
+	// Nix store URI to copy the closure to, such as "file:///var/cache/nix"
+	To string
+	// Path to a secret key file to sign the closure with
+	// before copying it, or empty to skip signing.
+	SigningKey string
+	Verbose    bool
+}
+
+type PushResult struct {
+	// Paths that were copied to the store, sorted
+	Uploaded []string
+	// Paths that were already present in the store, sorted
+	Present []string
+	// Total NAR size of uploaded paths, in bytes
+	UploadedSize uint64
+	// Set if the paths in the store could not be queried beforehand,
+	// such as with older versions of Nix that fail to query missing
+	// paths. All paths are then counted as uploaded.
+	PresenceUnknown bool
+}
+
+// Copy the closure of a store path to another Nix store, such
+// as a binary cache, signing it first if a key is given.
+func Push(s system.CommandRunner, path string, opts *PushOptions) (*PushResult, error) {
+	c, err := Query(s, path, opts.Verbose)
+	if err != nil {
+		return nil, err
+	}
+
+	storePaths := make([]string, 0, len(c.Paths))
+	for p := range c.Paths {
+		storePaths = append(storePaths, p)
+	}
+	slices.Sort(storePaths)
+
+	result := &PushResult{
+		Uploaded: []string{},
+		Present:  []string{},
+	}
+
+	present, err := queryValidPaths(s, opts.To, storePaths, opts.Verbose)
+	if err != nil {
+		s.Logger().Warnf("unable to check which paths are already present: %v", err)
+		result.PresenceUnknown = true
+	}
+
+	for _, p := range storePaths {
+		if _, ok := present[p]; ok {
+			result.Present = append(result.Present, p)
+		} else {
+			result.Uploaded = append(result.Uploaded, p)
+			result.UploadedSize += c.Paths[p]
+		}
+	}
+
+	if opts.SigningKey != "" {
+		argv := []string{"nix", "--extra-experimental-features", "nix-command", "store", "sign", "--key-file", opts.SigningKey, "--recursive", path}
+		if err := runNixCommand(s, argv, opts.Verbose); err != nil {
+			return nil, fmt.Errorf("failed to sign closure of %v: %w", path, err)
+		}
+	}
+
+	argv := []string{"nix", "--extra-experimental-features", "nix-command", "copy", "--to", opts.To, path}
+	if err := runNixCommand(s, argv, opts.Verbose); err != nil {
+		return nil, fmt.Errorf("failed to copy closure of %v to %v: %w", path, opts.To, err)
+	}
+
+	return result, nil
+}
+
+// Find which of the given paths already exist in a store.
+func queryValidPaths(s system.CommandRunner, storeURI string, paths []string, verbose bool) (map[string]uint64, error) {
+	argv := []string{"nix", "--extra-experimental-features", "nix-command", "path-info", "--json", "--store", storeURI}
+	argv = append(argv, paths...)
+
+	if verbose {
+		s.Logger().CmdArray(argv[:len(argv)-len(paths)])
+	}
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd := system.NewCommand(argv[0], argv[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	// Nix exits with a non-zero status when some of the paths are
+	// missing, so only fail if nothing could be parsed at all.
+	_, runErr := s.Run(cmd)
+
+	valid, err := parsePathInfo(stdout.Bytes())
+	if err != nil {
+		if runErr != nil {
+			return nil, fmt.Errorf("failed to query paths in %v: %v: %s", storeURI, runErr, bytes.TrimSpace(stderr.Bytes()))
+		}
+		return nil, fmt.Errorf("failed to parse paths in %v: %w", storeURI, err)
+	}
+
+	return valid, nil
+}
+
+func runNixCommand(s system.CommandRunner, argv []string, verbose bool) error {
+	if verbose {
+		s.Logger().CmdArray(argv)
+	}
+
+	cmd := system.NewCommand(argv[0], argv[1:]...)
+	_, err := s.Run(cmd)
+	return err
+}
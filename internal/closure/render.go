@@ -0,0 +1,137 @@
+package closure
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/fatih/color"
+	"github.com/nix-community/nixos-cli/internal/utils"
+)
+
+// Packages with only size changes smaller than this are not shown
+// in text output, since they are usually not interesting.
+const minDisplayedSizeDelta = 8 * 1024
+
+func formatVersions(versions []string) string {
+	if len(versions) == 0 {
+		return "∅"
+	}
+
+	formatted := make([]string, len(versions))
+	for i, v := range versions {
+		if v == "" {
+			v = "ε"
+		}
+		formatted[i] = v
+	}
+
+	return strings.Join(formatted, ", ")
+}
+
+// Format a size difference in human-readable units, with a sign.
+func FormatSizeDelta(delta int64) string {
+	if delta < 0 {
+		return "-" + utils.FormatBytes(uint64(-delta))
+	}
+	return "+" + utils.FormatBytes(uint64(delta))
+}
+
+// Write a human-readable summary of a diff, grouped by type of change.
+func WriteText(w io.Writer, d *Diff) error {
+	boldStyle := color.New(color.Bold)
+
+	sections := []struct {
+		title string
+		tag   string
+		style *color.Color
+		types []ChangeType
+	}{
+		{"Version changes", "", nil, []ChangeType{ChangeUpgraded, ChangeDowngraded, ChangeVersionChanged}},
+		{"Added packages", "[A]", color.New(color.FgGreen), []ChangeType{ChangeAdded}},
+		{"Removed packages", "[R]", color.New(color.FgRed), []ChangeType{ChangeRemoved}},
+		{"Size changes", "[S]", color.New(color.FgBlue), []ChangeType{ChangeSizeChanged}},
+	}
+
+	tags := map[ChangeType]string{
+		ChangeUpgraded:       color.New(color.FgCyan).Sprint("[U]"),
+		ChangeDowngraded:     color.New(color.FgYellow).Sprint("[D]"),
+		ChangeVersionChanged: color.New(color.FgMagenta).Sprint("[C]"),
+	}
+
+	shownAny := false
+
+	for _, section := range sections {
+		changes := d.ChangesOfType(section.types...)
+		if section.types[0] == ChangeSizeChanged {
+			filtered := []PackageChange{}
+			for _, c := range changes {
+				delta := c.SizeDelta()
+				if delta >= minDisplayedSizeDelta || delta <= -minDisplayedSizeDelta {
+					filtered = append(filtered, c)
+				}
+			}
+			changes = filtered
+		}
+
+		if len(changes) == 0 {
+			continue
+		}
+
+		if shownAny {
+			fmt.Fprintln(w)
+		}
+		shownAny = true
+
+		fmt.Fprintln(w, boldStyle.Sprintf("%s:", section.title))
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, c := range changes {
+			tag := tags[c.Type]
+			if section.style != nil {
+				tag = section.style.Sprint(section.tag)
+			}
+
+			var versions string
+			switch c.Type {
+			case ChangeAdded:
+				versions = formatVersions(c.VersionsAfter)
+			case ChangeRemoved, ChangeSizeChanged:
+				versions = formatVersions(c.VersionsBefore)
+			default:
+				versions = fmt.Sprintf("%s → %s", formatVersions(c.VersionsBefore), formatVersions(c.VersionsAfter))
+			}
+
+			fmt.Fprintf(tw, "%s %s\t%s\t%s\n", tag, c.Name, versions, FormatSizeDelta(c.SizeDelta()))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if !shownAny {
+		fmt.Fprintln(w, "No package changes.")
+	}
+
+	fmt.Fprintln(w)
+	_, err := fmt.Fprintf(w, "%s %d → %d paths, %s → %s (%s)\n",
+		boldStyle.Sprint("Closure size:"),
+		d.PathsBefore, d.PathsAfter,
+		utils.FormatBytes(d.SizeBefore), utils.FormatBytes(d.SizeAfter),
+		FormatSizeDelta(d.SizeDelta()),
+	)
+
+	return err
+}
+
+func WriteJSON(w io.Writer, d *Diff) error {
+	bytes, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", bytes)
+	return err
+}
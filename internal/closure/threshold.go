@@ -0,0 +1,59 @@
+package closure
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nix-community/nixos-cli/internal/utils"
+)
+
+// How much a closure is allowed to grow before warning about it,
+// either by an absolute size or by a percentage of its old size.
+type GrowthThreshold struct {
+	Bytes   uint64
+	Percent float64
+}
+
+// Parse a growth threshold, such as "500MiB" or "10%".
+func ParseGrowthThreshold(s string) (*GrowthThreshold, error) {
+	s = strings.TrimSpace(s)
+
+	if percent, ok := strings.CutSuffix(s, "%"); ok {
+		value, err := strconv.ParseFloat(strings.TrimSpace(percent), 64)
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("invalid percentage '%s'", s)
+		}
+		return &GrowthThreshold{Percent: value}, nil
+	}
+
+	value, err := utils.ParseBytes(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GrowthThreshold{Bytes: value}, nil
+}
+
+func (t *GrowthThreshold) String() string {
+	if t.Percent > 0 {
+		return strconv.FormatFloat(t.Percent, 'f', -1, 64) + "%"
+	}
+	return utils.FormatBytes(t.Bytes)
+}
+
+// Check if a closure growing from `before` to `after`
+// bytes grows by more than this threshold.
+func (t *GrowthThreshold) Exceeded(before uint64, after uint64) bool {
+	if after <= before {
+		return false
+	}
+
+	growth := after - before
+
+	if t.Percent > 0 {
+		return float64(growth) > float64(before)*t.Percent/100
+	}
+
+	return growth > t.Bytes
+}
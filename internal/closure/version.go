@@ -0,0 +1,113 @@
+package closure
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Output names such as "man", "dev", or "lib" that are appended to
+// store path names for non-default outputs of a derivation.
+var outputSuffixRegex = regexp.MustCompile(`^(.*)-([a-z]+|lib32|lib64)$`)
+
+// Split the name of a store path (i.e. /nix/store/<hash>-hello-2.12-man)
+// into its package name and version, following the same rules as Nix's
+// `builtins.parseDrvName`: the version starts at the first dash that
+// is followed by a digit. Output suffixes are removed from the version.
+func ParseStorePathName(path string) (name string, version string) {
+	base := filepath.Base(path)
+
+	// Store path names are prefixed by a 32-character hash.
+	if _, rest, found := strings.Cut(base, "-"); found {
+		base = rest
+	}
+
+	name, version = parseDrvName(base)
+
+	if m := outputSuffixRegex.FindStringSubmatch(base); m != nil {
+		// Only treat the suffix as an output name if there is still
+		// a version afterwards, to avoid splitting names such as
+		// "etc-os-release" that do not have a version at all.
+		if n, v := parseDrvName(m[1]); v != "" {
+			name, version = n, v
+		}
+	}
+
+	return name, version
+}
+
+func parseDrvName(s string) (string, string) {
+	for i := 0; i < len(s)-1; i++ {
+		if s[i] == '-' && unicode.IsDigit(rune(s[i+1])) {
+			return s[:i], s[i+1:]
+		}
+	}
+	return s, ""
+}
+
+// Compare two versions using the same algorithm as Nix's
+// `builtins.compareVersions`. Returns -1 if a is older than b,
+// 0 if they are equal, and 1 if a is newer than b.
+func CompareVersions(a string, b string) int {
+	for a != "" || b != "" {
+		var c1, c2 string
+		c1, a = nextVersionComponent(a)
+		c2, b = nextVersionComponent(b)
+
+		if componentLessThan(c1, c2) {
+			return -1
+		} else if componentLessThan(c2, c1) {
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func nextVersionComponent(s string) (component string, rest string) {
+	// Skip any separators before the component.
+	s = strings.TrimLeft(s, ".-")
+	if s == "" {
+		return "", ""
+	}
+
+	isDigit := unicode.IsDigit(rune(s[0]))
+
+	end := 0
+	for end < len(s) {
+		c := rune(s[end])
+		if c == '.' || c == '-' || unicode.IsDigit(c) != isDigit {
+			break
+		}
+		end++
+	}
+
+	return s[:end], s[end:]
+}
+
+func componentLessThan(c1 string, c2 string) bool {
+	n1, err1 := strconv.Atoi(c1)
+	n2, err2 := strconv.Atoi(c2)
+	isNum1 := err1 == nil
+	isNum2 := err2 == nil
+
+	switch {
+	case isNum1 && isNum2:
+		return n1 < n2
+	case c1 == "" && isNum2:
+		return true
+	case c1 == "pre" && c2 != "pre":
+		return true
+	case c2 == "pre":
+		return false
+	// Assume that "2.3a" < "2.3.1".
+	case isNum2:
+		return true
+	case isNum1:
+		return false
+	default:
+		return c1 < c2
+	}
+}
@@ -3,6 +3,7 @@ package nixopts
 import (
 	"fmt"
 	"reflect"
+	"slices"
 	"sort"
 
 	"github.com/spf13/pflag"
@@ -44,7 +45,34 @@ func getNixFlag(name string) string {
 	panic("unknown option '" + name + "' when trying to convert to nix options struct")
 }
 
+// Options that are accepted by commands that only realise store
+// paths, rather than evaluate them (i.e. `nix-store --realise`).
+var buildOptions = []string{
+	"quiet",
+	"keep-going",
+	"keep-failed",
+	"fallback",
+	"repair",
+	"max-jobs",
+	"cores",
+	"log-format",
+	"option",
+	"builders",
+}
+
 func NixOptionsToArgsList(flags *pflag.FlagSet, options any) []string {
+	return nixOptionsToArgsList(flags, options, func(string) bool { return true })
+}
+
+// Like NixOptionsToArgsList, but only converts options that are
+// accepted by commands that realise derivations.
+func NixBuildOptionsToArgsList(flags *pflag.FlagSet, options any) []string {
+	return nixOptionsToArgsList(flags, options, func(name string) bool {
+		return slices.Contains(buildOptions, name)
+	})
+}
+
+func nixOptionsToArgsList(flags *pflag.FlagSet, options any, include func(name string) bool) []string {
 	val := reflect.ValueOf(options)
 	typ := reflect.TypeOf(options)
 
@@ -60,7 +88,7 @@ func NixOptionsToArgsList(flags *pflag.FlagSet, options any) []string {
 		fieldType := typ.Field(i)
 		fieldName := getNixFlag(fieldType.Name)
 
-		if !flags.Changed(fieldName) {
+		if !include(fieldName) || !flags.Changed(fieldName) {
 			continue
 		}
 
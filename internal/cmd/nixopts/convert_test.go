@@ -98,3 +98,18 @@ func TestNixOptionsToArgsList(t *testing.T) {
 		})
 	}
 }
+
+func TestNixBuildOptionsToArgsList(t *testing.T) {
+	cmd, opts := createTestCmd()
+
+	cmd.SetArgs([]string{"--print-build-logs", "--max-jobs", "2", "--log-format", "bar", "--option", "option1=value1"})
+	_ = cmd.Execute()
+
+	expected := []string{"--max-jobs", "2", "--log-format", "bar", "--option", "option1", "value1"}
+
+	args := nixopts.NixBuildOptionsToArgsList(cmd.Flags(), opts)
+
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("NixBuildOptionsToArgsList() = %v, want %v", args, expected)
+	}
+}
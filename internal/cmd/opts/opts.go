@@ -5,6 +5,7 @@ import "github.com/nix-community/nixos-cli/internal/configuration"
 type MainOpts struct {
 	ColorAlways  bool
 	ConfigValues map[string]string
+	OutputFormat string
 }
 
 type AliasesOpts struct {
@@ -28,6 +29,19 @@ type ApplyOpts struct {
 	BuildVMWithBootloader bool
 	AlwaysConfirm         bool
 	FlakeRef              string
+	FlakeRefs             []string
+	File                  string
+	Attr                  string
+	Parallel              int
+	BuildHost             string
+	TargetHost            string
+	UseRemoteRoot         bool
+	Confirm               bool
+	ConfirmTimeout        string
+	WaitForLock           bool
+	UpdateFlakeInputs     []string
+	RebootIfNeeded        string
+	PushTo                string
 
 	NixOptions ApplyNixOptions
 }
@@ -61,6 +75,17 @@ type ApplyNixOptions struct {
 	OverrideInputs   map[string]string
 }
 
+type AutoUpgradeOpts struct {
+	FlakeRef    string
+	DisplayJson bool
+	NoDelay     bool
+	Status      bool
+	Verbose     bool
+	WaitForLock bool
+
+	NixOptions ApplyNixOptions
+}
+
 type EnterOpts struct {
 	Command      string
 	CommandArray []string
@@ -70,6 +95,34 @@ type EnterOpts struct {
 	Verbose      bool
 }
 
+type EvalOpts struct {
+	Attribute       string
+	Apply           string
+	DisplayJson     bool
+	Raw             bool
+	FlakeRef        string
+	File            string
+	Attr            string
+	NixPathIncludes []string
+
+	NixOptions EvalNixOptions
+}
+
+type EvalNixOptions struct {
+	Quiet     bool
+	ShowTrace bool
+	Refresh   bool
+	Impure    bool
+	Offline   bool
+	NoNet     bool
+	Options   map[string]string
+
+	NoUpdateLockFile bool
+	NoWriteLockFile  bool
+	NoUseRegistries  bool
+	OverrideInputs   map[string]string
+}
+
 type FeaturesOpts struct {
 	DisplayJson bool
 }
@@ -79,9 +132,11 @@ type GenerationOpts struct {
 }
 
 type GenerationDiffOpts struct {
-	Before  uint
-	After   uint
-	Verbose bool
+	Before      uint
+	After       uint
+	DisplayJson bool
+	Inputs      bool
+	Verbose     bool
 }
 
 type GenerationDeleteOpts struct {
@@ -96,13 +151,30 @@ type GenerationDeleteOpts struct {
 	AlwaysConfirm bool
 	// This ideally should be a uint64 to match types,
 	// but Cobra's pflags does not support this type yet.
-	Remove  []uint
-	Verbose bool
+	Remove      []uint
+	Verbose     bool
+	WaitForLock bool
 }
 
 type GenerationListOpts struct {
 	DisplayJson  bool
 	DisplayTable bool
+	ComputeSizes bool
+}
+
+type GenerationPinOpts struct {
+	Generation uint
+	Reason     string
+}
+
+type GenerationUnpinOpts struct {
+	Generation uint
+}
+
+type GenerationTagOpts struct {
+	Generation  uint
+	Description string
+	Clear       bool
 }
 
 type GenerationSwitchOpts struct {
@@ -111,6 +183,7 @@ type GenerationSwitchOpts struct {
 	Verbose        bool
 	AlwaysConfirm  bool
 	Generation     uint
+	WaitForLock    bool
 }
 
 type GenerationRollbackOpts struct {
@@ -118,6 +191,25 @@ type GenerationRollbackOpts struct {
 	Specialisation string
 	Verbose        bool
 	AlwaysConfirm  bool
+	WaitForLock    bool
+}
+
+type HistoryOpts struct {
+	DisplayJson bool
+	Limit       int
+	Since       string
+	User        string
+	Command     string
+	Profile     string
+	OnlyFailed  bool
+}
+
+type InputsOpts struct {
+	FlakeRef    string
+	DisplayJson bool
+	Inputs      []string
+	MaxAge      string
+	Verbose     bool
 }
 
 type InfoOpts struct {
@@ -140,7 +232,9 @@ type InstallOpts struct {
 	NoRootPassword bool
 	Root           string
 	SystemClosure  string
+	BuildHost      string
 	Verbose        bool
+	WaitForLock    bool
 	FlakeRef       *configuration.FlakeRef
 
 	NixOptions struct {
@@ -181,9 +275,38 @@ type OptionOpts struct {
 	MinScore         int64
 	OptionInput      string
 	FlakeRef         string
+	File             string
+	Attr             string
 }
 
 type ReplOpts struct {
 	NixPathIncludes []string
 	FlakeRef        string
+	File            string
+	Attr            string
+}
+
+type SpecialisationOpts struct {
+	ProfileName string
+}
+
+type SpecialisationListOpts struct {
+	DisplayJson bool
+	Generation  uint
+}
+
+type SpecialisationSwitchOpts struct {
+	Base           bool
+	Dry            bool
+	Specialisation string
+	Verbose        bool
+	AlwaysConfirm  bool
+	WaitForLock    bool
+}
+
+type SpecialisationDiffOpts struct {
+	DisplayJson    bool
+	Generation     uint
+	Specialisation string
+	Verbose        bool
 }
@@ -1,11 +1,15 @@
 package cmdUtils
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
 	"github.com/fatih/color"
+	"golang.org/x/sys/unix"
 )
 
 func ConfirmationInput(msg string) (bool, error) {
@@ -26,3 +30,46 @@ func ConfirmationInput(msg string) (bool, error) {
 
 	return input[0] == 'y', nil
 }
+
+// Same as ConfirmationInput, but stops waiting for input once `ctx` is
+// done. Blocking reads from stdin cannot be interrupted, so stdin is
+// polled instead, and only read from once a line is available.
+func ConfirmationInputContext(ctx context.Context, msg string) (bool, error) {
+	fmt.Fprintf(os.Stderr, "%s\n[y/n]: ", color.GreenString("|> %s", msg))
+
+	fd := int(os.Stdin.Fd())
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+
+	var line []byte
+	buf := make([]byte, 256)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+
+		n, err := unix.Poll(fds, 100)
+		if err == unix.EINTR || n == 0 {
+			continue
+		} else if err != nil {
+			return false, err
+		}
+
+		n, err = unix.Read(fd, buf)
+		if err == unix.EINTR || err == unix.EAGAIN {
+			continue
+		} else if err != nil {
+			return false, err
+		}
+		if n == 0 {
+			return false, io.EOF
+		}
+
+		line = append(line, buf[:n]...)
+
+		if input, _, found := bytes.Cut(line, []byte("\n")); found {
+			input = bytes.ToLower(bytes.TrimSpace(input))
+			return len(input) > 0 && input[0] == 'y', nil
+		}
+	}
+}
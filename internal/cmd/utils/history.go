@@ -0,0 +1,18 @@
+package cmdUtils
+
+import (
+	"github.com/nix-community/nixos-cli/internal/history"
+	"github.com/nix-community/nixos-cli/internal/logger"
+	"github.com/nix-community/nixos-cli/internal/system"
+)
+
+// Finish a history record with the result of a command, and append
+// it to the journal on the given system. Failing to do so is not
+// fatal, since the command itself has already finished.
+func WriteHistoryRecord(log *logger.Logger, s system.System, record *history.Record, err error) {
+	record.Finish(err)
+
+	if err := history.Append(s, record); err != nil {
+		log.Warnf("failed to record command in activation history: %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package cmdUtils
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/nix-community/nixos-cli/internal/lock"
+	"github.com/nix-community/nixos-cli/internal/logger"
+)
+
+// Acquire the lock that guards operations that modify system
+// profiles, such as activating or deleting generations. This
+// lock file is located relative to the given root directory.
+//
+// If the lock is already held by another process, then an error
+// is returned, unless wait is true; in that case, this blocks
+// until the other process releases the lock.
+func AcquireOperationLock(log *logger.Logger, root string, wait bool) (*lock.Lock, error) {
+	lockPath := filepath.Join(root, lock.OperationLockFile)
+
+	l, err := lock.TryAcquire(lockPath)
+
+	var heldErr *lock.HeldError
+	if errors.As(err, &heldErr) {
+		if heldErr.IsStale() {
+			log.Warnf("the process holding %v is no longer running, but the lock is still held", lockPath)
+			log.Warnf("this usually means a child process of it is still running")
+		}
+
+		if !wait {
+			if heldErr.Holder != nil {
+				log.Errorf("another operation is in progress: held by %v", heldErr.Holder)
+			} else {
+				log.Errorf("another operation is in progress")
+			}
+			log.Info("use --wait to wait for it to finish")
+			return nil, heldErr
+		}
+
+		if heldErr.Holder != nil {
+			log.Infof("waiting for another operation to finish: held by %v", heldErr.Holder)
+		} else {
+			log.Info("waiting for another operation to finish")
+		}
+
+		l, err = lock.Acquire(lockPath)
+	}
+
+	if err != nil {
+		log.Errorf("failed to acquire lock %v: %v", lockPath, err)
+		return nil, err
+	}
+
+	if l.Stale != nil {
+		log.Warnf("a previous operation did not exit cleanly: held by %v", l.Stale)
+		log.Warn("the system profile may be in an inconsistent state, check `nixos generation list`")
+	}
+
+	return l, nil
+}
+
+// Release a lock acquired with AcquireOperationLock,
+// and warn if it could not be released cleanly.
+func ReleaseOperationLock(log *logger.Logger, l *lock.Lock) {
+	if err := l.Release(); err != nil {
+		log.Warnf("failed to release lock %v: %v", l.Path, err)
+	}
+}
@@ -14,13 +14,48 @@ func SetHelpFlagText(cmd *cobra.Command) {
 
 var CommandError = errors.New("command error")
 
-// Replace a returned error with the generic CommandError, and.
+var commandErrorHooks []func(err error)
+
+// Register a function to call with the original error right
+// before CommandErrorHandler exits the program.
+func OnCommandError(hook func(err error)) {
+	commandErrorHooks = append(commandErrorHooks, hook)
+}
+
+// An error that causes the program to exit with a specific exit code,
+// for commands whose exit codes are meant to be checked by scripts.
+type ExitCodeError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitCodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitCodeError) Unwrap() error {
+	return e.Err
+}
+
+// Replace a returned error with the generic CommandError, and
 // exit with a non-zero exit code. This is to avoid extra error
 // messages being printed when a command function defined with
 // RunE returns a non-nil error.
+//
+// The exit code is 1, unless the error is an *ExitCodeError.
 func CommandErrorHandler(err error) error {
 	if err != nil {
-		os.Exit(1)
+		for _, hook := range commandErrorHooks {
+			hook(err)
+		}
+
+		code := 1
+		var exitErr *ExitCodeError
+		if errors.As(err, &exitErr) && exitErr.Code != 0 {
+			code = exitErr.Code
+		}
+
+		os.Exit(code)
 
 		return CommandError
 	}
@@ -2,6 +2,8 @@ package configuration
 
 import (
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/nix-community/nixos-cli/internal/build"
 	"github.com/nix-community/nixos-cli/internal/logger"
@@ -16,6 +18,14 @@ type SystemBuildOptions struct {
 	UseNom         bool
 	GenerationTag  string
 	Verbose        bool
+	// Render build progress natively from Nix's internal JSON log
+	// format. This is ignored if UseNom is set, or if stdout and
+	// stderr are not terminals.
+	ShowProgress bool
+	// Write the output of the build command here instead of to
+	// stderr, such as when building several systems at once.
+	// Progress is never rendered when this is set.
+	Stderr io.Writer
 
 	// Command-line flags that were passed for the command context.
 	// This is needed to determine the proper Nix options to pass
@@ -24,11 +34,37 @@ type SystemBuildOptions struct {
 	NixOpts   any
 	Env       map[string]string
 	ExtraArgs []string
+
+	// Realise the system on this host instead of with the configured
+	// builder. Evaluation still happens using the builder, and the
+	// result is copied back to it afterwards.
+	BuildHost *system.SSHSystem
+	// When building on a build host, copy the result directly to this
+	// host instead of back to the builder.
+	TargetHost *system.SSHSystem
+}
+
+type EvalOptions struct {
+	// Print the value as JSON instead of as a Nix expression.
+	JSON bool
+	// Print the value, which must be a string, without quotes
+	// or escaping. This takes precedence over JSON.
+	Raw bool
+	// Nix function to apply to the value before printing it
+	Apply string
+
+	// Command-line flags that were passed for the command context,
+	// and the Nix options to pass through when evaluating, if any.
+	CmdFlags *pflag.FlagSet
+	NixOpts  any
 }
 
 type Configuration interface {
 	SetBuilder(builder system.CommandRunner)
-	EvalAttribute(attr string) (*string, error)
+	// Evaluate an attribute path inside the `config` attribute of
+	// this configuration. A nil `opts` prints the value as a Nix
+	// expression without any extra Nix options.
+	EvalAttribute(attr string, opts *EvalOptions) (*string, error)
 	BuildSystem(buildType SystemBuildType, opts *SystemBuildOptions) (string, error)
 }
 
@@ -42,6 +78,28 @@ func (e *AttributeEvaluationError) Error() string {
 }
 
 func FindConfiguration(log *logger.Logger, cfg *settings.Settings, includes []string, verbose bool) (Configuration, error) {
+	if cfg.ConfigAttr != "" {
+		if verbose {
+			log.Info("config_attr is set, looking for file configuration")
+		}
+
+		filename := cfg.ConfigLocation
+		if nixosCfg, set := os.LookupEnv("NIXOS_CONFIG"); set {
+			filename = nixosCfg
+		}
+
+		c, err := FileConfigurationFromPath(filename, cfg.ConfigAttr, includes)
+		if err != nil {
+			return nil, err
+		}
+
+		if verbose {
+			log.Infof("found file configuration: %s", c)
+		}
+
+		return c, nil
+	}
+
 	if buildOpts.Flake == "true" {
 		if verbose {
 			log.Info("looking for flake configuration")
@@ -57,7 +115,7 @@ func FindConfiguration(log *logger.Logger, cfg *settings.Settings, includes []st
 		}
 
 		if verbose {
-			log.Infof("found flake configuration: %s#%s", f.URI, f.System)
+			log.Infof("found flake configuration: %s", f)
 		}
 
 		return f, nil
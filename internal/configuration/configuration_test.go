@@ -1,6 +1,8 @@
 package configuration_test
 
 import (
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -54,6 +56,41 @@ func TestFlakeRefFromString(t *testing.T) {
 				System: "linux#extra",
 			},
 		},
+		{
+			input: `.#"my.host"`,
+			expected: &configuration.FlakeRef{
+				URI:    ".",
+				System: "my.host",
+			},
+		},
+		{
+			input: ".#%22my.host%22",
+			expected: &configuration.FlakeRef{
+				URI:    ".",
+				System: "my.host",
+			},
+		},
+		{
+			input: "/etc/nixos#nixosConfigurations.linux",
+			expected: &configuration.FlakeRef{
+				URI:    "/etc/nixos",
+				System: "linux",
+			},
+		},
+		{
+			input: "/etc/nixos#my.host",
+			expected: &configuration.FlakeRef{
+				URI:    "/etc/nixos",
+				System: "my.host",
+			},
+		},
+		{
+			input: "git+https://example.com/repo?ref=main&dir=hosts#linux",
+			expected: &configuration.FlakeRef{
+				URI:    "git+https://example.com/repo?ref=main&dir=hosts",
+				System: "linux",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -66,3 +103,124 @@ func TestFlakeRefFromString(t *testing.T) {
 		})
 	}
 }
+
+func TestFlakeRefString(t *testing.T) {
+	tests := []struct {
+		ref      *configuration.FlakeRef
+		expected string
+		attr     string
+	}{
+		{&configuration.FlakeRef{URI: "github:owner/repo", System: "linux"}, "github:owner/repo#linux", "nixosConfigurations.linux"},
+		{&configuration.FlakeRef{URI: ".", System: "my.host"}, `.#"my.host"`, `nixosConfigurations."my.host"`},
+		{&configuration.FlakeRef{URI: "/etc/nixos", System: ""}, "/etc/nixos", `nixosConfigurations.""`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if actual := tt.ref.String(); actual != tt.expected {
+				t.Errorf("String() = %v, want %v", actual, tt.expected)
+			}
+
+			if actual := tt.ref.SystemAttribute(); actual != tt.attr {
+				t.Errorf("SystemAttribute() = %v, want %v", actual, tt.attr)
+			}
+
+			if tt.ref.System != "" {
+				parsed := configuration.FlakeRefFromString(tt.ref.String())
+				if parsed.URI != tt.ref.URI || parsed.System != tt.ref.System {
+					t.Errorf("FlakeRefFromString(%q) = %+v, want %+v", tt.ref.String(), parsed, tt.ref)
+				}
+			}
+		})
+	}
+}
+
+func TestFlakeRefMakeAbsolute(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		uri      string
+		expected string
+	}{
+		{".", cwd},
+		{"./hosts?dir=sub", filepath.Join(cwd, "hosts") + "?dir=sub"},
+		{"path:../config", "path:" + filepath.Join(filepath.Dir(cwd), "config")},
+		{"/etc/nixos", "/etc/nixos"},
+		{"git+file:///etc/nixos", "git+file:///etc/nixos"},
+		{"github:owner/repo", "github:owner/repo"},
+		{"nixpkgs", "nixpkgs"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			ref := &configuration.FlakeRef{URI: tt.uri, System: "linux"}
+			if err := ref.MakeAbsolute(); err != nil {
+				t.Fatalf("MakeAbsolute() returned error: %v", err)
+			}
+
+			if ref.URI != tt.expected {
+				t.Errorf("MakeAbsolute(%q) = %v, want %v", tt.uri, ref.URI, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFileConfigurationFromPath(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "hosts.nix")
+	if err := os.WriteFile(file, []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "default.nix"), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		filename string
+		dirname  string
+		ok       bool
+	}{
+		{file, dir, true},
+		{dir, dir, true},
+		{filepath.Join(dir, "empty"), "", false},
+		{filepath.Join(dir, "nonexistent.nix"), "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			c, err := configuration.FileConfigurationFromPath(tt.filename, "hosts.web", nil)
+			if (err == nil) != tt.ok {
+				t.Fatalf("FileConfigurationFromPath(%q) error = %v, want ok = %v", tt.filename, err, tt.ok)
+			}
+			if err != nil {
+				return
+			}
+
+			if c.Filename != tt.filename {
+				t.Errorf("Filename = %v, want %v", c.Filename, tt.filename)
+			}
+			if c.Dirname() != tt.dirname {
+				t.Errorf("Dirname() = %v, want %v", c.Dirname(), tt.dirname)
+			}
+		})
+	}
+}
+
+func TestFileConfigurationSystemExpression(t *testing.T) {
+	c := &configuration.FileConfiguration{Filename: "/etc/nixos/hosts.nix"}
+	expected := `(let f = import (/. + "/etc/nixos/hosts.nix"); in if builtins.isFunction f then f {} else f)`
+	if actual := c.SystemExpression(); actual != expected {
+		t.Errorf("SystemExpression() = %v, want %v", actual, expected)
+	}
+
+	c.Attr = "hosts.web"
+	if actual := c.SystemExpression(); actual != expected+".hosts.web" {
+		t.Errorf("SystemExpression() = %v, want %v", actual, expected+".hosts.web")
+	}
+}
@@ -0,0 +1,302 @@
+package configuration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/nix-community/nixos-cli/internal/cmd/nixopts"
+	"github.com/nix-community/nixos-cli/internal/flake"
+	"github.com/nix-community/nixos-cli/internal/system"
+)
+
+// A NixOS configuration defined at an attribute path inside an
+// arbitrary Nix file, such as a `default.nix` that pins its own
+// sources, rather than through a flake or the `nixos-config`
+// search path entry.
+type FileConfiguration struct {
+	// Absolute path to the Nix file, or to a directory
+	// containing a `default.nix` file.
+	Filename string
+	// Attribute path of the NixOS system inside the file, or
+	// empty if the file evaluates to the system itself.
+	Attr     string
+	Includes []string
+
+	// Builder is used to build the system. They must have Nix installed.
+	Builder system.CommandRunner
+}
+
+func FileConfigurationFromPath(filename string, attr string, includes []string) (*FileConfiguration, error) {
+	if filename == "" {
+		filename = "."
+	}
+
+	filename, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		defaultNix := filepath.Join(filename, "default.nix")
+		if _, err := os.Stat(defaultNix); err != nil {
+			return nil, err
+		}
+	}
+
+	return &FileConfiguration{
+		Filename: filename,
+		Attr:     attr,
+		Includes: includes,
+	}, nil
+}
+
+func (c *FileConfiguration) String() string {
+	if c.Attr == "" {
+		return c.Filename
+	}
+
+	return fmt.Sprintf("%s -A %s", c.Filename, c.Attr)
+}
+
+// Get the directory that contains the Nix file.
+func (c *FileConfiguration) Dirname() string {
+	if info, err := os.Stat(c.Filename); err == nil && info.IsDir() {
+		return c.Filename
+	}
+
+	return filepath.Dir(c.Filename)
+}
+
+func (c *FileConfiguration) SetBuilder(builder system.CommandRunner) {
+	c.Builder = builder
+}
+
+// Get a Nix expression that evaluates to the NixOS system, in the same
+// way that `nix-build` does: if the file evaluates to a function, it is
+// called with an empty attribute set first.
+func (c *FileConfiguration) SystemExpression() string {
+	expr := fmt.Sprintf("(let f = import (/. + %s); in if builtins.isFunction f then f {} else f)", flake.QuoteString(c.Filename))
+
+	if c.Attr != "" {
+		expr += "." + c.Attr
+	}
+
+	return expr
+}
+
+// Join the attribute path of the system with an attribute path inside it.
+func (c *FileConfiguration) attrPath(attr string) string {
+	if c.Attr == "" {
+		return attr
+	}
+
+	return c.Attr + "." + attr
+}
+
+func (c *FileConfiguration) EvalAttribute(attr string, opts *EvalOptions) (*string, error) {
+	return evalNixFile(c.Filename, c.SystemExpression(), c.attrPath("config."+attr), c.Includes, attr, opts)
+}
+
+func (c *FileConfiguration) BuildSystem(buildType SystemBuildType, opts *SystemBuildOptions) (string, error) {
+	if c.Builder == nil {
+		panic("FileConfiguration.Builder is nil")
+	}
+
+	// The toplevel attribute is named differently for legacy
+	// configurations, but this is always a full system.
+	buildAttr := buildType.BuildAttr()
+	if buildType.IsSystem() {
+		buildAttr = "toplevel"
+	}
+
+	target := []string{c.Filename, "-A", c.attrPath("config.system.build." + buildAttr)}
+
+	return buildNixFile(c.Builder, target, buildType, opts)
+}
+
+// Evaluate an attribute path inside a Nix file using `nix-instantiate`.
+// `file` is passed on the command line as-is, and `fileExpr` is a Nix
+// expression that evaluates to the same value, for use with --apply.
+// `name` is the attribute name to report in evaluation errors.
+func evalNixFile(file string, fileExpr string, attrPath string, includes []string, name string, opts *EvalOptions) (*string, error) {
+	if opts == nil {
+		opts = &EvalOptions{}
+	}
+
+	var argv []string
+	if opts.Apply != "" {
+		expr := fmt.Sprintf("(%s) (%s).%s", opts.Apply, fileExpr, attrPath)
+		argv = []string{"nix-instantiate", "--eval", "--expr", expr}
+	} else {
+		argv = []string{"nix-instantiate", "--eval", file, "-A", attrPath}
+	}
+
+	// `nix-instantiate` cannot print raw strings on all supported
+	// Nix versions, so they are decoded from JSON output instead.
+	if opts.JSON || opts.Raw {
+		argv = append(argv, "--json", "--strict")
+	}
+
+	for _, v := range includes {
+		argv = append(argv, "-I", v)
+	}
+
+	if opts.NixOpts != nil {
+		argv = append(argv, nixopts.NixOptionsToArgsList(opts.CmdFlags, opts.NixOpts)...)
+	}
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return nil, &AttributeEvaluationError{
+			Attribute:        name,
+			EvaluationOutput: strings.TrimSpace(stderr.String()),
+		}
+	}
+
+	value := strings.TrimSpace(stdout.String())
+
+	if opts.Raw {
+		var str string
+		if err := json.Unmarshal([]byte(value), &str); err != nil {
+			return nil, &AttributeEvaluationError{
+				Attribute:        name,
+				EvaluationOutput: fmt.Sprintf("error: expected a string, but got %s", value),
+			}
+		}
+		value = str
+	}
+
+	return &value, nil
+}
+
+// Build a system from a Nix file using `nix-build`, where `target` is
+// the file and attribute path arguments, such as `<nixpkgs/nixos> -A
+// system`.
+func buildNixFile(builder system.CommandRunner, target []string, buildType SystemBuildType, opts *SystemBuildOptions) (string, error) {
+	nixCommand := "nix-build"
+	if opts.UseNom {
+		nixCommand = "nom-build"
+	}
+
+	if opts.BuildHost != nil {
+		drvPath, err := instantiateNixFile(builder, target, opts)
+		if err != nil {
+			return "", err
+		}
+		return realiseOnBuildHost(builder, drvPath, opts)
+	}
+
+	argv := append([]string{nixCommand}, target...)
+
+	// Mimic `nixos-rebuild` behavior of using -k option
+	// for all commands except for switch and boot
+	if buildType != SystemBuildTypeSystemActivation {
+		argv = append(argv, "-k")
+	}
+
+	if opts.NixOpts != nil {
+		argv = append(argv, nixopts.NixOptionsToArgsList(opts.CmdFlags, opts.NixOpts)...)
+	}
+
+	if opts.ResultLocation != "" {
+		argv = append(argv, "--out-link", opts.ResultLocation)
+	} else {
+		argv = append(argv, "--no-out-link")
+	}
+
+	if opts.ExtraArgs != nil {
+		argv = append(argv, opts.ExtraArgs...)
+	}
+
+	progress := newProgressRenderer(opts)
+	if progress != nil {
+		argv = append(argv, "--log-format", "internal-json")
+	}
+
+	if opts.Verbose {
+		argv = append(argv, "-v")
+		builder.Logger().CmdArray(argv)
+	}
+
+	var stdout bytes.Buffer
+	cmd := system.NewCommand(nixCommand, argv[1:]...)
+	cmd.Stdout = &stdout
+
+	if opts.GenerationTag != "" {
+		cmd.SetEnv("NIXOS_GENERATION_TAG", opts.GenerationTag)
+	}
+
+	for k, v := range opts.Env {
+		cmd.SetEnv(k, v)
+	}
+
+	if opts.Stderr != nil {
+		cmd.Stderr = opts.Stderr
+	}
+
+	if progress != nil {
+		cmd.Stderr = progress
+		progress.Start()
+	}
+
+	_, err := builder.Run(cmd)
+
+	if progress != nil {
+		progress.Stop()
+	}
+
+	return strings.Trim(stdout.String(), "\n "), err
+}
+
+// Evaluate the system derivation without building it, and
+// return the path to the derivation in the builder's store.
+func instantiateNixFile(builder system.CommandRunner, target []string, opts *SystemBuildOptions) (string, error) {
+	argv := append([]string{"nix-instantiate"}, target...)
+
+	if opts.NixOpts != nil {
+		argv = append(argv, nixopts.NixOptionsToArgsList(opts.CmdFlags, opts.NixOpts)...)
+	}
+
+	if opts.ExtraArgs != nil {
+		argv = append(argv, opts.ExtraArgs...)
+	}
+
+	if opts.Verbose {
+		builder.Logger().CmdArray(argv)
+	}
+
+	var stdout bytes.Buffer
+	cmd := system.NewCommand(argv[0], argv[1:]...)
+	cmd.Stdout = &stdout
+
+	if opts.GenerationTag != "" {
+		cmd.SetEnv("NIXOS_GENERATION_TAG", opts.GenerationTag)
+	}
+
+	for k, v := range opts.Env {
+		cmd.SetEnv(k, v)
+	}
+
+	if _, err := builder.Run(cmd); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -2,12 +2,15 @@ package configuration
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 
 	"github.com/nix-community/nixos-cli/internal/cmd/nixopts"
+	"github.com/nix-community/nixos-cli/internal/flake"
 	"github.com/nix-community/nixos-cli/internal/system"
 )
 
@@ -19,19 +22,34 @@ type FlakeRef struct {
 	Builder system.CommandRunner
 }
 
+// Create a flake ref from a string of the form `URI#NAME`, where
+// NAME is the name of a NixOS configuration. NAME may be quoted if
+// it is not a valid identifier, such as `#"my.host"`, or given as a
+// full `nixosConfigurations.NAME` attribute path. Any other fragment
+// is used as the configuration name as-is, in the same way that
+// `nixos-rebuild` does.
 func FlakeRefFromString(s string) *FlakeRef {
-	split := strings.Index(s, "#")
+	uri, fragment := flake.SplitRef(s)
 
-	if split > -1 {
-		return &FlakeRef{
-			URI:    s[:split],
-			System: s[split+1:],
-		}
+	return &FlakeRef{
+		URI:    uri,
+		System: systemFromFragment(fragment),
 	}
+}
 
-	return &FlakeRef{
-		URI:    s,
-		System: "",
+func systemFromFragment(fragment string) string {
+	attrPath, err := flake.ParseAttrPath(fragment)
+	if err != nil {
+		return fragment
+	}
+
+	switch {
+	case len(attrPath) == 1:
+		return attrPath[0]
+	case len(attrPath) == 2 && attrPath[0] == "nixosConfigurations":
+		return attrPath[1]
+	default:
+		return fragment
 	}
 }
 
@@ -65,10 +83,88 @@ func (f *FlakeRef) SetBuilder(builder system.CommandRunner) {
 	f.Builder = builder
 }
 
-func (f *FlakeRef) EvalAttribute(attr string) (*string, error) {
-	evalArg := fmt.Sprintf(`%s#nixosConfigurations.%s.config.%s`, f.URI, f.System, attr)
+// Format the flake ref in a form that can be parsed again.
+func (f *FlakeRef) String() string {
+	if f.System == "" {
+		return f.URI
+	}
+
+	return f.URI + "#" + flake.QuoteAttr(f.System)
+}
+
+// Get the attribute path of this configuration inside the flake,
+// with the system name quoted if needed.
+func (f *FlakeRef) SystemAttribute() string {
+	return flake.FormatAttrPath([]string{"nixosConfigurations", f.System})
+}
+
+// Find the directory of this flake on the local filesystem, if it
+// refers to one. This includes the `dir` parameter of the URI.
+func (f *FlakeRef) LocalDirectory() (string, bool) {
+	return flake.LocalDirectory(f.URI)
+}
+
+// Make a relative local path in the flake URI absolute, so that it
+// still refers to the same flake after changing directories or when
+// it is passed to `builtins.getFlake`. Other URIs, including ones
+// that cannot be parsed, are left as-is for Nix to handle.
+func (f *FlakeRef) MakeAbsolute() error {
+	u, err := flake.ParseURL(f.URI)
+	if err != nil || u.Type != flake.URLTypePath {
+		return nil
+	}
+
+	prefix := ""
+	rest := f.URI
+	if strings.HasPrefix(rest, "path:") {
+		prefix = "path:"
+		rest = strings.TrimPrefix(rest, "path:")
+	}
+
+	path, query, hasQuery := strings.Cut(rest, "?")
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		path = filepath.Join(home, strings.TrimPrefix(path, "~"))
+	}
+
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	f.URI = prefix + path
+	if hasQuery {
+		f.URI += "?" + query
+	}
+
+	return nil
+}
+
+func (f *FlakeRef) EvalAttribute(attr string, opts *EvalOptions) (*string, error) {
+	if opts == nil {
+		opts = &EvalOptions{}
+	}
+
+	evalArg := fmt.Sprintf(`%s#%s.config.%s`, f.URI, f.SystemAttribute(), attr)
 	argv := []string{"nix", "eval", evalArg}
 
+	if opts.Raw {
+		argv = append(argv, "--raw")
+	} else if opts.JSON {
+		argv = append(argv, "--json")
+	}
+
+	if opts.Apply != "" {
+		argv = append(argv, "--apply", opts.Apply)
+	}
+
+	if opts.NixOpts != nil {
+		argv = append(argv, nixopts.NixOptionsToArgsList(opts.CmdFlags, opts.NixOpts)...)
+	}
+
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
 
@@ -84,18 +180,65 @@ func (f *FlakeRef) EvalAttribute(attr string) (*string, error) {
 		}
 	}
 
-	value := strings.TrimSpace(stdout.String())
+	// Raw strings are printed verbatim, so any surrounding
+	// whitespace is part of the value.
+	value := stdout.String()
+	if !opts.Raw {
+		value = strings.TrimSpace(value)
+	}
 
 	return &value, nil
 }
 
+// List the names of all NixOS configurations that this flake provides,
+// ignoring the system that this flake ref points to, if any.
+func (f *FlakeRef) ListSystems(verbose bool) ([]string, error) {
+	argv := []string{"nix", "eval", "--json", f.URI + "#nixosConfigurations", "--apply", "builtins.attrNames"}
+
+	if f.Builder == nil {
+		panic("FlakeRef.Builder is nil")
+	}
+
+	if verbose {
+		f.Builder.Logger().CmdArray(argv)
+	}
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd := system.NewCommand(argv[0], argv[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if _, err := f.Builder.Run(cmd); err != nil {
+		return nil, &AttributeEvaluationError{
+			Attribute:        "nixosConfigurations",
+			EvaluationOutput: strings.TrimSpace(stderr.String()),
+		}
+	}
+
+	var names []string
+	if err := json.Unmarshal(stdout.Bytes(), &names); err != nil {
+		return nil, fmt.Errorf("failed to parse configuration names: %w", err)
+	}
+
+	return names, nil
+}
+
 func (f *FlakeRef) BuildSystem(buildType SystemBuildType, opts *SystemBuildOptions) (string, error) {
 	nixCommand := "nix"
 	if opts.UseNom {
 		nixCommand = "nom"
 	}
 
-	systemAttribute := fmt.Sprintf("%s#nixosConfigurations.%s.config.system.build.%s", f.URI, f.System, buildType.BuildAttr())
+	systemAttribute := fmt.Sprintf("%s#%s.config.system.build.%s", f.URI, f.SystemAttribute(), buildType.BuildAttr())
+
+	if opts.BuildHost != nil {
+		drvPath, err := f.instantiateSystem(systemAttribute, opts)
+		if err != nil {
+			return "", err
+		}
+		return realiseOnBuildHost(f.Builder, drvPath, opts)
+	}
 
 	argv := []string{nixCommand, "build", systemAttribute, "--print-out-paths"}
 
@@ -117,6 +260,11 @@ func (f *FlakeRef) BuildSystem(buildType SystemBuildType, opts *SystemBuildOptio
 		argv = append(argv, opts.ExtraArgs...)
 	}
 
+	progress := newProgressRenderer(opts)
+	if progress != nil {
+		argv = append(argv, "--log-format", "internal-json")
+	}
+
 	if opts.Verbose {
 		argv = append(argv, "-v")
 		f.Builder.Logger().CmdArray(argv)
@@ -138,7 +286,60 @@ func (f *FlakeRef) BuildSystem(buildType SystemBuildType, opts *SystemBuildOptio
 		panic("FlakeRef.Builder is nil")
 	}
 
+	if opts.Stderr != nil {
+		cmd.Stderr = opts.Stderr
+	}
+
+	if progress != nil {
+		cmd.Stderr = progress
+		progress.Start()
+	}
+
 	_, err := f.Builder.Run(cmd)
 
+	if progress != nil {
+		progress.Stop()
+	}
+
 	return strings.Trim(stdout.String(), "\n "), err
 }
+
+// Evaluate the system derivation without building it, and
+// return the path to the derivation in the builder's store.
+func (f *FlakeRef) instantiateSystem(systemAttribute string, opts *SystemBuildOptions) (string, error) {
+	argv := []string{"nix", "eval", "--raw", systemAttribute + ".drvPath"}
+
+	if opts.NixOpts != nil {
+		argv = append(argv, nixopts.NixOptionsToArgsList(opts.CmdFlags, opts.NixOpts)...)
+	}
+
+	if opts.ExtraArgs != nil {
+		argv = append(argv, opts.ExtraArgs...)
+	}
+
+	if f.Builder == nil {
+		panic("FlakeRef.Builder is nil")
+	}
+
+	if opts.Verbose {
+		f.Builder.Logger().CmdArray(argv)
+	}
+
+	var stdout bytes.Buffer
+	cmd := system.NewCommand(argv[0], argv[1:]...)
+	cmd.Stdout = &stdout
+
+	if opts.GenerationTag != "" {
+		cmd.SetEnv("NIXOS_GENERATION_TAG", opts.GenerationTag)
+	}
+
+	for k, v := range opts.Env {
+		cmd.SetEnv(k, v)
+	}
+
+	if _, err := f.Builder.Run(cmd); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -1,14 +1,11 @@
 package configuration
 
 import (
-	"bytes"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
-	"github.com/nix-community/nixos-cli/internal/cmd/nixopts"
 	"github.com/nix-community/nixos-cli/internal/logger"
 	"github.com/nix-community/nixos-cli/internal/system"
 )
@@ -89,84 +86,16 @@ func (l *LegacyConfiguration) SetBuilder(builder system.CommandRunner) {
 	l.Builder = builder
 }
 
-func (l *LegacyConfiguration) EvalAttribute(attr string) (*string, error) {
-	configAttr := fmt.Sprintf("config.%s", attr)
-	argv := []string{"nix-instantiate", "--eval", "<nixpkgs/nixos>", "-A", configAttr}
-
-	for _, v := range l.Includes {
-		argv = append(argv, "-I", v)
-	}
-
-	var stdout bytes.Buffer
-	var stderr bytes.Buffer
-
-	cmd := exec.Command(argv[0], argv[1:]...)
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	err := cmd.Run()
-	if err != nil {
-		return nil, &AttributeEvaluationError{
-			Attribute:        attr,
-			EvaluationOutput: strings.TrimSpace(stderr.String()),
-		}
-	}
-
-	value := strings.TrimSpace(stdout.String())
-
-	return &value, nil
+func (l *LegacyConfiguration) EvalAttribute(attr string, opts *EvalOptions) (*string, error) {
+	return evalNixFile("<nixpkgs/nixos>", "import <nixpkgs/nixos> {}", "config."+attr, l.Includes, attr, opts)
 }
 
 func (l *LegacyConfiguration) BuildSystem(buildType SystemBuildType, opts *SystemBuildOptions) (string, error) {
-	nixCommand := "nix-build"
-	if opts.UseNom {
-		nixCommand = "nom-build"
-	}
-
-	argv := []string{nixCommand, "<nixpkgs/nixos>", "-A", buildType.BuildAttr()}
-
-	// Mimic `nixos-rebuild` behavior of using -k option
-	// for all commands except for switch and boot
-	if buildType != SystemBuildTypeSystemActivation {
-		argv = append(argv, "-k")
-	}
-
-	if opts.NixOpts != nil {
-		argv = append(argv, nixopts.NixOptionsToArgsList(opts.CmdFlags, opts.NixOpts)...)
-	}
-
-	if opts.ResultLocation != "" {
-		argv = append(argv, "--out-link", opts.ResultLocation)
-	} else {
-		argv = append(argv, "--no-out-link")
-	}
-
-	if opts.ExtraArgs != nil {
-		argv = append(argv, opts.ExtraArgs...)
-	}
-
-	if opts.Verbose {
-		argv = append(argv, "-v")
-		l.Builder.Logger().CmdArray(argv)
-	}
-
-	var stdout bytes.Buffer
-	cmd := system.NewCommand(nixCommand, argv[1:]...)
-	cmd.Stdout = &stdout
-
-	if opts.GenerationTag != "" {
-		cmd.SetEnv("NIXOS_GENERATION_TAG", opts.GenerationTag)
-	}
-
 	if l.Builder == nil {
 		panic("LegacyConfiguration.Builder is nil")
 	}
 
-	for k, v := range opts.Env {
-		cmd.SetEnv(k, v)
-	}
-
-	_, err := l.Builder.Run(cmd)
+	target := []string{"<nixpkgs/nixos>", "-A", buildType.BuildAttr()}
 
-	return strings.Trim(stdout.String(), "\n "), err
+	return buildNixFile(l.Builder, target, buildType, opts)
 }
@@ -0,0 +1,36 @@
+package configuration
+
+import (
+	"os"
+
+	"github.com/nix-community/nixos-cli/internal/nixlog"
+	"golang.org/x/term"
+)
+
+// Create a renderer for build progress if it was requested and can
+// be displayed, or nil if Nix's own output should be used instead.
+//
+// When this returns a renderer, `--log-format internal-json` must be
+// passed to the build command, and its stderr written to the renderer.
+func newProgressRenderer(opts *SystemBuildOptions) *nixlog.Renderer {
+	if !opts.ShowProgress || opts.UseNom || opts.Stderr != nil {
+		return nil
+	}
+
+	if !term.IsTerminal(int(os.Stdout.Fd())) || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return nil
+	}
+
+	printBuildLogs := false
+	if opts.CmdFlags != nil {
+		// An explicit log format means the user wants Nix's own output.
+		if opts.CmdFlags.Changed("log-format") {
+			return nil
+		}
+		printBuildLogs, _ = opts.CmdFlags.GetBool("print-build-logs")
+	}
+
+	return nixlog.NewRenderer(os.Stderr, &nixlog.RendererOptions{
+		PrintBuildLogs: printBuildLogs,
+	})
+}
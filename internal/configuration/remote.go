@@ -0,0 +1,90 @@
+package configuration
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/nix-community/nixos-cli/internal/cmd/nixopts"
+	"github.com/nix-community/nixos-cli/internal/system"
+)
+
+// Realise an already-evaluated system derivation on a remote build host,
+// and then copy the result to wherever it is needed.
+//
+// The derivation is expected to exist in the local store, which is the
+// case for any derivation that was instantiated using the local builder.
+func realiseOnBuildHost(local system.CommandRunner, drvPath string, opts *SystemBuildOptions) (string, error) {
+	buildHost := opts.BuildHost
+	log := local.Logger()
+
+	if err := buildHost.CopyClosureTo(local, drvPath, opts.Verbose); err != nil {
+		return "", fmt.Errorf("failed to copy derivation to build host %v: %w", buildHost.Host(), err)
+	}
+
+	argv := []string{"nix-store", "--realise", drvPath}
+
+	if opts.DryBuild {
+		argv = append(argv, "--dry-run")
+	}
+
+	if opts.NixOpts != nil {
+		argv = append(argv, nixopts.NixBuildOptionsToArgsList(opts.CmdFlags, opts.NixOpts)...)
+	}
+
+	if opts.Verbose {
+		argv = append(argv, "-v")
+		log.CmdArray(argv)
+	}
+
+	var stdout bytes.Buffer
+	cmd := system.NewCommand(argv[0], argv[1:]...)
+	cmd.Stdout = &stdout
+
+	if _, err := buildHost.Run(cmd); err != nil {
+		return "", err
+	}
+
+	if opts.DryBuild {
+		return "", nil
+	}
+
+	// Derivations for systems and VMs only have a single output.
+	resultPath := strings.TrimSpace(stdout.String())
+	if resultPath == "" {
+		return "", fmt.Errorf("build host %v did not return a result path", buildHost.Host())
+	}
+
+	if opts.TargetHost != nil {
+		if err := system.CopyClosureBetween(local, buildHost, opts.TargetHost, resultPath, opts.Verbose); err != nil {
+			return "", fmt.Errorf("failed to copy result from build host to target host: %w", err)
+		}
+
+		// The result is only needed locally if an explicit
+		// output link has been requested.
+		if opts.ResultLocation == "" {
+			return resultPath, nil
+		}
+	}
+
+	if err := buildHost.CopyClosureFrom(local, resultPath, opts.Verbose); err != nil {
+		return "", fmt.Errorf("failed to copy result from build host %v: %w", buildHost.Host(), err)
+	}
+
+	if opts.ResultLocation != "" {
+		argv := []string{"nix-store", "--realise", resultPath, "--add-root", opts.ResultLocation}
+
+		if opts.Verbose {
+			log.CmdArray(argv)
+		}
+
+		cmd := system.NewCommand(argv[0], argv[1:]...)
+		cmd.Stdout = nil
+
+		if _, err := local.Run(cmd); err != nil {
+			return "", fmt.Errorf("failed to create output link at %v: %w", opts.ResultLocation, err)
+		}
+	}
+
+	return resultPath, nil
+}
@@ -5,6 +5,8 @@ const (
 	NixSystemProfileDirectory = NixProfileDirectory + "/system-profiles"
 	DefaultConfigLocation     = "/etc/nixos-cli/config.toml"
 	CurrentSystem             = "/run/current-system"
+	BootedSystem              = "/run/booted-system"
 	NixOSMarker               = "/etc/NIXOS"
 	NixChannelDirectory       = NixProfileDirectory + "/per-user/root/channels"
+	NixOSCLIStateDirectory    = "/var/lib/nixos-cli"
 )
@@ -0,0 +1,103 @@
+package flake
+
+import (
+	"sort"
+
+	"github.com/nix-community/nixos-cli/internal/git"
+)
+
+type InputChangeType string
+
+const (
+	InputAdded   InputChangeType = "added"
+	InputRemoved InputChangeType = "removed"
+	InputUpdated InputChangeType = "updated"
+)
+
+// A change to a single locked input between two lock files.
+type InputChange struct {
+	Path   string          `json:"path"`
+	Type   InputChangeType `json:"type"`
+	Before *LockedRef      `json:"before,omitempty"`
+	After  *LockedRef      `json:"after,omitempty"`
+	// Number of commits added and removed between the two revisions,
+	// if the input is a local git repository and both are known.
+	CommitsAhead  *int `json:"commits_ahead,omitempty"`
+	CommitsBehind *int `json:"commits_behind,omitempty"`
+}
+
+func lockedRefsEqual(a *LockedRef, b *LockedRef) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.NarHash == b.NarHash && a.Rev == b.Rev && a.String() == b.String()
+}
+
+// Compare the locked inputs of two lock files. Inputs that follow
+// other inputs are not compared, since any changes to them are
+// reported for the inputs that they follow.
+func CompareLockFiles(before *LockFile, after *LockFile) []InputChange {
+	lockedInputs := func(l *LockFile) map[string]*LockedRef {
+		inputs := map[string]*LockedRef{}
+		if l == nil {
+			return inputs
+		}
+		for _, input := range l.Inputs() {
+			if input.Follows == "" && input.Locked != nil {
+				inputs[input.Path] = input.Locked
+			}
+		}
+		return inputs
+	}
+
+	beforeInputs := lockedInputs(before)
+	afterInputs := lockedInputs(after)
+
+	changes := []InputChange{}
+
+	for path, b := range beforeInputs {
+		a, ok := afterInputs[path]
+		if !ok {
+			changes = append(changes, InputChange{Path: path, Type: InputRemoved, Before: b})
+		} else if !lockedRefsEqual(b, a) {
+			changes = append(changes, InputChange{Path: path, Type: InputUpdated, Before: b, After: a})
+		}
+	}
+
+	for path, a := range afterInputs {
+		if _, ok := beforeInputs[path]; !ok {
+			changes = append(changes, InputChange{Path: path, Type: InputAdded, After: a})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes
+}
+
+// Fill in the number of commits between revisions for updated inputs
+// that are git repositories on the local filesystem. Inputs whose
+// repositories cannot be read are left as-is.
+func CountInputCommits(changes []InputChange) {
+	for i := range changes {
+		c := &changes[i]
+		if c.Type != InputUpdated || c.Before.Rev == "" || c.After.Rev == "" {
+			continue
+		}
+
+		repoPath, ok := c.After.LocalGitRepository()
+		if !ok {
+			continue
+		}
+
+		ahead, behind, err := git.CountCommitsBetween(repoPath, c.Before.Rev, c.After.Rev)
+		if err != nil {
+			continue
+		}
+
+		c.CommitsAhead = &ahead
+		c.CommitsBehind = &behind
+	}
+}
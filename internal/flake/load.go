@@ -0,0 +1,49 @@
+package flake
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/nix-community/nixos-cli/internal/system"
+)
+
+// Find the directory of a flake on the local filesystem from its
+// URI, if it refers to one. See URL.LocalDirectory for details.
+func LocalDirectory(uri string) (string, bool) {
+	u, err := ParseURL(uri)
+	if err != nil {
+		return "", false
+	}
+
+	return u.LocalDirectory()
+}
+
+// Load the lock file of the flake with the given URI. For flakes on
+// the local filesystem, the flake.lock file is read directly, and
+// Nix is asked to fetch and lock any other flakes.
+func LoadLockFile(s system.CommandRunner, uri string) (*LockFile, error) {
+	if dir, ok := LocalDirectory(uri); ok {
+		return ReadLockFile(filepath.Join(dir, "flake.lock"))
+	}
+
+	argv := []string{"nix", "--extra-experimental-features", "nix-command flakes", "flake", "metadata", "--json", uri}
+
+	var stdout bytes.Buffer
+	cmd := system.NewCommand(argv[0], argv[1:]...)
+	cmd.Stdout = &stdout
+
+	if _, err := s.Run(cmd); err != nil {
+		return nil, fmt.Errorf("failed to get metadata for flake %v: %w", uri, err)
+	}
+
+	var metadata struct {
+		Locks json.RawMessage `json:"locks"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse flake metadata: %w", err)
+	}
+
+	return ParseLockFile(metadata.Locks)
+}
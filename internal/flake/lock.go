@@ -0,0 +1,306 @@
+package flake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The contents of a flake.lock file.
+type LockFile struct {
+	Version int             `json:"version"`
+	Root    string          `json:"root"`
+	Nodes   map[string]Node `json:"nodes"`
+}
+
+type Node struct {
+	Inputs   map[string]InputReference `json:"inputs,omitempty"`
+	Locked   *LockedRef                `json:"locked,omitempty"`
+	Original map[string]any            `json:"original,omitempty"`
+	// Only set if the input is not a flake
+	Flake *bool `json:"flake,omitempty"`
+}
+
+// An input of a node is either the name of another node, or
+// a path of input names from the root node that it follows.
+type InputReference struct {
+	Node    string
+	Follows []string
+}
+
+func (r *InputReference) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &r.Node); err == nil {
+		return nil
+	}
+
+	return json.Unmarshal(data, &r.Follows)
+}
+
+func (r InputReference) MarshalJSON() ([]byte, error) {
+	if r.Follows != nil {
+		return json.Marshal(r.Follows)
+	}
+	return json.Marshal(r.Node)
+}
+
+// The locked source of a node. Which fields are set
+// depends on the type of the source.
+type LockedRef struct {
+	Type         string `json:"type"`
+	Owner        string `json:"owner,omitempty"`
+	Repo         string `json:"repo,omitempty"`
+	Host         string `json:"host,omitempty"`
+	URL          string `json:"url,omitempty"`
+	Path         string `json:"path,omitempty"`
+	Dir          string `json:"dir,omitempty"`
+	Ref          string `json:"ref,omitempty"`
+	Rev          string `json:"rev,omitempty"`
+	RevCount     int64  `json:"revCount,omitempty"`
+	NarHash      string `json:"narHash,omitempty"`
+	LastModified int64  `json:"lastModified,omitempty"`
+}
+
+// Format the locked source as a flake reference, similar
+// to how Nix displays it.
+func (l *LockedRef) String() string {
+	var ref string
+	query := url.Values{}
+
+	switch l.Type {
+	case "github", "gitlab", "sourcehut":
+		ref = fmt.Sprintf("%s:%s/%s", l.Type, l.Owner, l.Repo)
+		if l.Rev != "" {
+			ref += "/" + l.Rev
+		} else if l.Ref != "" {
+			ref += "/" + l.Ref
+		}
+		if l.Host != "" {
+			query.Set("host", l.Host)
+		}
+	case "git", "hg":
+		if l.Type == "hg" {
+			ref = "hg+" + l.URL
+		} else {
+			ref = "git+" + l.URL
+		}
+		if l.Ref != "" {
+			query.Set("ref", l.Ref)
+		}
+		if l.Rev != "" {
+			query.Set("rev", l.Rev)
+		}
+	case "path":
+		ref = "path:" + l.Path
+	case "tarball", "file":
+		ref = l.URL
+	default:
+		ref = l.Type + ":" + l.URL + l.Path
+	}
+
+	if l.Dir != "" {
+		query.Set("dir", l.Dir)
+	}
+
+	if len(query) > 0 {
+		separator := "?"
+		if strings.Contains(ref, "?") {
+			separator = "&"
+		}
+		ref += separator + query.Encode()
+	}
+
+	return ref
+}
+
+// A short identifier for the locked revision of a source. This is
+// the commit hash if there is one, and the NAR hash otherwise.
+func (l *LockedRef) ShortRev() string {
+	if l.Rev != "" {
+		if len(l.Rev) > 7 {
+			return l.Rev[:7]
+		}
+		return l.Rev
+	}
+
+	narHash := strings.TrimPrefix(l.NarHash, "sha256-")
+	if len(narHash) > 7 {
+		narHash = narHash[:7]
+	}
+	return narHash
+}
+
+// The time of the last modification to the source, or the
+// zero time if it is not known.
+func (l *LockedRef) LastModifiedTime() time.Time {
+	if l.LastModified == 0 {
+		return time.Time{}
+	}
+	return time.Unix(l.LastModified, 0)
+}
+
+// If this source is a git repository on the local filesystem,
+// return its path.
+func (l *LockedRef) LocalGitRepository() (string, bool) {
+	if l.Type != "git" {
+		return "", false
+	}
+
+	u, err := url.Parse(l.URL)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+
+	return u.Path, true
+}
+
+func ParseLockFile(contents []byte) (*LockFile, error) {
+	var lock LockFile
+	if err := json.Unmarshal(contents, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse lock file: %w", err)
+	}
+
+	if lock.Root == "" {
+		lock.Root = "root"
+	}
+
+	if _, ok := lock.Nodes[lock.Root]; !ok {
+		return nil, fmt.Errorf("lock file has no root node '%s'", lock.Root)
+	}
+
+	return &lock, nil
+}
+
+func ReadLockFile(filename string) (*LockFile, error) {
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseLockFile(contents)
+}
+
+// A single input of a flake, including inputs of its inputs.
+type Input struct {
+	// Path of input names from the root, such as "home-manager/nixpkgs"
+	Path string `json:"path"`
+	// Name of the node that this input resolves to, if any
+	Node string `json:"node,omitempty"`
+	// Path of the input that this input follows, if any
+	Follows string     `json:"follows,omitempty"`
+	Locked  *LockedRef `json:"locked,omitempty"`
+	IsFlake bool       `json:"is_flake"`
+}
+
+func (i *Input) Name() string {
+	if idx := strings.LastIndex(i.Path, "/"); idx >= 0 {
+		return i.Path[idx+1:]
+	}
+	return i.Path
+}
+
+func (i *Input) IsDirect() bool {
+	return !strings.Contains(i.Path, "/")
+}
+
+// Resolve the node that the given path of input names from the root
+// node points to, following any other inputs along the way.
+func (l *LockFile) ResolvePath(path []string) (string, bool) {
+	return l.resolvePath(path, 0)
+}
+
+// Inputs can follow each other in cycles in malformed lock files,
+// so resolution is limited to a reasonable depth.
+const maxResolveDepth = 64
+
+func (l *LockFile) resolvePath(path []string, depth int) (string, bool) {
+	if depth > maxResolveDepth {
+		return "", false
+	}
+
+	current := l.Root
+	for _, name := range path {
+		node, ok := l.Nodes[current]
+		if !ok {
+			return "", false
+		}
+
+		ref, ok := node.Inputs[name]
+		if !ok {
+			return "", false
+		}
+
+		if ref.Follows != nil {
+			resolved, ok := l.resolvePath(ref.Follows, depth+1)
+			if !ok {
+				return "", false
+			}
+			current = resolved
+		} else {
+			current = ref.Node
+		}
+	}
+
+	return current, true
+}
+
+// List all inputs of the flake, including transitive ones,
+// sorted by their path.
+func (l *LockFile) Inputs() []Input {
+	inputs := []Input{}
+	l.collectInputs(l.Root, "", map[string]bool{l.Root: true}, &inputs)
+
+	sort.Slice(inputs, func(i, j int) bool {
+		return inputs[i].Path < inputs[j].Path
+	})
+
+	return inputs
+}
+
+func (l *LockFile) collectInputs(nodeName string, prefix string, visiting map[string]bool, inputs *[]Input) {
+	node, ok := l.Nodes[nodeName]
+	if !ok {
+		return
+	}
+
+	for name, ref := range node.Inputs {
+		input := Input{Path: prefix + name}
+
+		if ref.Follows != nil {
+			input.Follows = strings.Join(ref.Follows, "/")
+			if resolved, ok := l.ResolvePath(ref.Follows); ok {
+				input.Node = resolved
+			}
+		} else {
+			input.Node = ref.Node
+		}
+
+		if target, ok := l.Nodes[input.Node]; ok {
+			input.Locked = target.Locked
+			input.IsFlake = target.Flake == nil || *target.Flake
+		}
+
+		*inputs = append(*inputs, input)
+
+		// Inputs of followed inputs are already listed under
+		// the input that is being followed.
+		if ref.Follows == nil && !visiting[ref.Node] {
+			visiting[ref.Node] = true
+			l.collectInputs(ref.Node, input.Path+"/", visiting, inputs)
+			delete(visiting, ref.Node)
+		}
+	}
+}
+
+// Find an input by its path from the root.
+func (l *LockFile) Input(path string) (*Input, bool) {
+	for _, input := range l.Inputs() {
+		if input.Path == path {
+			return &input, true
+		}
+	}
+	return nil, false
+}
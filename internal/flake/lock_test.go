@@ -0,0 +1,186 @@
+package flake
+
+import (
+	"strings"
+	"testing"
+)
+
+const testLockFile = `{
+  "nodes": {
+    "home-manager": {
+      "inputs": {
+        "nixpkgs": ["nixpkgs"]
+      },
+      "locked": {
+        "lastModified": 1700000000,
+        "narHash": "sha256-AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+        "owner": "nix-community",
+        "repo": "home-manager",
+        "rev": "1111111111111111111111111111111111111111",
+        "type": "github"
+      },
+      "original": {
+        "owner": "nix-community",
+        "repo": "home-manager",
+        "type": "github"
+      }
+    },
+    "nixpkgs": {
+      "locked": {
+        "lastModified": 1700000000,
+        "narHash": "sha256-BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBB=",
+        "owner": "NixOS",
+        "repo": "nixpkgs",
+        "rev": "2222222222222222222222222222222222222222",
+        "type": "github"
+      },
+      "original": {
+        "owner": "NixOS",
+        "ref": "nixos-unstable",
+        "repo": "nixpkgs",
+        "type": "github"
+      }
+    },
+    "secrets": {
+      "flake": false,
+      "locked": {
+        "lastModified": 1690000000,
+        "narHash": "sha256-CCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCC=",
+        "ref": "refs/heads/main",
+        "rev": "3333333333333333333333333333333333333333",
+        "revCount": 42,
+        "type": "git",
+        "url": "file:///home/user/secrets"
+      },
+      "original": {
+        "type": "git",
+        "url": "file:///home/user/secrets"
+      }
+    },
+    "root": {
+      "inputs": {
+        "home-manager": "home-manager",
+        "nixpkgs": "nixpkgs",
+        "secrets": "secrets"
+      }
+    }
+  },
+  "root": "root",
+  "version": 7
+}`
+
+func TestLockFileInputs(t *testing.T) {
+	lock, err := ParseLockFile([]byte(testLockFile))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	inputs := lock.Inputs()
+
+	paths := []string{}
+	for _, input := range inputs {
+		paths = append(paths, input.Path)
+	}
+	expected := "home-manager,home-manager/nixpkgs,nixpkgs,secrets"
+	if strings.Join(paths, ",") != expected {
+		t.Fatalf("expected inputs %v, got %v", expected, paths)
+	}
+
+	followed := inputs[1]
+	if followed.Follows != "nixpkgs" || followed.Node != "nixpkgs" || followed.Locked.Repo != "nixpkgs" {
+		t.Errorf("unexpected followed input: %+v", followed)
+	}
+
+	secrets := inputs[3]
+	if secrets.IsFlake {
+		t.Errorf("expected secrets input to not be a flake")
+	}
+	if path, ok := secrets.Locked.LocalGitRepository(); !ok || path != "/home/user/secrets" {
+		t.Errorf("expected secrets to be a local git repository, got %v", path)
+	}
+}
+
+func TestLockedRefString(t *testing.T) {
+	tests := []struct {
+		ref      LockedRef
+		expected string
+	}{
+		{
+			LockedRef{Type: "github", Owner: "NixOS", Repo: "nixpkgs", Rev: "abc"},
+			"github:NixOS/nixpkgs/abc",
+		},
+		{
+			LockedRef{Type: "git", URL: "https://example.com/repo.git", Ref: "main", Rev: "abc"},
+			"git+https://example.com/repo.git?ref=main&rev=abc",
+		},
+		{
+			LockedRef{Type: "path", Path: "/etc/nixos/sub"},
+			"path:/etc/nixos/sub",
+		},
+		{
+			LockedRef{Type: "tarball", URL: "https://example.com/a.tar.gz"},
+			"https://example.com/a.tar.gz",
+		},
+	}
+
+	for _, tt := range tests {
+		if actual := tt.ref.String(); actual != tt.expected {
+			t.Errorf("expected %v, got %v", tt.expected, actual)
+		}
+	}
+}
+
+func TestCompareLockFiles(t *testing.T) {
+	before, err := ParseLockFile([]byte(testLockFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated := strings.ReplaceAll(testLockFile, "2222222222222222222222222222222222222222", "4444444444444444444444444444444444444444")
+	updated = strings.ReplaceAll(updated, `"secrets": "secrets"`, `"extra": "secrets"`)
+
+	after, err := ParseLockFile([]byte(updated))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := CompareLockFiles(before, after)
+
+	summary := []string{}
+	for _, c := range changes {
+		summary = append(summary, string(c.Type)+":"+c.Path)
+	}
+
+	expected := "added:extra,updated:nixpkgs,removed:secrets"
+	if strings.Join(summary, ",") != expected {
+		t.Errorf("expected changes %v, got %v", expected, summary)
+	}
+
+	message := LockFileCommitMessage(changes)
+	if !strings.Contains(message, "• Updated input 'nixpkgs':\n    'github:NixOS/nixpkgs/2222222222222222222222222222222222222222' (2023-11-14)\n  → 'github:NixOS/nixpkgs/4444444444444444444444444444444444444444' (2023-11-14)") {
+		t.Errorf("unexpected commit message:\n%v", message)
+	}
+}
+
+func TestLocalDirectory(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		uri      string
+		expected string
+		ok       bool
+	}{
+		{dir, dir, true},
+		{"path:" + dir, dir, true},
+		{"git+file://" + dir + "?ref=main", dir, true},
+		{"github:NixOS/nixpkgs", "", false},
+		{dir + "/nonexistent", "", false},
+	}
+
+	for _, tt := range tests {
+		actual, ok := LocalDirectory(tt.uri)
+		if ok != tt.ok || actual != tt.expected {
+			t.Errorf("LocalDirectory(%v) = (%v, %v), expected (%v, %v)", tt.uri, actual, ok, tt.expected, tt.ok)
+		}
+	}
+}
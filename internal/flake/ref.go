@@ -0,0 +1,461 @@
+package flake
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+type URLType string
+
+const (
+	URLTypePath      URLType = "path"
+	URLTypeGit       URLType = "git"
+	URLTypeMercurial URLType = "mercurial"
+	URLTypeGitHub    URLType = "github"
+	URLTypeGitLab    URLType = "gitlab"
+	URLTypeSourceHut URLType = "sourcehut"
+	URLTypeTarball   URLType = "tarball"
+	URLTypeFile      URLType = "file"
+	URLTypeIndirect  URLType = "indirect"
+)
+
+// A parsed flake URL, which is the part of a flake ref before
+// the attribute path.
+type URL struct {
+	Type URLType
+	// Transport protocol for git, mercurial, tarball, and file
+	// URLs, such as "https", "ssh", or "file".
+	Transport string
+	// Host of URLs with a network transport, including any user
+	// information, or the custom host of github, gitlab, and
+	// sourcehut URLs, if one is set.
+	Host string
+	// Local path for path URLs and file transports, or the path
+	// on the host for other transports.
+	Path string
+	// Repository owner and name for github, gitlab, and sourcehut URLs
+	Owner string
+	Repo  string
+	// Flake registry identifier for indirect URLs
+	ID  string
+	Ref string
+	Rev string
+	// Subdirectory of the source tree that contains flake.nix
+	Dir string
+	// Any other query parameters, such as `submodules` or `narHash`
+	Params url.Values
+}
+
+var (
+	flakeIDRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]*$`)
+	revRegex     = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+	archiveExtensions = []string{".zip", ".tar", ".tgz", ".tar.gz", ".tar.xz", ".tar.bz2", ".tar.zst"}
+)
+
+// Split a flake ref into its URL and its attribute path. The
+// attribute path is percent-decoded, but not parsed.
+func SplitRef(ref string) (string, string) {
+	uri, fragment, found := strings.Cut(ref, "#")
+	if !found {
+		return ref, ""
+	}
+
+	if decoded, err := url.PathUnescape(fragment); err == nil {
+		fragment = decoded
+	}
+
+	return uri, fragment
+}
+
+// Parse a flake URL, following the same grammar that Nix uses
+// for flake refs. The URL must not contain an attribute path.
+func ParseURL(uri string) (*URL, error) {
+	if uri == "" {
+		return nil, fmt.Errorf("flake URL is empty")
+	}
+
+	if isPathLike(uri) {
+		path, query, _ := strings.Cut(uri, "?")
+		return newURL(uri, &URL{Type: URLTypePath, Path: path}, query)
+	}
+
+	scheme, rest, found := strings.Cut(uri, ":")
+	if !found {
+		return parseIndirectURL(uri, uri)
+	}
+
+	switch scheme {
+	case "path":
+		path, query, _ := strings.Cut(rest, "?")
+		if path == "" {
+			return nil, fmt.Errorf("flake URL '%v' has an empty path", uri)
+		}
+		return newURL(uri, &URL{Type: URLTypePath, Path: path}, query)
+	case "flake":
+		return parseIndirectURL(uri, rest)
+	case "github", "gitlab", "sourcehut":
+		return parseRepositoryURL(uri, URLType(scheme), rest)
+	case "http", "https", "file":
+		u, err := parseTransportURL(uri, scheme, rest)
+		if err != nil {
+			return nil, err
+		}
+		if isArchive(u.Path) {
+			u.Type = URLTypeTarball
+		} else {
+			u.Type = URLTypeFile
+		}
+		return u, nil
+	}
+
+	kind, transport, found := strings.Cut(scheme, "+")
+	if !found {
+		return nil, fmt.Errorf("flake URL '%v' has unsupported scheme '%v'", uri, scheme)
+	}
+
+	var urlType URLType
+	var transports []string
+
+	switch kind {
+	case "git":
+		urlType = URLTypeGit
+		transports = []string{"http", "https", "ssh", "git", "file"}
+	case "hg":
+		urlType = URLTypeMercurial
+		transports = []string{"http", "https", "ssh", "file"}
+	case "tarball":
+		urlType = URLTypeTarball
+		transports = []string{"http", "https", "file"}
+	case "file":
+		urlType = URLTypeFile
+		transports = []string{"http", "https", "file"}
+	default:
+		return nil, fmt.Errorf("flake URL '%v' has unsupported scheme '%v'", uri, scheme)
+	}
+
+	supported := false
+	for _, t := range transports {
+		if t == transport {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return nil, fmt.Errorf("flake URL '%v' has unsupported transport '%v' for type %v", uri, transport, urlType)
+	}
+
+	u, err := parseTransportURL(uri, transport, rest)
+	if err != nil {
+		return nil, err
+	}
+	u.Type = urlType
+
+	return u, nil
+}
+
+func isPathLike(uri string) bool {
+	return strings.HasPrefix(uri, "/") || strings.HasPrefix(uri, ".") || strings.HasPrefix(uri, "~")
+}
+
+func isArchive(path string) bool {
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(path, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse an indirect URL of the form `id(/ref(/rev)?)?`.
+func parseIndirectURL(uri string, rest string) (*URL, error) {
+	path, query, _ := strings.Cut(rest, "?")
+	segments := strings.Split(path, "/")
+
+	if !flakeIDRegex.MatchString(segments[0]) {
+		return nil, fmt.Errorf("flake URL '%v' has invalid flake ID '%v'", uri, segments[0])
+	}
+
+	u := &URL{Type: URLTypeIndirect, ID: segments[0]}
+
+	switch len(segments) {
+	case 1:
+	case 2:
+		if revRegex.MatchString(segments[1]) {
+			u.Rev = segments[1]
+		} else {
+			u.Ref = segments[1]
+		}
+	case 3:
+		if !revRegex.MatchString(segments[2]) {
+			return nil, fmt.Errorf("flake URL '%v' has invalid revision '%v'", uri, segments[2])
+		}
+		u.Ref = segments[1]
+		u.Rev = segments[2]
+	default:
+		return nil, fmt.Errorf("flake URL '%v' has too many path segments", uri)
+	}
+
+	return newURL(uri, u, query)
+}
+
+// Parse a URL of the form `owner/repo(/ref-or-rev)?`, as used
+// by github, gitlab, and sourcehut URLs.
+func parseRepositoryURL(uri string, urlType URLType, rest string) (*URL, error) {
+	path, query, _ := strings.Cut(rest, "?")
+	segments := strings.Split(path, "/")
+
+	if len(segments) < 2 || segments[0] == "" || segments[1] == "" {
+		return nil, fmt.Errorf("flake URL '%v' must be of the form '%v:owner/repo'", uri, urlType)
+	}
+	if len(segments) > 3 {
+		return nil, fmt.Errorf("flake URL '%v' has too many path segments", uri)
+	}
+
+	u := &URL{Type: urlType, Owner: segments[0], Repo: segments[1]}
+
+	if len(segments) == 3 {
+		if revRegex.MatchString(segments[2]) {
+			u.Rev = segments[2]
+		} else {
+			u.Ref = segments[2]
+		}
+	}
+
+	u, err := newURL(uri, u, query)
+	if err != nil {
+		return nil, err
+	}
+
+	if host := u.Params.Get("host"); host != "" {
+		u.Host = host
+		u.Params.Del("host")
+	}
+
+	return u, nil
+}
+
+func parseTransportURL(uri string, transport string, rest string) (*URL, error) {
+	parsed, err := url.Parse(transport + ":" + rest)
+	if err != nil {
+		return nil, fmt.Errorf("flake URL '%v' is invalid: %w", uri, err)
+	}
+
+	u := &URL{Transport: transport, Host: parsed.Host, Path: parsed.Path}
+	if parsed.User != nil {
+		u.Host = parsed.User.String() + "@" + parsed.Host
+	}
+
+	if transport == "file" && u.Host != "" {
+		return nil, fmt.Errorf("flake URL '%v' cannot have a host with the file transport", uri)
+	}
+	if transport != "file" && u.Host == "" {
+		return nil, fmt.Errorf("flake URL '%v' is missing a host", uri)
+	}
+
+	return newURL(uri, u, parsed.RawQuery)
+}
+
+// Fill in the query parameters of a URL. Parameters that have
+// their own fields are removed from the remaining parameters.
+func newURL(uri string, u *URL, query string) (*URL, error) {
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("flake URL '%v' has invalid query parameters: %w", uri, err)
+	}
+
+	if ref := params.Get("ref"); ref != "" {
+		if u.Ref != "" {
+			return nil, fmt.Errorf("flake URL '%v' has a ref in both the path and the query parameters", uri)
+		}
+		u.Ref = ref
+	}
+
+	if rev := params.Get("rev"); rev != "" {
+		if u.Rev != "" {
+			return nil, fmt.Errorf("flake URL '%v' has a revision in both the path and the query parameters", uri)
+		}
+		if !revRegex.MatchString(rev) {
+			return nil, fmt.Errorf("flake URL '%v' has invalid revision '%v'", uri, rev)
+		}
+		u.Rev = rev
+	}
+
+	u.Dir = params.Get("dir")
+
+	params.Del("ref")
+	params.Del("rev")
+	params.Del("dir")
+	u.Params = params
+
+	return u, nil
+}
+
+// Check if the flake URL refers to a source tree on the
+// local filesystem.
+func (u *URL) IsLocal() bool {
+	switch u.Type {
+	case URLTypePath:
+		return true
+	case URLTypeGit, URLTypeMercurial:
+		return u.Transport == "file"
+	default:
+		return false
+	}
+}
+
+// Find the directory that contains flake.nix for a local flake URL,
+// taking the `dir` parameter into account. This is relative if the
+// URL's path is relative, and only exists if the directory exists.
+func (u *URL) LocalDirectory() (string, bool) {
+	if !u.IsLocal() {
+		return "", false
+	}
+
+	path := u.Path
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", false
+		}
+		path = filepath.Join(home, strings.TrimPrefix(path, "~"))
+	}
+
+	if u.Dir != "" {
+		path = filepath.Join(path, u.Dir)
+	}
+
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		return "", false
+	}
+
+	return path, true
+}
+
+var (
+	attrIdentifierRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_'-]*$`)
+	nixKeywords         = []string{"assert", "else", "if", "in", "inherit", "let", "or", "rec", "then", "with"}
+)
+
+// Parse a Nix attribute path, such as `nixosConfigurations."my.host"`,
+// into its components. Quoted components may contain dots and escaped
+// characters.
+func ParseAttrPath(path string) ([]string, error) {
+	if path == "" {
+		return []string{}, nil
+	}
+
+	components := []string{}
+
+	i := 0
+	for {
+		var name strings.Builder
+
+		if path[i] == '"' {
+			closed := false
+			for i++; i < len(path); i++ {
+				c := path[i]
+				if c == '\\' && i+1 < len(path) {
+					i++
+					name.WriteByte(unescapeNixChar(path[i]))
+					continue
+				}
+				if c == '"' {
+					closed = true
+					i++
+					break
+				}
+				name.WriteByte(c)
+			}
+
+			if !closed {
+				return nil, fmt.Errorf("unterminated quote in attribute path '%v'", path)
+			}
+		} else {
+			start := i
+			for i < len(path) && path[i] != '.' {
+				if path[i] == '"' {
+					return nil, fmt.Errorf("unexpected quote in attribute path '%v'", path)
+				}
+				i++
+			}
+			if i == start {
+				return nil, fmt.Errorf("empty name in attribute path '%v'", path)
+			}
+			name.WriteString(path[start:i])
+		}
+
+		components = append(components, name.String())
+
+		if i == len(path) {
+			return components, nil
+		}
+		if path[i] != '.' {
+			return nil, fmt.Errorf("unexpected character '%c' in attribute path '%v'", path[i], path)
+		}
+
+		i++
+		if i == len(path) {
+			return nil, fmt.Errorf("empty name in attribute path '%v'", path)
+		}
+	}
+}
+
+func unescapeNixChar(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	default:
+		return c
+	}
+}
+
+// Format a single attribute name for use in a Nix attribute path,
+// quoting it if it is not a valid identifier.
+func QuoteAttr(name string) string {
+	if attrIdentifierRegex.MatchString(name) {
+		isKeyword := false
+		for _, k := range nixKeywords {
+			if name == k {
+				isKeyword = true
+				break
+			}
+		}
+		if !isKeyword {
+			return name
+		}
+	}
+
+	return QuoteString(name)
+}
+
+// Format a string as a Nix string literal.
+func QuoteString(s string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"${", `\${`,
+		"\n", `\n`,
+		"\r", `\r`,
+		"\t", `\t`,
+	)
+
+	return `"` + replacer.Replace(s) + `"`
+}
+
+// Format a list of attribute names as a Nix attribute path.
+func FormatAttrPath(components []string) string {
+	quoted := make([]string, len(components))
+	for i, c := range components {
+		quoted[i] = QuoteAttr(c)
+	}
+	return strings.Join(quoted, ".")
+}
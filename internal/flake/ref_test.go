@@ -0,0 +1,288 @@
+package flake
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testRev = "0123456789abcdef0123456789abcdef01234567"
+
+func TestSplitRef(t *testing.T) {
+	tests := []struct {
+		ref      string
+		uri      string
+		fragment string
+	}{
+		{"github:owner/repo#host", "github:owner/repo", "host"},
+		{"github:owner/repo", "github:owner/repo", ""},
+		{".#host", ".", "host"},
+		{`.#"my.host"`, ".", `"my.host"`},
+		{".#%22my.host%22", ".", `"my.host"`},
+		{"git+https://example.com/repo?ref=main#host", "git+https://example.com/repo?ref=main", "host"},
+		{"#host", "", "host"},
+		{"github:owner/repo#host#extra", "github:owner/repo", "host#extra"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			uri, fragment := SplitRef(tt.ref)
+			if uri != tt.uri || fragment != tt.fragment {
+				t.Errorf("SplitRef(%q) = (%q, %q), want (%q, %q)", tt.ref, uri, fragment, tt.uri, tt.fragment)
+			}
+		})
+	}
+}
+
+func TestParseURL(t *testing.T) {
+	tests := []struct {
+		uri      string
+		expected *URL
+	}{
+		{
+			uri:      ".",
+			expected: &URL{Type: URLTypePath, Path: ".", Params: url.Values{}},
+		},
+		{
+			uri:      "./config?dir=hosts",
+			expected: &URL{Type: URLTypePath, Path: "./config", Dir: "hosts", Params: url.Values{}},
+		},
+		{
+			uri:      "/etc/nixos",
+			expected: &URL{Type: URLTypePath, Path: "/etc/nixos", Params: url.Values{}},
+		},
+		{
+			uri:      "~/config",
+			expected: &URL{Type: URLTypePath, Path: "~/config", Params: url.Values{}},
+		},
+		{
+			uri:      "path:/etc/nixos?narHash=sha256-abc",
+			expected: &URL{Type: URLTypePath, Path: "/etc/nixos", Params: url.Values{"narHash": {"sha256-abc"}}},
+		},
+		{
+			uri:      "git+file:///etc/nixos?ref=main&dir=sub",
+			expected: &URL{Type: URLTypeGit, Transport: "file", Path: "/etc/nixos", Ref: "main", Dir: "sub", Params: url.Values{}},
+		},
+		{
+			uri: "git+https://example.com/owner/repo.git?rev=" + testRev + "&submodules=1",
+			expected: &URL{
+				Type:      URLTypeGit,
+				Transport: "https",
+				Host:      "example.com",
+				Path:      "/owner/repo.git",
+				Rev:       testRev,
+				Params:    url.Values{"submodules": {"1"}},
+			},
+		},
+		{
+			uri:      "git+ssh://git@example.com/owner/repo",
+			expected: &URL{Type: URLTypeGit, Transport: "ssh", Host: "git@example.com", Path: "/owner/repo", Params: url.Values{}},
+		},
+		{
+			uri:      "hg+https://example.com/repo",
+			expected: &URL{Type: URLTypeMercurial, Transport: "https", Host: "example.com", Path: "/repo", Params: url.Values{}},
+		},
+		{
+			uri:      "github:NixOS/nixpkgs",
+			expected: &URL{Type: URLTypeGitHub, Owner: "NixOS", Repo: "nixpkgs", Params: url.Values{}},
+		},
+		{
+			uri:      "github:NixOS/nixpkgs/nixos-unstable?dir=lib",
+			expected: &URL{Type: URLTypeGitHub, Owner: "NixOS", Repo: "nixpkgs", Ref: "nixos-unstable", Dir: "lib", Params: url.Values{}},
+		},
+		{
+			uri:      "github:NixOS/nixpkgs/" + testRev,
+			expected: &URL{Type: URLTypeGitHub, Owner: "NixOS", Repo: "nixpkgs", Rev: testRev, Params: url.Values{}},
+		},
+		{
+			uri:      "gitlab:owner/repo?host=gitlab.example.com",
+			expected: &URL{Type: URLTypeGitLab, Host: "gitlab.example.com", Owner: "owner", Repo: "repo", Params: url.Values{}},
+		},
+		{
+			uri:      "sourcehut:~owner/repo/main",
+			expected: &URL{Type: URLTypeSourceHut, Owner: "~owner", Repo: "repo", Ref: "main", Params: url.Values{}},
+		},
+		{
+			uri:      "https://example.com/source.tar.gz",
+			expected: &URL{Type: URLTypeTarball, Transport: "https", Host: "example.com", Path: "/source.tar.gz", Params: url.Values{}},
+		},
+		{
+			uri:      "https://example.com/flake.nix",
+			expected: &URL{Type: URLTypeFile, Transport: "https", Host: "example.com", Path: "/flake.nix", Params: url.Values{}},
+		},
+		{
+			uri:      "tarball+https://example.com/download",
+			expected: &URL{Type: URLTypeTarball, Transport: "https", Host: "example.com", Path: "/download", Params: url.Values{}},
+		},
+		{
+			uri:      "nixpkgs",
+			expected: &URL{Type: URLTypeIndirect, ID: "nixpkgs", Params: url.Values{}},
+		},
+		{
+			uri:      "nixpkgs/nixos-24.05",
+			expected: &URL{Type: URLTypeIndirect, ID: "nixpkgs", Ref: "nixos-24.05", Params: url.Values{}},
+		},
+		{
+			uri:      "flake:nixpkgs/nixos-24.05/" + testRev,
+			expected: &URL{Type: URLTypeIndirect, ID: "nixpkgs", Ref: "nixos-24.05", Rev: testRev, Params: url.Values{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			actual, err := ParseURL(tt.uri)
+			if err != nil {
+				t.Fatalf("ParseURL(%q) returned error: %v", tt.uri, err)
+			}
+
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("ParseURL(%q) = %+v, want %+v", tt.uri, actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseURLErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"path:",
+		"github:NixOS",
+		"github:/nixpkgs",
+		"github:NixOS/nixpkgs/a/b",
+		"github:NixOS/nixpkgs/main?ref=main",
+		"github:NixOS/nixpkgs?rev=notarev",
+		"git+ftp://example.com/repo",
+		"git+https:///repo",
+		"git+file://host/repo",
+		"svn://example.com/repo",
+		"1nvalid",
+		"nixpkgs/main/notarev",
+	}
+
+	for _, uri := range tests {
+		t.Run(uri, func(t *testing.T) {
+			if u, err := ParseURL(uri); err == nil {
+				t.Errorf("ParseURL(%q) = %+v, expected error", uri, u)
+			}
+		})
+	}
+}
+
+func TestURLLocalDirectory(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		uri      string
+		expected string
+		ok       bool
+	}{
+		{dir, dir, true},
+		{dir + "?dir=sub", filepath.Join(dir, "sub"), true},
+		{"path:" + dir + "?dir=sub", filepath.Join(dir, "sub"), true},
+		{"git+file://" + dir + "?dir=sub&ref=main", filepath.Join(dir, "sub"), true},
+		{"hg+file://" + dir, dir, true},
+		{dir + "?dir=nonexistent", "", false},
+		{"file://" + dir, "", false},
+		{"git+https://example.com/repo", "", false},
+		{"github:NixOS/nixpkgs", "", false},
+		{"nixpkgs", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			u, err := ParseURL(tt.uri)
+			if err != nil {
+				t.Fatalf("ParseURL(%q) returned error: %v", tt.uri, err)
+			}
+
+			actual, ok := u.LocalDirectory()
+			if ok != tt.ok || actual != tt.expected {
+				t.Errorf("LocalDirectory(%q) = (%q, %v), want (%q, %v)", tt.uri, actual, ok, tt.expected, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseAttrPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected []string
+	}{
+		{"", []string{}},
+		{"host", []string{"host"}},
+		{"nixosConfigurations.host", []string{"nixosConfigurations", "host"}},
+		{`"my.host"`, []string{"my.host"}},
+		{`nixosConfigurations."my.host"`, []string{"nixosConfigurations", "my.host"}},
+		{`"a\"b".c`, []string{`a"b`, "c"}},
+		{`""`, []string{""}},
+		{"host#extra", []string{"host#extra"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			actual, err := ParseAttrPath(tt.path)
+			if err != nil {
+				t.Fatalf("ParseAttrPath(%q) returned error: %v", tt.path, err)
+			}
+
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("ParseAttrPath(%q) = %q, want %q", tt.path, actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseAttrPathErrors(t *testing.T) {
+	tests := []string{
+		".",
+		"a.",
+		".a",
+		"a..b",
+		`"unterminated`,
+		`"a"b`,
+		`a"b"`,
+	}
+
+	for _, path := range tests {
+		t.Run(path, func(t *testing.T) {
+			if actual, err := ParseAttrPath(path); err == nil {
+				t.Errorf("ParseAttrPath(%q) = %q, expected error", path, actual)
+			}
+		})
+	}
+}
+
+func TestQuoteAttr(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"host", "host"},
+		{"my-host_1'", "my-host_1'"},
+		{"my.host", `"my.host"`},
+		{"1host", `"1host"`},
+		{"in", `"in"`},
+		{"", `""`},
+		{`a"b`, `"a\"b"`},
+		{"${x}", `"\${x}"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := QuoteAttr(tt.name); actual != tt.expected {
+				t.Errorf("QuoteAttr(%q) = %v, want %v", tt.name, actual, tt.expected)
+			}
+
+			// Quoted names must parse back to the same name.
+			parsed, err := ParseAttrPath(QuoteAttr(tt.name))
+			if err != nil || len(parsed) != 1 || parsed[0] != tt.name {
+				t.Errorf("ParseAttrPath(QuoteAttr(%q)) = (%q, %v)", tt.name, parsed, err)
+			}
+		})
+	}
+}
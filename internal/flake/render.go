@@ -0,0 +1,123 @@
+package flake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+func formatLocked(l *LockedRef) string {
+	if l == nil {
+		return "∅"
+	}
+
+	rev := l.ShortRev()
+	if date := l.LastModifiedTime(); !date.IsZero() {
+		rev += fmt.Sprintf(" (%s)", date.UTC().Format(time.DateOnly))
+	}
+
+	return rev
+}
+
+func formatCommitCount(c *InputChange) string {
+	if c.CommitsAhead == nil || c.CommitsBehind == nil {
+		return ""
+	}
+
+	counts := []string{}
+	if *c.CommitsAhead > 0 {
+		counts = append(counts, fmt.Sprintf("+%d", *c.CommitsAhead))
+	}
+	if *c.CommitsBehind > 0 {
+		counts = append(counts, fmt.Sprintf("-%d", *c.CommitsBehind))
+	}
+
+	if len(counts) == 0 {
+		return ""
+	}
+
+	return strings.Join(counts, "/") + " commits"
+}
+
+// Write a human-readable summary of changes to flake inputs.
+func WriteInputChangesText(w io.Writer, changes []InputChange) error {
+	if len(changes) == 0 {
+		_, err := fmt.Fprintln(w, "No input changes.")
+		return err
+	}
+
+	tags := map[InputChangeType]string{
+		InputUpdated: color.New(color.FgCyan).Sprint("[U]"),
+		InputAdded:   color.New(color.FgGreen).Sprint("[A]"),
+		InputRemoved: color.New(color.FgRed).Sprint("[R]"),
+	}
+
+	fmt.Fprintln(w, color.New(color.Bold).Sprint("Input changes:"))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i := range changes {
+		c := &changes[i]
+
+		var revisions string
+		switch c.Type {
+		case InputAdded:
+			revisions = formatLocked(c.After)
+		case InputRemoved:
+			revisions = formatLocked(c.Before)
+		default:
+			revisions = fmt.Sprintf("%s → %s", formatLocked(c.Before), formatLocked(c.After))
+		}
+
+		line := fmt.Sprintf("%s %s\t%s", tags[c.Type], c.Path, revisions)
+		if commits := formatCommitCount(c); commits != "" {
+			line += "\t" + commits
+		}
+		fmt.Fprintln(tw, line)
+	}
+
+	return tw.Flush()
+}
+
+func WriteInputChangesJSON(w io.Writer, changes []InputChange) error {
+	bytes, err := json.MarshalIndent(changes, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", bytes)
+	return err
+}
+
+// Format a commit message for a lock file update, in the
+// same format that Nix uses for --commit-lock-file.
+func LockFileCommitMessage(changes []InputChange) string {
+	var sb strings.Builder
+
+	sb.WriteString("flake.lock: Update\n\nFlake lock file updates:\n")
+
+	formatRef := func(l *LockedRef) string {
+		ref := fmt.Sprintf("'%s'", l.String())
+		if date := l.LastModifiedTime(); !date.IsZero() {
+			ref += fmt.Sprintf(" (%s)", date.UTC().Format(time.DateOnly))
+		}
+		return ref
+	}
+
+	for _, c := range changes {
+		switch c.Type {
+		case InputAdded:
+			fmt.Fprintf(&sb, "\n• Added input '%s':\n    %s\n", c.Path, formatRef(c.After))
+		case InputRemoved:
+			fmt.Fprintf(&sb, "\n• Removed input '%s'\n", c.Path)
+		case InputUpdated:
+			fmt.Fprintf(&sb, "\n• Updated input '%s':\n    %s\n  → %s\n", c.Path, formatRef(c.Before), formatRef(c.After))
+		}
+	}
+
+	return sb.String()
+}
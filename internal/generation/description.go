@@ -0,0 +1,36 @@
+package generation
+
+import (
+	"github.com/nix-community/nixos-cli/internal/system"
+)
+
+const descriptionMetadataFilename = "description.json"
+
+// A description of a generation that was set after it was built,
+// which takes precedence over the description in `nixos-version.json`.
+type DescriptionOverlay struct {
+	// Store path of the generation when the description was set.
+	StorePath   string `json:"store_path"`
+	Description string `json:"description"`
+}
+
+func WriteDescription(s system.System, profile string, number uint64, overlay *DescriptionOverlay) error {
+	return writeGenerationMetadata(s, profile, number, descriptionMetadataFilename, overlay)
+}
+
+// Read the description overlay of a generation, if it
+// was set for the same store path.
+func ReadDescription(profile string, number uint64, storePath string) (*DescriptionOverlay, error) {
+	var overlay DescriptionOverlay
+
+	found, err := readGenerationMetadata(profile, number, storePath, descriptionMetadataFilename, &overlay)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return &overlay, nil
+}
+
+func RemoveDescription(profile string, number uint64) error {
+	return removeGenerationMetadata(profile, number, descriptionMetadataFilename)
+}
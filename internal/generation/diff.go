@@ -1,8 +1,9 @@
 package generation
 
 import (
-	"os/exec"
+	"os"
 
+	"github.com/nix-community/nixos-cli/internal/closure"
 	"github.com/nix-community/nixos-cli/internal/logger"
 	"github.com/nix-community/nixos-cli/internal/system"
 )
@@ -12,31 +13,73 @@ type DiffCommandOptions struct {
 	Verbose bool
 }
 
-func RunDiffCommand(log *logger.Logger, s system.CommandRunner, before string, after string, opts *DiffCommandOptions) error {
+// Display the differences between the closures of two store paths.
+// This uses the built-in closure diff, unless `nvd` was requested
+// and is available.
+func RunDiffCommand(log *logger.Logger, s system.System, before string, after string, opts *DiffCommandOptions) error {
 	useNvd := opts.UseNvd
 
 	if opts.UseNvd {
-		nvdPath, _ := exec.LookPath("nvd")
-		nvdFound := nvdPath != ""
-		if !nvdFound {
+		if !system.HasCommand(s, "nvd") {
 			log.Warn("use_nvd is specified in config, but `nvd` is not executable")
-			log.Warn("falling back to `nix store diff-closures`")
+			log.Warn("falling back to built-in closure diff")
 			useNvd = false
 		}
 	}
 
-	argv := []string{"nix", "store", "diff-closures", before, after}
-	if useNvd {
-		argv = []string{"nvd", "diff", before, after}
+	if !useNvd {
+		diff, err := DiffClosures(s, before, after, opts.Verbose)
+		if err != nil {
+			return err
+		}
+
+		log.Event("diff", map[string]any{
+			"before": before,
+			"after":  after,
+			"tool":   "builtin",
+			"diff":   diff,
+		})
+
+		// The diff is already part of the event, so the text
+		// version is only for people watching the output.
+		out := os.Stdout
+		if log.OutputFormat() == logger.OutputFormatJSON {
+			out = os.Stderr
+		}
+
+		return closure.WriteText(out, diff)
 	}
 
+	argv := []string{"nvd", "diff", before, after}
+
 	if opts.Verbose {
 		s.Logger().CmdArray(argv)
 	}
 
+	log.Event("diff", map[string]any{
+		"before": before,
+		"after":  after,
+		"tool":   argv[0],
+	})
+
 	cmd := system.NewCommand(argv[0], argv[1:]...)
 
 	_, err := s.Run(cmd)
 
 	return err
 }
+
+// Compare the closures of two store paths on the given system.
+func DiffClosures(s system.CommandRunner, before string, after string, verbose bool) (*closure.Diff, error) {
+	beforeClosure, err := closure.Query(s, before, verbose)
+	if err != nil {
+		return nil, err
+	}
+
+	afterClosure, err := closure.Query(s, after, verbose)
+	if err != nil {
+		return nil, err
+	}
+
+	return closure.Compare(beforeClosure, afterClosure), nil
+}
@@ -0,0 +1,35 @@
+package generation
+
+import (
+	"github.com/nix-community/nixos-cli/internal/flake"
+	"github.com/nix-community/nixos-cli/internal/system"
+)
+
+const flakeLockMetadataFilename = "flake-lock.json"
+
+// Flake lock file of a generation, along with the
+// store path that it was recorded for.
+type flakeLockRecord struct {
+	StorePath string `json:"store_path"`
+	flake.LockFile
+}
+
+// Record the lock file of the flake that a generation was built from,
+// so that its inputs can be compared with those of other generations.
+func WriteFlakeLock(s system.System, profile string, number uint64, storePath string, lock *flake.LockFile) error {
+	record := flakeLockRecord{StorePath: storePath, LockFile: *lock}
+	return writeGenerationMetadata(s, profile, number, flakeLockMetadataFilename, &record)
+}
+
+// Read the flake lock file of a generation, if it
+// was recorded for the same store path.
+func ReadFlakeLock(profile string, number uint64, storePath string) (*flake.LockFile, error) {
+	var record flakeLockRecord
+
+	found, err := readGenerationMetadata(profile, number, storePath, flakeLockMetadataFilename, &record)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return &record.LockFile, nil
+}
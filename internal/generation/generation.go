@@ -12,6 +12,7 @@ import (
 
 	"github.com/djherbis/times"
 	"github.com/nix-community/nixos-cli/internal/constants"
+	"github.com/nix-community/nixos-cli/internal/git"
 	"github.com/nix-community/nixos-cli/internal/logger"
 )
 
@@ -34,6 +35,13 @@ type Generation struct {
 	NixpkgsRevision       string `json:"nixpkgs_revision"`
 	ConfigurationRevision string `json:"configuration_revision"`
 	Description           string `json:"description"`
+
+	HealthCheck   *HealthCheckResult `json:"health_check"`
+	GitProvenance *git.Provenance    `json:"git_provenance"`
+	ClosureSize   *ClosureSize       `json:"closure_size"`
+	Pin           *Pin               `json:"pin"`
+	// Set if the generation may be pinned, but its pin could not be read.
+	PinError error `json:"-"`
 }
 
 type GenerationManifest struct {
@@ -53,7 +61,11 @@ func (e *GenerationReadError) Error() string {
 	return fmt.Sprintf("failed to read generation %d from directory %s", e.Number, e.Directory)
 }
 
-func GenerationFromDirectory(generationDirname string, number uint64) (*Generation, error) {
+// Read information about a generation from its directory. The
+// description can be changed after the generation is built, so
+// the description overlay for this generation number in `profile`
+// is merged over the one in `nixos-version.json`, if it exists.
+func GenerationFromDirectory(generationDirname string, profile string, number uint64) (*Generation, error) {
 	nixosVersionManifestFile := filepath.Join(generationDirname, "nixos-version.json")
 
 	if _, err := os.Stat(generationDirname); err != nil {
@@ -87,6 +99,16 @@ func GenerationFromDirectory(generationDirname string, number uint64) (*Generati
 		}
 	}
 
+	// `generationDirname` may point to a specialisation, so the overlay is
+	// matched against the store path of the generation link instead. If it
+	// cannot be read, the original description is still usable.
+	generationLink := fmt.Sprintf("%s-%d-link", GetProfileDirectoryFromName(profile), number)
+	if storePath, err := filepath.EvalSymlinks(generationLink); err == nil {
+		if overlay, err := ReadDescription(profile, number, storePath); err == nil && overlay != nil {
+			info.Description = overlay.Description
+		}
+	}
+
 	// Fall back to reading the nixos-version file that should always
 	// exist if the version doesn't.
 	if info.NixosVersion == "" {
@@ -183,7 +205,7 @@ func CollectGenerationsInProfile(log *logger.Logger, profile string) ([]Generati
 
 			generationDirectoryName := filepath.Join(profileDirectory, fmt.Sprintf("%s-%d-link", profile, genNumber))
 
-			info, err := GenerationFromDirectory(generationDirectoryName, uint64(genNumber))
+			info, err := GenerationFromDirectory(generationDirectoryName, profile, uint64(genNumber))
 			if err != nil {
 				return nil, err
 			}
@@ -192,6 +214,35 @@ func CollectGenerationsInProfile(log *logger.Logger, profile string) ([]Generati
 				info.IsCurrent = true
 			}
 
+			if storePath, err := filepath.EvalSymlinks(generationDirectoryName); err == nil {
+				healthCheck, err := ReadHealthCheckResult(profile, uint64(genNumber), storePath)
+				if err != nil {
+					log.Warnf("failed to read health check result for generation %v: %v", genNumber, err)
+				}
+				info.HealthCheck = healthCheck
+
+				gitProvenance, err := ReadGitProvenance(profile, uint64(genNumber), storePath)
+				if err != nil {
+					log.Warnf("failed to read git provenance for generation %v: %v", genNumber, err)
+				}
+				info.GitProvenance = gitProvenance
+
+				// Only cached sizes are read here, since computing
+				// them for every generation would be too slow.
+				closureSize, err := ReadClosureSize(profile, uint64(genNumber), storePath)
+				if err != nil {
+					log.Warnf("failed to read closure size for generation %v: %v", genNumber, err)
+				}
+				info.ClosureSize = closureSize
+			}
+
+			pin, err := LoadPin(profile, uint64(genNumber), generationDirectoryName)
+			if err != nil {
+				log.Warnf("failed to read pin for generation %v: %v", genNumber, err)
+				info.PinError = err
+			}
+			info.Pin = pin
+
 			generations = append(generations, *info)
 		}
 	}
@@ -0,0 +1,40 @@
+package generation
+
+import (
+	"time"
+
+	"github.com/nix-community/nixos-cli/internal/system"
+)
+
+const healthCheckMetadataFilename = "health-check.json"
+
+type HealthCheckResult struct {
+	Time     time.Time `json:"time"`
+	Passed   bool      `json:"passed"`
+	Failures []string  `json:"failures"`
+}
+
+// Health check result of a generation, along with
+// the store path that it was recorded for.
+type healthCheckRecord struct {
+	StorePath string `json:"store_path"`
+	HealthCheckResult
+}
+
+func WriteHealthCheckResult(s system.System, profile string, number uint64, storePath string, result *HealthCheckResult) error {
+	record := healthCheckRecord{StorePath: storePath, HealthCheckResult: *result}
+	return writeGenerationMetadata(s, profile, number, healthCheckMetadataFilename, &record)
+}
+
+// Read the health check result of a generation, if it
+// was recorded for the same store path.
+func ReadHealthCheckResult(profile string, number uint64, storePath string) (*HealthCheckResult, error) {
+	var record healthCheckRecord
+
+	found, err := readGenerationMetadata(profile, number, storePath, healthCheckMetadataFilename, &record)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return &record.HealthCheckResult, nil
+}
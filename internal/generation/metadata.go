@@ -0,0 +1,86 @@
+package generation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nix-community/nixos-cli/internal/constants"
+	"github.com/nix-community/nixos-cli/internal/system"
+)
+
+// Generations themselves live in the Nix store and cannot be modified
+// after they are built. Any information about a generation that is only
+// known later (such as the result of activating it) is kept in a separate
+// state directory instead, keyed by profile and generation number.
+func GetGenerationMetadataDirectory(profile string, number uint64) string {
+	return filepath.Join(constants.NixOSCLIStateDirectory, "generations", profile, fmt.Sprintf("%d", number))
+}
+
+func writeGenerationMetadata(s system.System, profile string, number uint64, filename string, value any) error {
+	metadataDirectory := GetGenerationMetadataDirectory(profile, number)
+
+	if err := system.MkdirAll(s, metadataDirectory, 0o755); err != nil {
+		return err
+	}
+
+	contents, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return system.WriteFile(s, filepath.Join(metadataDirectory, filename), contents, 0o644)
+}
+
+// Every metadata file records the store path of the generation it
+// belongs to in a `store_path` field.
+type generationMetadataHeader struct {
+	StorePath string `json:"store_path"`
+}
+
+// Read a metadata file for a generation into `value`. Returns false if
+// no such metadata has been recorded for the generation.
+//
+// Generation numbers can be reused after generations are deleted by
+// other tools, so metadata that was recorded for a different store
+// path is treated as if it had not been recorded at all.
+func readGenerationMetadata(profile string, number uint64, storePath string, filename string, value any) (bool, error) {
+	contents, err := os.ReadFile(filepath.Join(GetGenerationMetadataDirectory(profile, number), filename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	var header generationMetadataHeader
+	if err := json.Unmarshal(contents, &header); err != nil {
+		return false, err
+	}
+	if header.StorePath != storePath {
+		return false, nil
+	}
+
+	if err := json.Unmarshal(contents, value); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Remove a metadata file for a generation, if it exists.
+func removeGenerationMetadata(profile string, number uint64, filename string) error {
+	err := os.Remove(filepath.Join(GetGenerationMetadataDirectory(profile, number), filename))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// Remove all metadata for a generation, such as after it
+// has been deleted, so that it does not outlive it.
+func RemoveGenerationMetadata(profile string, number uint64) error {
+	return os.RemoveAll(GetGenerationMetadataDirectory(profile, number))
+}
@@ -0,0 +1,73 @@
+package generation
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nix-community/nixos-cli/internal/system"
+)
+
+const pinMetadataFilename = "pin.json"
+
+// A pinned generation is never deleted by `nixos generation delete`,
+// in the same way as the current generation.
+type Pin struct {
+	// Store path of the generation when it was pinned.
+	StorePath string    `json:"store_path"`
+	Reason    string    `json:"reason"`
+	User      string    `json:"user"`
+	Time      time.Time `json:"time"`
+}
+
+// Describe who pinned the generation, when, and why.
+func (p *Pin) Describe() string {
+	desc := "pinned"
+	if p.User != "" {
+		desc += fmt.Sprintf(" by %v", p.User)
+	}
+	desc += fmt.Sprintf(" on %v", p.Time.Format(time.ANSIC))
+
+	if p.Reason != "" {
+		desc += fmt.Sprintf(": %v", p.Reason)
+	}
+
+	return desc
+}
+
+func WritePin(s system.System, profile string, number uint64, pin *Pin) error {
+	return writeGenerationMetadata(s, profile, number, pinMetadataFilename, pin)
+}
+
+// Read the pin of a generation, if it was pinned with the same store path.
+func ReadPin(profile string, number uint64, storePath string) (*Pin, error) {
+	var pin Pin
+
+	found, err := readGenerationMetadata(profile, number, storePath, pinMetadataFilename, &pin)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return &pin, nil
+}
+
+func RemovePin(profile string, number uint64) error {
+	return removeGenerationMetadata(profile, number, pinMetadataFilename)
+}
+
+// Get the pin of a generation from its generation link. Pins protect
+// generations from deletion, so unlike other metadata, an error is
+// returned if a pin exists but cannot be checked against the link.
+func LoadPin(profile string, number uint64, generationDirname string) (*Pin, error) {
+	storePath, err := filepath.EvalSymlinks(generationDirname)
+	if err != nil {
+		_, statErr := os.Stat(filepath.Join(GetGenerationMetadataDirectory(profile, number), pinMetadataFilename))
+		if os.IsNotExist(statErr) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return ReadPin(profile, number, storePath)
+}
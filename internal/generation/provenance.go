@@ -0,0 +1,33 @@
+package generation
+
+import (
+	"github.com/nix-community/nixos-cli/internal/git"
+	"github.com/nix-community/nixos-cli/internal/system"
+)
+
+const gitProvenanceMetadataFilename = "git-provenance.json"
+
+// Git provenance of a generation, along with the
+// store path that it was recorded for.
+type gitProvenanceRecord struct {
+	StorePath string `json:"store_path"`
+	git.Provenance
+}
+
+func WriteGitProvenance(s system.System, profile string, number uint64, storePath string, provenance *git.Provenance) error {
+	record := gitProvenanceRecord{StorePath: storePath, Provenance: *provenance}
+	return writeGenerationMetadata(s, profile, number, gitProvenanceMetadataFilename, &record)
+}
+
+// Read the git provenance of a generation, if it
+// was recorded for the same store path.
+func ReadGitProvenance(profile string, number uint64, storePath string) (*git.Provenance, error) {
+	var record gitProvenanceRecord
+
+	found, err := readGenerationMetadata(profile, number, storePath, gitProvenanceMetadataFilename, &record)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return &record.Provenance, nil
+}
@@ -0,0 +1,74 @@
+package generation
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/nix-community/nixos-cli/internal/closure"
+	"github.com/nix-community/nixos-cli/internal/system"
+)
+
+const closureSizeMetadataFilename = "closure-size.json"
+
+// Size of the closure of a generation. Computing this requires querying
+// every path in the closure, so it is cached once it is known.
+type ClosureSize struct {
+	// Store path that the size was computed for.
+	StorePath string `json:"store_path"`
+	NarSize   uint64 `json:"nar_size"`
+	PathCount int    `json:"path_count"`
+}
+
+func ClosureSizeFromClosure(storePath string, c *closure.Closure) *ClosureSize {
+	return &ClosureSize{
+		StorePath: storePath,
+		NarSize:   c.Size(),
+		PathCount: len(c.Paths),
+	}
+}
+
+func WriteClosureSize(s system.System, profile string, number uint64, size *ClosureSize) error {
+	return writeGenerationMetadata(s, profile, number, closureSizeMetadataFilename, size)
+}
+
+// Read the cached closure size of a generation, if it
+// was computed for the same store path.
+func ReadClosureSize(profile string, number uint64, storePath string) (*ClosureSize, error) {
+	var size ClosureSize
+
+	found, err := readGenerationMetadata(profile, number, storePath, closureSizeMetadataFilename, &size)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return &size, nil
+}
+
+// Get the closure size of a generation, querying it and caching the result
+// if it has not been cached yet. Only root can write to the cache, so
+// failing to write it due to missing permissions is not an error.
+func LoadClosureSize(s *system.LocalSystem, profile string, number uint64, generationDirname string, verbose bool) (*ClosureSize, error) {
+	storePath, err := filepath.EvalSymlinks(generationDirname)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := ReadClosureSize(profile, number, storePath)
+	if err != nil || size != nil {
+		return size, err
+	}
+
+	c, err := closure.Query(s, storePath, verbose)
+	if err != nil {
+		return nil, err
+	}
+
+	size = ClosureSizeFromClosure(storePath, c)
+
+	if err := WriteClosureSize(s, profile, number, size); err != nil && !errors.Is(err, fs.ErrPermission) {
+		return size, err
+	}
+
+	return size, nil
+}
@@ -2,8 +2,6 @@ package generation
 
 import (
 	"encoding/json"
-	"fmt"
-	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -34,29 +32,17 @@ func CollectSpecialisations(generationDirname string) ([]string, error) {
 }
 
 func CollectSpecialisationsFromConfig(cfg configuration.Configuration) []string {
-	var argv []string
-
-	switch c := cfg.(type) {
-	case *configuration.FlakeRef:
-		attr := fmt.Sprintf("%s#nixosConfigurations.%s.config.specialisation", c.URI, c.System)
-		argv = []string{"nix", "eval", attr, "--apply", "builtins.attrNames", "--json"}
-	case *configuration.LegacyConfiguration:
-		argv = []string{
-			"nix-instantiate", "--eval", "--json", "--expr", "builtins.attrNames",
-			"builtins.attrNames (import <nixpkgs/nixos> {}).config.specialisation",
-		}
-	}
-
-	cmd := exec.Command(argv[0], argv[1:]...)
-
-	stdout, err := cmd.Output()
+	value, err := cfg.EvalAttribute("specialisation", &configuration.EvalOptions{
+		JSON:  true,
+		Apply: "builtins.attrNames",
+	})
 	if err != nil {
 		return []string{}
 	}
 
 	specialisations := []string{}
 
-	err = json.Unmarshal(stdout, &specialisations)
+	err = json.Unmarshal([]byte(*value), &specialisations)
 	if err != nil {
 		return []string{}
 	}
@@ -87,16 +73,36 @@ func CompleteSpecialisationFlag(generationDirname string) cobra.CompletionFunc {
 	}
 }
 
-func CompleteSpecialisationFlagFromConfig(flakeRefStr string, includes []string) cobra.CompletionFunc {
+// Complete specialisation names from the configuration that would be
+// built. The arguments point to the values of the command's flags, which
+// are only parsed once completion is requested.
+func CompleteSpecialisationFlagFromConfig(flakeRefStr *string, file *string, attr *string, includes *[]string) cobra.CompletionFunc {
 	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		log := logger.FromContext(cmd.Context())
 		cfg := settings.FromContext(cmd.Context())
 
+		flakeRef := *flakeRefStr
+		if flakeRef == "" && len(args) > 0 {
+			flakeRef = args[0]
+		}
+
 		var nixConfig configuration.Configuration
-		if flakeRefStr != "" {
-			nixConfig = configuration.FlakeRefFromString(flakeRefStr)
+		if *file != "" || *attr != "" {
+			c, err := configuration.FileConfigurationFromPath(*file, *attr, *includes)
+			if err != nil {
+				log.Errorf("failed to find configuration: %v", err)
+				return []string{}, cobra.ShellCompDirectiveNoFileComp
+			}
+			nixConfig = c
+		} else if flakeRef != "" {
+			f := configuration.FlakeRefFromString(flakeRef)
+			if err := f.InferSystemFromHostnameIfNeeded(); err != nil {
+				log.Errorf("failed to infer hostname: %v", err)
+				return []string{}, cobra.ShellCompDirectiveNoFileComp
+			}
+			nixConfig = f
 		} else {
-			c, err := configuration.FindConfiguration(log, cfg, includes, false)
+			c, err := configuration.FindConfiguration(log, cfg, *includes, false)
 			if err != nil {
 				log.Errorf("failed to find configuration: %v", err)
 				return []string{}, cobra.ShellCompDirectiveNoFileComp
@@ -126,3 +132,41 @@ func CompleteSpecialisationFlagFromConfig(flakeRefStr string, includes []string)
 		return candidates, cobra.ShellCompDirectiveNoFileComp
 	}
 }
+
+// Find which specialisation of a generation a system path, such as
+// `/run/current-system`, points to. An empty name refers to the base
+// configuration, and `ok` is false if the system path is not part
+// of the generation at all.
+func FindActiveSpecialisation(generationDirname string, systemPath string) (name string, ok bool, err error) {
+	target, err := filepath.EvalSymlinks(systemPath)
+	if err != nil {
+		return "", false, err
+	}
+
+	base, err := filepath.EvalSymlinks(generationDirname)
+	if err != nil {
+		return "", false, err
+	}
+
+	if target == base {
+		return "", true, nil
+	}
+
+	specialisations, err := CollectSpecialisations(generationDirname)
+	if err != nil {
+		return "", false, err
+	}
+
+	for _, specialisation := range specialisations {
+		path, err := filepath.EvalSymlinks(filepath.Join(generationDirname, "specialisation", specialisation))
+		if err != nil {
+			continue
+		}
+
+		if target == path {
+			return specialisation, true, nil
+		}
+	}
+
+	return "", false, nil
+}
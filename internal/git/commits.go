@@ -0,0 +1,177 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+
+	"github.com/nix-community/nixos-cli/internal/utils"
+)
+
+// Count the commits between two revisions in the repository at the
+// given path. `ahead` is the number of commits in `to` that are not in
+// `from`, and `behind` is the number of commits in `from` that are not
+// in `to`.
+func CountCommitsBetween(path string, from string, to string) (ahead int, behind int, err error) {
+	repo, err := gogit.PlainOpenWithOptions(path, &gogit.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	fromCommit, err := repo.CommitObject(plumbing.NewHash(from))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	toCommit, err := repo.CommitObject(plumbing.NewHash(to))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	ahead, err = countCommitsNotIn(repo, toCommit, fromCommit.Hash)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	behind, err = countCommitsNotIn(repo, fromCommit, toCommit.Hash)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return ahead, behind, nil
+}
+
+// Returned by CommitFile when other changes are already staged,
+// since they would otherwise be included in the commit.
+var ErrOtherChangesStaged = errors.New("other changes are already staged")
+
+// Commit the current contents of a single file in the repository
+// that contains it, using the author from the git configuration.
+//
+// Commands are usually re-executed as root, so git is run as the user
+// that invoked the command, if any. This keeps the files in the
+// repository owned by that user, and makes sure that the commit is
+// authored by them rather than by root.
+func CommitFile(filename string, message string) (string, error) {
+	absFilename, err := filepath.Abs(filename)
+	if err != nil {
+		return "", err
+	}
+
+	repo, err := gogit.PlainOpenWithOptions(filepath.Dir(absFilename), &gogit.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return "", err
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+
+	root := wt.Filesystem.Root()
+
+	relFilename, err := filepath.Rel(root, absFilename)
+	if err != nil {
+		return "", err
+	}
+	relFilename = filepath.ToSlash(relFilename)
+
+	status, err := wt.Status()
+	if err != nil {
+		return "", err
+	}
+
+	for path, fileStatus := range status {
+		if path == relFilename {
+			continue
+		}
+		if fileStatus.Staging != gogit.Unmodified && fileStatus.Staging != gogit.Untracked {
+			return "", ErrOtherChangesStaged
+		}
+	}
+
+	committer := utils.GetInvokingUserAccount()
+
+	name, _ := runGit(root, committer, "config", "--get", "user.name")
+	email, _ := runGit(root, committer, "config", "--get", "user.email")
+	if name == "" || email == "" {
+		return "", fmt.Errorf("no git author identity is configured, set user.name and user.email")
+	}
+	author := fmt.Sprintf("%s <%s>", name, email)
+
+	if _, err := runGit(root, committer, "add", "--", relFilename); err != nil {
+		return "", err
+	}
+
+	if _, err := runGit(root, committer, "commit", "--quiet", "--author", author, "--message", message, "--", relFilename); err != nil {
+		return "", err
+	}
+
+	return runGit(root, committer, "rev-parse", "HEAD")
+}
+
+// Run git in a repository, as the given user if it is not nil, and
+// return its trimmed output.
+func runGit(dir string, u *user.User, args ...string) (string, error) {
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if u != nil {
+		credential, err := userCredential(u)
+		if err != nil {
+			return "", err
+		}
+
+		cmd.SysProcAttr = &syscall.SysProcAttr{Credential: credential}
+		cmd.Env = append(os.Environ(), "HOME="+u.HomeDir, "USER="+u.Username, "LOGNAME="+u.Username)
+	}
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return "", fmt.Errorf("git %v: %w", args[0], err)
+		}
+		return "", fmt.Errorf("git %v: %v", args[0], msg)
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func userCredential(u *user.User) (*syscall.Credential, error) {
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	credential := &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+
+	if groupIds, err := u.GroupIds(); err == nil {
+		for _, g := range groupIds {
+			if parsed, err := strconv.ParseUint(g, 10, 32); err == nil {
+				credential.Groups = append(credential.Groups, uint32(parsed))
+			}
+		}
+	}
+
+	return credential, nil
+}
@@ -0,0 +1,72 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gogit "github.com/go-git/go-git/v5"
+)
+
+func TestCommitFile(t *testing.T) {
+	dir := t.TempDir()
+
+	repo, err := gogit.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.User.Name = "test"
+	cfg.User.Email = "test@example.com"
+	if err := repo.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	first := commitFile(t, repo, dir, "flake.nix", "{ }")
+
+	lockFile := filepath.Join(dir, "flake.lock")
+	if err := os.WriteFile(lockFile, []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "flake.nix"), []byte("{ x = 1; }"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := CommitFile(lockFile, "flake.lock: Update")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ahead, behind, err := CountCommitsBetween(dir, first.String(), second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ahead != 1 || behind != 0 {
+		t.Errorf("expected 1 commit ahead and 0 behind, got %v and %v", ahead, behind)
+	}
+
+	// Unrelated changes must not be included in the commit.
+	p, err := CollectProvenance(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Dirty {
+		t.Errorf("expected unrelated changes to be left uncommitted")
+	}
+
+	wt, _ := repo.Worktree()
+	if _, err := wt.Add("flake.nix"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(lockFile, []byte(`{"version": 7}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CommitFile(lockFile, "flake.lock: Update"); err != ErrOtherChangesStaged {
+		t.Errorf("expected ErrOtherChangesStaged, got %v", err)
+	}
+}
@@ -0,0 +1,264 @@
+package git
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Information about the state of the git repository
+// that a configuration was built from.
+type Provenance struct {
+	Commit  string `json:"commit"`
+	Message string `json:"message"`
+	// Empty if HEAD is detached
+	Branch string `json:"branch,omitempty"`
+	// Remote-tracking branch of the current branch, such as "origin/main"
+	Upstream  string `json:"upstream,omitempty"`
+	RemoteURL string `json:"remote_url,omitempty"`
+	Dirty     bool   `json:"dirty"`
+	// Hash of all uncommitted changes, if the tree is dirty. Two builds
+	// with the same diff hash were built from the same uncommitted state.
+	DiffHash string `json:"diff_hash,omitempty"`
+	// Number of commits on the current branch that are not in its
+	// upstream branch, or -1 if there is no upstream to compare to.
+	Unpushed int `json:"unpushed"`
+}
+
+// Short form of the commit hash, with a marker if the tree was dirty.
+func (p *Provenance) ShortCommit() string {
+	commit := p.Commit
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+	if p.Dirty {
+		commit += "-dirty"
+	}
+	return commit
+}
+
+// Describe the commit, branch, and push state in a single line,
+// such as "0123456789ab-dirty on main (2 unpushed)".
+func (p *Provenance) Describe() string {
+	desc := p.ShortCommit()
+
+	if p.Branch != "" {
+		desc += " on " + p.Branch
+	} else {
+		desc += " (detached)"
+	}
+
+	if p.Unpushed > 0 {
+		desc += fmt.Sprintf(" (%d unpushed)", p.Unpushed)
+	} else if p.Unpushed < 0 && p.Branch != "" {
+		desc += " (no upstream)"
+	}
+
+	return desc
+}
+
+// First line of the commit message.
+func (p *Provenance) Subject() string {
+	subject, _, _ := strings.Cut(strings.TrimSpace(p.Message), "\n")
+	return subject
+}
+
+// Returned when a path is not inside a git repository.
+var ErrNotRepository = gogit.ErrRepositoryNotExists
+
+// Collect the provenance of the git repository that contains the
+// given path. ErrNotRepository is returned if there is no repository.
+func CollectProvenance(path string) (*Provenance, error) {
+	repo, err := gogit.PlainOpenWithOptions(path, &gogit.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+
+	headCommit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Provenance{
+		Commit:   head.Hash().String(),
+		Message:  headCommit.Message,
+		Unpushed: -1,
+	}
+
+	remoteName := ""
+
+	if head.Name().IsBranch() {
+		p.Branch = head.Name().Short()
+
+		// Branches without any upstream configuration
+		// are not considered to be an error.
+		if branch, err := repo.Branch(p.Branch); err == nil && branch.Remote != "" && branch.Merge != "" {
+			remoteName = branch.Remote
+
+			upstreamRef := plumbing.NewRemoteReferenceName(branch.Remote, branch.Merge.Short())
+			p.Upstream = upstreamRef.Short()
+
+			if ref, err := repo.Reference(upstreamRef, true); err == nil {
+				unpushed, err := countCommitsNotIn(repo, headCommit, ref.Hash())
+				if err != nil {
+					return nil, err
+				}
+				p.Unpushed = unpushed
+			}
+		}
+	}
+
+	p.RemoteURL = findRemoteURL(repo, remoteName)
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := wt.Status()
+	if err != nil {
+		return nil, err
+	}
+	status = trackedChanges(status)
+
+	if !status.IsClean() {
+		p.Dirty = true
+
+		diffHash, err := hashUncommittedChanges(wt, status)
+		if err != nil {
+			return nil, err
+		}
+		p.DiffHash = diffHash
+	}
+
+	return p, nil
+}
+
+// Count the commits reachable from head that are not reachable from other.
+func countCommitsNotIn(repo *gogit.Repository, head *object.Commit, other plumbing.Hash) (int, error) {
+	if head.Hash == other {
+		return 0, nil
+	}
+
+	otherCommit, err := repo.CommitObject(other)
+	if err != nil {
+		return 0, err
+	}
+
+	bases, err := head.MergeBase(otherCommit)
+	if err != nil {
+		return 0, err
+	}
+
+	ignore := make([]plumbing.Hash, len(bases))
+	for i, base := range bases {
+		ignore[i] = base.Hash
+	}
+
+	count := 0
+	err = object.NewCommitPreorderIter(head, nil, ignore).ForEach(func(c *object.Commit) error {
+		count++
+		return nil
+	})
+
+	return count, err
+}
+
+// Find the URL of the given remote, or "origin" if no remote
+// name is given. Credentials are removed from the URL.
+func findRemoteURL(repo *gogit.Repository, name string) string {
+	if name == "" {
+		name = "origin"
+	}
+
+	remote, err := repo.Remote(name)
+	if err != nil {
+		return ""
+	}
+
+	urls := remote.Config().URLs
+	if len(urls) == 0 {
+		return ""
+	}
+
+	return redactURL(urls[0])
+}
+
+func redactURL(remoteURL string) string {
+	u, err := url.Parse(remoteURL)
+	if err != nil || u.User == nil {
+		return remoteURL
+	}
+
+	if _, hasPassword := u.User.Password(); hasPassword {
+		u.User = url.User(u.User.Username())
+	}
+
+	return u.String()
+}
+
+// Remove untracked files from a worktree status. Nix only copies tracked
+// files from a Git repository, so untracked files such as `result` links
+// do not affect the configuration. go-git also ignores the global and
+// system gitignore files, so such files would not always be ignored.
+func trackedChanges(status gogit.Status) gogit.Status {
+	tracked := make(gogit.Status, len(status))
+	for path, fileStatus := range status {
+		if fileStatus.Staging == gogit.Untracked && fileStatus.Worktree == gogit.Untracked {
+			continue
+		}
+		tracked[path] = fileStatus
+	}
+	return tracked
+}
+
+// Hash the status and contents of all changed files in the worktree.
+func hashUncommittedChanges(wt *gogit.Worktree, status gogit.Status) (string, error) {
+	paths := make([]string, 0, len(status))
+	for path := range status {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	h := sha256.New()
+
+	for _, path := range paths {
+		fileStatus := status[path]
+		fmt.Fprintf(h, "%s\x00%c%c\x00", path, fileStatus.Staging, fileStatus.Worktree)
+
+		file, err := wt.Filesystem.Open(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				fmt.Fprint(h, "-\n")
+				continue
+			}
+			return "", err
+		}
+
+		fileHash := sha256.New()
+		_, err = io.Copy(fileHash, file)
+		_ = file.Close()
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprintf(h, "%x\n", fileHash.Sum(nil))
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,164 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nix-community/nixos-cli/internal/constants"
+	"github.com/nix-community/nixos-cli/internal/system"
+	"github.com/nix-community/nixos-cli/internal/utils"
+)
+
+// Location of the activation history journal. This is a file with
+// one JSON record per line, and records are only ever appended to it.
+const JournalFile = constants.NixOSCLIStateDirectory + "/history.jsonl"
+
+type Status string
+
+const (
+	StatusSuccess Status = "success"
+	StatusFailure Status = "failure"
+)
+
+// A record of a single command that modified a system profile.
+type Record struct {
+	Time time.Time `json:"time"`
+	// User that invoked the command, before it was re-executed as root
+	User        string `json:"user"`
+	CommandLine string `json:"command_line"`
+	// Name of the command, such as "apply" or "generation switch"
+	Command string `json:"command"`
+	// Activation action, such as "switch" or "boot", or "delete"
+	Action             string   `json:"action"`
+	Profile            string   `json:"profile"`
+	PreviousGeneration uint64   `json:"previous_generation,omitempty"`
+	NewGeneration      uint64   `json:"new_generation,omitempty"`
+	DeletedGenerations []uint64 `json:"deleted_generations,omitempty"`
+	Tag                string   `json:"tag,omitempty"`
+
+	Status   Status `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration int64  `json:"duration_ms"`
+}
+
+// Start a new record for the currently running command.
+func NewRecord(command string, profile string) *Record {
+	return &Record{
+		Time:        time.Now(),
+		User:        utils.GetInvokingUser(),
+		CommandLine: utils.EscapeAndJoinArgs(os.Args),
+		Command:     command,
+		Profile:     profile,
+	}
+}
+
+// Set the final status and duration of the command.
+func (r *Record) Finish(err error) {
+	r.Duration = time.Since(r.Time).Milliseconds()
+
+	if err != nil {
+		r.Status = StatusFailure
+		r.Error = err.Error()
+	} else {
+		r.Status = StatusSuccess
+	}
+}
+
+// Append a record to the journal on the given system.
+func Append(s system.System, record *Record) error {
+	contents, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	contents = append(contents, '\n')
+
+	if err := system.MkdirAll(s, filepath.Dir(JournalFile), 0o755); err != nil {
+		return err
+	}
+
+	return system.AppendFile(s, JournalFile, contents, 0o644)
+}
+
+// Read all records from the journal on the local system, from oldest
+// to newest. Lines that cannot be parsed are skipped, and the number
+// of skipped lines is returned.
+func Read() ([]Record, int, error) {
+	file, err := os.Open(JournalFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []Record{}, 0, nil
+		}
+		return nil, 0, err
+	}
+	defer func() { _ = file.Close() }()
+
+	records, skipped, err := readRecords(file)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read %v: %w", JournalFile, err)
+	}
+
+	return records, skipped, nil
+}
+
+func readRecords(r io.Reader) ([]Record, int, error) {
+	records := []Record{}
+	skipped := 0
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(line, &record); err != nil {
+			skipped++
+			continue
+		}
+		records = append(records, record)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return records, skipped, nil
+}
+
+// Criteria for selecting records from the journal. Zero values
+// match any record.
+type Filter struct {
+	Since      time.Time
+	User       string
+	Command    string
+	Profile    string
+	OnlyFailed bool
+}
+
+func (f *Filter) Matches(r *Record) bool {
+	if !f.Since.IsZero() && r.Time.Before(f.Since) {
+		return false
+	}
+	if f.User != "" && r.User != f.User {
+		return false
+	}
+	if f.Command != "" && r.Command != f.Command {
+		return false
+	}
+	if f.Profile != "" && r.Profile != f.Profile {
+		return false
+	}
+	if f.OnlyFailed && r.Status != StatusFailure {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package history
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadRecords(t *testing.T) {
+	input := `{"time":"2025-01-01T10:00:00Z","user":"alice","command":"apply","action":"switch","profile":"system","previous_generation":1,"new_generation":2,"status":"success","duration_ms":1500}
+
+not json
+{"time":"2025-01-02T10:00:00Z","user":"bob","command":"generation delete","action":"delete","profile":"system","deleted_generations":[1],"status":"failure","error":"oops","duration_ms":10}
+`
+
+	records, skipped, err := readRecords(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if skipped != 1 {
+		t.Errorf("expected 1 skipped line, got %v", skipped)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %v", len(records))
+	}
+
+	if records[0].NewGeneration != 2 || records[0].User != "alice" {
+		t.Errorf("unexpected first record: %+v", records[0])
+	}
+	if records[1].Status != StatusFailure || len(records[1].DeletedGenerations) != 1 {
+		t.Errorf("unexpected second record: %+v", records[1])
+	}
+}
+
+func TestFilterMatches(t *testing.T) {
+	now := time.Now()
+
+	record := Record{Time: now, User: "root", Command: "apply", Profile: "system"}
+	record.Finish(errors.New("build failed"))
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"empty", Filter{}, true},
+		{"since before", Filter{Since: now.Add(-time.Hour)}, true},
+		{"since after", Filter{Since: now.Add(time.Hour)}, false},
+		{"user", Filter{User: "root"}, true},
+		{"other user", Filter{User: "alice"}, false},
+		{"command", Filter{Command: "generation switch"}, false},
+		{"profile", Filter{Profile: "system", Command: "apply"}, true},
+		{"failed", Filter{OnlyFailed: true}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Matches(&record); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
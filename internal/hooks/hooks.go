@@ -0,0 +1,132 @@
+package hooks
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nix-community/nixos-cli/internal/settings"
+	"github.com/nix-community/nixos-cli/internal/system"
+)
+
+type Stage string
+
+const (
+	StagePreBuild     Stage = "pre_build"
+	StagePostBuild    Stage = "post_build"
+	StagePreActivate  Stage = "pre_activate"
+	StagePostActivate Stage = "post_activate"
+	StagePreDelete    Stage = "pre_delete"
+	StagePostDelete   Stage = "post_delete"
+	StageOnFailure    Stage = "on_failure"
+)
+
+// Information about the operation that is passed to
+// hooks through environment variables. Fields that are
+// not known at the time a hook is run are left unset.
+type Context struct {
+	// Name of the command that is running the hook, such as
+	// `apply` or `generation switch`.
+	Command       string
+	Profile       string
+	OldGeneration uint64
+	NewGeneration uint64
+	StorePath     string
+	Tag           string
+	TargetHost    string
+	// Generations that are being deleted, if any.
+	Generations []uint64
+	// The error that caused the operation to fail, for
+	// `on_failure` hooks.
+	Error error
+}
+
+func (c *Context) environment(stage Stage) map[string]string {
+	env := map[string]string{
+		"NIXOS_CLI_HOOK":    string(stage),
+		"NIXOS_CLI_COMMAND": c.Command,
+		"NIXOS_CLI_PROFILE": c.Profile,
+	}
+
+	if c.OldGeneration != 0 {
+		env["NIXOS_CLI_OLD_GENERATION"] = fmt.Sprintf("%d", c.OldGeneration)
+	}
+	if c.NewGeneration != 0 {
+		env["NIXOS_CLI_NEW_GENERATION"] = fmt.Sprintf("%d", c.NewGeneration)
+	}
+	if c.StorePath != "" {
+		env["NIXOS_CLI_STORE_PATH"] = c.StorePath
+	}
+	if c.Tag != "" {
+		env["NIXOS_CLI_TAG"] = c.Tag
+	}
+	if c.TargetHost != "" {
+		env["NIXOS_CLI_TARGET_HOST"] = c.TargetHost
+	}
+	if len(c.Generations) > 0 {
+		generations := make([]string, len(c.Generations))
+		for i, g := range c.Generations {
+			generations[i] = fmt.Sprintf("%d", g)
+		}
+		env["NIXOS_CLI_GENERATIONS"] = strings.Join(generations, " ")
+	}
+	if c.Error != nil {
+		env["NIXOS_CLI_ERROR"] = c.Error.Error()
+	}
+
+	return env
+}
+
+func commandsForStage(cfg *settings.HookSettings, stage Stage) []string {
+	switch stage {
+	case StagePreBuild:
+		return cfg.PreBuild
+	case StagePostBuild:
+		return cfg.PostBuild
+	case StagePreActivate:
+		return cfg.PreActivate
+	case StagePostActivate:
+		return cfg.PostActivate
+	case StagePreDelete:
+		return cfg.PreDelete
+	case StagePostDelete:
+		return cfg.PostDelete
+	case StageOnFailure:
+		return cfg.OnFailure
+	default:
+		panic("unknown hook stage")
+	}
+}
+
+// Run all hooks configured for a stage in order, using `sh -c`.
+// The first hook that exits with a non-zero status stops the
+// remaining hooks from running, and its error is returned so
+// that the caller can abort the operation.
+func Run(s system.CommandRunner, cfg *settings.HookSettings, stage Stage, ctx *Context, verbose bool) error {
+	commands := commandsForStage(cfg, stage)
+	if len(commands) == 0 {
+		return nil
+	}
+
+	s.Logger().Infof("running %v hooks", stage)
+
+	env := ctx.environment(stage)
+
+	for _, command := range commands {
+		argv := []string{"sh", "-c", command}
+
+		if verbose {
+			s.Logger().CmdArray(argv)
+		}
+
+		cmd := system.NewCommand(argv[0], argv[1:]...)
+		for key, value := range env {
+			cmd.SetEnv(key, value)
+		}
+
+		if exitCode, err := s.Run(cmd); err != nil {
+			return fmt.Errorf("%v hook `%v` failed with exit code %v", stage, command, exitCode)
+		}
+	}
+
+	return nil
+}
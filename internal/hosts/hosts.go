@@ -0,0 +1,91 @@
+package hosts
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nix-community/nixos-cli/internal/constants"
+)
+
+// Selects every configuration in a flake.
+const AllHosts = "all"
+
+// Check if a configuration name from a flake ref selects
+// several configurations, rather than naming a single one.
+func IsPattern(name string) bool {
+	return name == AllHosts || strings.ContainsAny(name, "*?[")
+}
+
+// Select the names that match a pattern, which is either AllHosts, a
+// glob pattern as accepted by path.Match, or a single literal name.
+// Names are returned in sorted order.
+func Match(names []string, pattern string) ([]string, error) {
+	matches := []string{}
+
+	for _, name := range names {
+		if pattern == AllHosts {
+			matches = append(matches, name)
+			continue
+		}
+
+		matched, err := path.Match(pattern, name)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			matches = append(matches, name)
+		}
+	}
+
+	slices.Sort(matches)
+
+	return matches, nil
+}
+
+// Location of the results of the last build of each host,
+// used to compare closure sizes between builds.
+const BuildRecordsFile = constants.NixOSCLIStateDirectory + "/host-builds.json"
+
+type BuildRecord struct {
+	Path        string    `json:"path"`
+	ClosureSize uint64    `json:"closure_size"`
+	Time        time.Time `json:"time"`
+}
+
+// Read the last build of each host, keyed by flake ref.
+func ReadBuildRecords() (map[string]BuildRecord, error) {
+	records := map[string]BuildRecord{}
+
+	contents, err := os.ReadFile(BuildRecordsFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return records, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(contents, &records); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+func WriteBuildRecords(records map[string]BuildRecord) error {
+	contents, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(BuildRecordsFile), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(BuildRecordsFile, contents, 0o644)
+}
@@ -0,0 +1,53 @@
+package hosts
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestIsPattern(t *testing.T) {
+	tests := map[string]bool{
+		"all":      true,
+		"web-*":    true,
+		"db-?":     true,
+		"[ab]-box": true,
+		"laptop":   false,
+		"":         false,
+	}
+
+	for name, want := range tests {
+		if got := IsPattern(name); got != want {
+			t.Errorf("IsPattern(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	names := []string{"web-2", "db-1", "web-1", "laptop"}
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"all", []string{"db-1", "laptop", "web-1", "web-2"}},
+		{"web-*", []string{"web-1", "web-2"}},
+		{"*-1", []string{"db-1", "web-1"}},
+		{"laptop", []string{"laptop"}},
+		{"desktop", []string{}},
+	}
+
+	for _, tt := range tests {
+		got, err := Match(names, tt.pattern)
+		if err != nil {
+			t.Errorf("Match(%q) returned error: %v", tt.pattern, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Match(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+
+	if _, err := Match(names, "[web"); err == nil {
+		t.Errorf("Match with malformed pattern should return an error")
+	}
+}
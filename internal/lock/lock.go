@@ -0,0 +1,207 @@
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/nix-community/nixos-cli/internal/constants"
+	"github.com/nix-community/nixos-cli/internal/utils"
+)
+
+// Location of the lock file that guards operations that modify
+// system profiles, relative to the root of the system.
+const OperationLockFile = constants.NixProfileDirectory + "/.nixos-cli.lock"
+
+// Information about the process that holds a lock. This is
+// written to the lock file itself once it has been acquired.
+type Info struct {
+	PID     int       `json:"pid"`
+	User    string    `json:"user"`
+	Command string    `json:"command"`
+	Since   time.Time `json:"since"`
+}
+
+func (i *Info) String() string {
+	return fmt.Sprintf("PID %d (user %v, command `%v`) since %v", i.PID, i.User, i.Command, i.Since.Local().Format(time.DateTime))
+}
+
+// Check if the process that wrote this information is still running.
+func (i *Info) IsProcessAlive() bool {
+	if i.PID <= 0 {
+		return false
+	}
+
+	err := syscall.Kill(i.PID, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// Returned when a lock is already held by another process.
+type HeldError struct {
+	Path string
+	// Information about the current holder, or nil if
+	// it could not be read from the lock file.
+	Holder *Info
+}
+
+func (e *HeldError) Error() string {
+	if e.Holder == nil {
+		return fmt.Sprintf("lock %v is held by another process", e.Path)
+	}
+	return fmt.Sprintf("lock %v is held by %v", e.Path, e.Holder)
+}
+
+// A lock is considered stale when the process that recorded itself
+// as the holder is no longer running, but the lock is still held.
+// This can happen when a child process inherits the lock file.
+func (e *HeldError) IsStale() bool {
+	return e.Holder != nil && !e.Holder.IsProcessAlive()
+}
+
+type Lock struct {
+	Path string
+	// Information left behind by a previous holder that exited
+	// without releasing the lock cleanly (i.e. it crashed or was
+	// killed), if any.
+	Stale *Info
+
+	file *os.File
+}
+
+// Try to acquire the lock at the given path, without blocking.
+// If another process holds the lock, a *HeldError is returned.
+func TryAcquire(path string) (*Lock, error) {
+	return acquire(path, false)
+}
+
+// Acquire the lock at the given path, blocking until it
+// is released if another process already holds it.
+func Acquire(path string) (*Lock, error) {
+	return acquire(path, true)
+}
+
+func acquire(path string, wait bool) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	how := syscall.LOCK_EX
+	if !wait {
+		how |= syscall.LOCK_NB
+	}
+
+	for {
+		err = syscall.Flock(int(file.Fd()), how)
+		if !errors.Is(err, syscall.EINTR) {
+			break
+		}
+	}
+	if err != nil {
+		holder, _ := readInfo(file)
+		_ = file.Close()
+
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, &HeldError{Path: path, Holder: holder}
+		}
+		return nil, err
+	}
+
+	lock := &Lock{Path: path, file: file}
+
+	// The lock file is truncated on release, so any information
+	// still in it came from a process that did not exit cleanly.
+	if previous, err := readInfo(file); err == nil && previous != nil {
+		lock.Stale = previous
+	}
+
+	if err := lock.writeInfo(); err != nil {
+		_ = lock.Release()
+		return nil, err
+	}
+
+	return lock, nil
+}
+
+// Read the information about the current holder
+// of this lock from the lock file, if any exists.
+func ReadHolder(path string) (*Info, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	return readInfo(file)
+}
+
+func readInfo(file *os.File) (*Info, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	contents, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(contents) == 0 {
+		return nil, nil
+	}
+
+	var info Info
+	if err := json.Unmarshal(contents, &info); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+func (l *Lock) writeInfo() error {
+	info := Info{
+		PID:     os.Getpid(),
+		User:    utils.GetInvokingUser(),
+		Command: utils.EscapeAndJoinArgs(os.Args),
+		Since:   time.Now(),
+	}
+
+	contents, err := json.Marshal(&info)
+	if err != nil {
+		return err
+	}
+
+	if err := l.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := l.file.WriteAt(contents, 0); err != nil {
+		return err
+	}
+
+	return l.file.Sync()
+}
+
+// Release the lock. The lock file itself is left in place, since
+// removing it would race with other processes trying to acquire it.
+func (l *Lock) Release() error {
+	if l.file == nil {
+		return nil
+	}
+
+	truncateErr := l.file.Truncate(0)
+	unlockErr := syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	closeErr := l.file.Close()
+	l.file = nil
+
+	return errors.Join(truncateErr, unlockErr, closeErr)
+}
@@ -0,0 +1,73 @@
+package lock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTryAcquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	first, err := TryAcquire(path)
+	if err != nil {
+		t.Fatalf("failed to acquire lock: %v", err)
+	}
+	if first.Stale != nil {
+		t.Errorf("expected no stale info for a new lock, got %v", first.Stale)
+	}
+
+	// flock(2) locks belong to open file descriptions, so a
+	// second acquisition from the same process still conflicts.
+	_, err = TryAcquire(path)
+
+	var heldErr *HeldError
+	if !errors.As(err, &heldErr) {
+		t.Fatalf("expected HeldError, got %v", err)
+	}
+	if heldErr.Holder == nil || heldErr.Holder.PID != os.Getpid() {
+		t.Errorf("expected holder to be PID %d, got %v", os.Getpid(), heldErr.Holder)
+	}
+	if heldErr.IsStale() {
+		t.Errorf("expected lock held by a running process to not be stale")
+	}
+
+	if err := first.Release(); err != nil {
+		t.Fatalf("failed to release lock: %v", err)
+	}
+
+	second, err := TryAcquire(path)
+	if err != nil {
+		t.Fatalf("failed to acquire lock after release: %v", err)
+	}
+	if second.Stale != nil {
+		t.Errorf("expected no stale info after a clean release, got %v", second.Stale)
+	}
+	_ = second.Release()
+}
+
+func TestAcquireWithLeftoverInfo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	leftover := `{"pid":999999999,"user":"alice","command":"nixos apply","since":"2025-01-01T00:00:00Z"}`
+	if err := os.WriteFile(path, []byte(leftover), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := TryAcquire(path)
+	if err != nil {
+		t.Fatalf("failed to acquire lock: %v", err)
+	}
+	defer func() { _ = l.Release() }()
+
+	if l.Stale == nil {
+		t.Fatalf("expected stale info from leftover lock file")
+	}
+	if l.Stale.User != "alice" || l.Stale.PID != 999999999 {
+		t.Errorf("unexpected stale info: %v", l.Stale)
+	}
+	if l.Stale.IsProcessAlive() {
+		t.Errorf("expected stale holder to not be running")
+	}
+}
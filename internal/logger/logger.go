@@ -1,8 +1,13 @@
 package logger
 
 import (
+	"encoding/json"
+	"fmt"
 	"log"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/nix-community/nixos-cli/internal/utils"
@@ -17,6 +22,31 @@ type Logger struct {
 	level        LogLevel
 	stepNumber   uint
 	stepsEnabled bool
+
+	format        OutputFormat
+	events        *json.Encoder
+	eventsMutex   sync.Mutex
+	startTime     time.Time
+	stepStartTime time.Time
+	stepMessage   string
+}
+
+type OutputFormat string
+
+const (
+	OutputFormatText OutputFormat = "text"
+	// Newline-delimited JSON events, meant to be
+	// consumed by other programs rather than humans.
+	OutputFormatJSON OutputFormat = "json"
+)
+
+func ParseOutputFormat(value string) (OutputFormat, error) {
+	switch OutputFormat(value) {
+	case OutputFormatText, OutputFormatJSON:
+		return OutputFormat(value), nil
+	default:
+		return "", fmt.Errorf("unknown output format '%v', must be one of [text, json]", value)
+	}
 }
 
 type LogLevel int
@@ -43,14 +73,27 @@ func NewLogger() *Logger {
 		// as `install` calling `enter`. For those, step numbers can
 		// be confusing.
 		stepsEnabled: os.Getenv("NIXOS_CLI_DISABLE_STEPS") == "",
+		format:       OutputFormatText,
+		// Events are written to stdout, so that they can be parsed
+		// without log output or the output of child processes mixed in.
+		events:    json.NewEncoder(os.Stdout),
+		startTime: time.Now(),
 	}
 }
 
 func (l *Logger) Print(v ...any) {
+	if l.format == OutputFormatJSON {
+		l.message("print", fmt.Sprint(v...))
+		return
+	}
 	l.print.Print(v...)
 }
 
 func (l *Logger) Printf(format string, v ...any) {
+	if l.format == OutputFormatJSON {
+		l.message("print", fmt.Sprintf(format, v...))
+		return
+	}
 	l.print.Printf(format, v...)
 }
 
@@ -58,6 +101,10 @@ func (l *Logger) Info(v ...any) {
 	if l.level > LogLevelInfo {
 		return
 	}
+	if l.format == OutputFormatJSON {
+		l.message("info", fmt.Sprintln(v...))
+		return
+	}
 	l.info.Println(v...)
 }
 
@@ -65,6 +112,10 @@ func (l *Logger) Infof(format string, v ...any) {
 	if l.level > LogLevelInfo {
 		return
 	}
+	if l.format == OutputFormatJSON {
+		l.message("info", fmt.Sprintf(format+"\n", v...))
+		return
+	}
 	l.info.Printf(format+"\n", v...)
 }
 
@@ -72,6 +123,10 @@ func (l *Logger) Warn(v ...any) {
 	if l.level > LogLevelWarn {
 		return
 	}
+	if l.format == OutputFormatJSON {
+		l.message("warn", fmt.Sprintln(v...))
+		return
+	}
 	l.warn.Println(v...)
 }
 
@@ -79,6 +134,10 @@ func (l *Logger) Warnf(format string, v ...any) {
 	if l.level > LogLevelWarn {
 		return
 	}
+	if l.format == OutputFormatJSON {
+		l.message("warn", fmt.Sprintf(format+"\n", v...))
+		return
+	}
 	l.warn.Printf(format+"\n", v...)
 }
 
@@ -86,6 +145,10 @@ func (l *Logger) Error(v ...any) {
 	if l.level > LogLevelError {
 		return
 	}
+	if l.format == OutputFormatJSON {
+		l.message("error", fmt.Sprintln(v...))
+		return
+	}
 	l.error.Println(v...)
 }
 
@@ -93,6 +156,10 @@ func (l *Logger) Errorf(format string, v ...any) {
 	if l.level > LogLevelError {
 		return
 	}
+	if l.format == OutputFormatJSON {
+		l.message("error", fmt.Sprintf(format+"\n", v...))
+		return
+	}
 	l.error.Printf(format+"\n", v...)
 }
 
@@ -101,11 +168,30 @@ func (l *Logger) CmdArray(argv []string) {
 		return
 	}
 
+	if l.format == OutputFormatJSON {
+		l.Event("command", map[string]any{"argv": argv})
+		return
+	}
+
 	msg := color.New(color.FgBlue).Sprintf("$ %v", utils.EscapeAndJoinArgs(argv))
 	l.print.Printf("%v\n", msg)
 }
 
 func (l *Logger) Step(message string) {
+	if l.format == OutputFormatJSON {
+		l.finishStep()
+
+		l.stepNumber++
+		l.stepMessage = message
+		l.stepStartTime = time.Now()
+
+		l.Event("step_start", map[string]any{
+			"step":    l.stepNumber,
+			"message": message,
+		})
+		return
+	}
+
 	// Replace step numbers with generic l.Info() calls if
 	// steps are disabled, to increase clarity in steps.
 	if !l.stepsEnabled {
@@ -125,6 +211,79 @@ func (l *Logger) Step(message string) {
 	l.print.Println(msg)
 }
 
+// Emit a structured event with the given type and fields. This
+// only does something when using the JSON output format, so that
+// commands can unconditionally report machine-readable details
+// (such as build results) without cluttering regular output.
+func (l *Logger) Event(eventType string, fields map[string]any) {
+	if l.format != OutputFormatJSON {
+		return
+	}
+
+	event := make(map[string]any, len(fields)+2)
+	for k, v := range fields {
+		event[k] = v
+	}
+	event["type"] = eventType
+	event["time"] = time.Now().Format(time.RFC3339Nano)
+
+	l.eventsMutex.Lock()
+	defer l.eventsMutex.Unlock()
+
+	_ = l.events.Encode(event)
+}
+
+func (l *Logger) message(level string, message string) {
+	message = strings.TrimSpace(message)
+	if message == "" {
+		return
+	}
+
+	l.Event("message", map[string]any{
+		"level":   level,
+		"message": message,
+	})
+}
+
+func (l *Logger) finishStep() {
+	if l.stepNumber == 0 || l.stepMessage == "" {
+		return
+	}
+
+	l.Event("step_finish", map[string]any{
+		"step":        l.stepNumber,
+		"message":     l.stepMessage,
+		"duration_ms": time.Since(l.stepStartTime).Milliseconds(),
+	})
+
+	l.stepMessage = ""
+}
+
+// Report the final status of a command. This should be called
+// exactly once, right before the program exits.
+func (l *Logger) Finish(err error) {
+	l.finishStep()
+
+	fields := map[string]any{
+		"status":      "success",
+		"duration_ms": time.Since(l.startTime).Milliseconds(),
+	}
+	if err != nil {
+		fields["status"] = "failure"
+		fields["error"] = err.Error()
+	}
+
+	l.Event("result", fields)
+}
+
+func (l *Logger) SetOutputFormat(format OutputFormat) {
+	l.format = format
+}
+
+func (l *Logger) OutputFormat() OutputFormat {
+	return l.format
+}
+
 func (l *Logger) SetLogLevel(level LogLevel) {
 	l.level = level
 }
@@ -0,0 +1,322 @@
+package nixlog
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+)
+
+// Nix's internal JSON log format, enabled with `--log-format internal-json`,
+// writes one JSON object per line to stderr, prefixed with this string.
+const linePrefix = "@nix "
+
+type ActivityType int
+
+// These must be kept in sync with the ActivityType enum in Nix's
+// src/libutil/logging.hh, since they are not documented anywhere.
+const (
+	ActivityUnknown       ActivityType = 0
+	ActivityCopyPath      ActivityType = 100
+	ActivityFileTransfer  ActivityType = 101
+	ActivityRealise       ActivityType = 102
+	ActivityCopyPaths     ActivityType = 103
+	ActivityBuilds        ActivityType = 104
+	ActivityBuild         ActivityType = 105
+	ActivityOptimiseStore ActivityType = 106
+	ActivityVerifyPaths   ActivityType = 107
+	ActivitySubstitute    ActivityType = 108
+	ActivityQueryPathInfo ActivityType = 109
+	ActivityPostBuildHook ActivityType = 110
+	ActivityBuildWaiting  ActivityType = 111
+	ActivityFetchTree     ActivityType = 112
+)
+
+type ResultType int
+
+// Same as above, but for the ResultType enum.
+const (
+	ResultFileLinked       ResultType = 100
+	ResultBuildLogLine     ResultType = 101
+	ResultUntrustedPath    ResultType = 102
+	ResultCorruptedPath    ResultType = 103
+	ResultSetPhase         ResultType = 104
+	ResultProgress         ResultType = 105
+	ResultSetExpected      ResultType = 106
+	ResultPostBuildLogLine ResultType = 107
+	ResultFetchStatus      ResultType = 108
+)
+
+type Verbosity int
+
+const (
+	VerbosityError Verbosity = iota
+	VerbosityWarn
+	VerbosityNotice
+	VerbosityInfo
+	VerbosityTalkative
+	VerbosityChatty
+	VerbosityDebug
+	VerbosityVomit
+)
+
+// A single entry in Nix's internal JSON log format.
+type Action struct {
+	Action string `json:"action"`
+
+	// Fields for "start", "stop", and "result" actions
+	ID     uint64 `json:"id"`
+	Parent uint64 `json:"parent"`
+	Text   string `json:"text"`
+	// For "start" actions, this is an ActivityType. For "result"
+	// actions, this is a ResultType.
+	Type   int   `json:"type"`
+	Fields []any `json:"fields"`
+
+	// Fields for "msg" and "start" actions
+	Level Verbosity `json:"level"`
+	Msg   string    `json:"msg"`
+}
+
+// Parse a single line of Nix's internal JSON log format. If the line
+// does not contain a JSON log entry, then ok is false; these lines
+// should be displayed as-is.
+func ParseLine(line string) (action *Action, ok bool) {
+	data, found := strings.CutPrefix(line, linePrefix)
+	if !found {
+		return nil, false
+	}
+
+	var a Action
+	if err := json.Unmarshal([]byte(data), &a); err != nil {
+		return nil, false
+	}
+
+	return &a, true
+}
+
+func (a *Action) stringField(index int) string {
+	if index >= len(a.Fields) {
+		return ""
+	}
+	s, _ := a.Fields[index].(string)
+	return s
+}
+
+func (a *Action) intField(index int) uint64 {
+	if index >= len(a.Fields) {
+		return 0
+	}
+	// Numbers are decoded as float64 values by encoding/json.
+	n, _ := a.Fields[index].(float64)
+	if n < 0 {
+		return 0
+	}
+	return uint64(n)
+}
+
+type Activity struct {
+	ID     uint64
+	Parent uint64
+	Type   ActivityType
+	Text   string
+
+	// The store path or URI that this activity is working on, if any.
+	Path string
+	// The machine that a build is running on, if not the local one.
+	Machine string
+	// The current phase of a build, such as "buildPhase".
+	Phase string
+	// The last line of output for a build.
+	LastLine string
+
+	Done     uint64
+	Expected uint64
+	Running  uint64
+	Failed   uint64
+
+	// Number of expected child activities by type, as
+	// reported by "set expected" results from Nix.
+	expectedByType map[ActivityType]uint64
+}
+
+// Get a human-readable name for the path this activity is working
+// on, without the store directory, hash, or derivation suffix.
+func (a *Activity) Name() string {
+	if a.Path == "" {
+		return a.Text
+	}
+
+	if !strings.HasPrefix(a.Path, "/") {
+		// This is a URI, such as for file transfers.
+		return a.Path
+	}
+
+	name := filepath.Base(a.Path)
+	if _, rest, found := strings.Cut(name, "-"); found {
+		name = rest
+	}
+
+	return strings.TrimSuffix(name, ".drv")
+}
+
+// Totals for all activities of a certain type.
+type Progress struct {
+	Done     uint64
+	Expected uint64
+	Running  uint64
+	Failed   uint64
+}
+
+type typeTotals struct {
+	// Progress from activities that have already stopped
+	done   uint64
+	failed uint64
+	// Expected count set by parent activities
+	expected uint64
+}
+
+// The state of a Nix invocation, built up from its log entries.
+type State struct {
+	activities map[uint64]*Activity
+	// Order that activities were started in, for stable display
+	order []uint64
+
+	totals map[ActivityType]*typeTotals
+
+	// Error messages reported by Nix
+	Errors []string
+}
+
+func NewState() *State {
+	return &State{
+		activities: make(map[uint64]*Activity),
+		totals:     make(map[ActivityType]*typeTotals),
+	}
+}
+
+func (s *State) typeTotals(t ActivityType) *typeTotals {
+	totals, ok := s.totals[t]
+	if !ok {
+		totals = &typeTotals{}
+		s.totals[t] = totals
+	}
+	return totals
+}
+
+// Update the state with a log entry.
+func (s *State) Apply(a *Action) {
+	switch a.Action {
+	case "start":
+		activity := &Activity{
+			ID:             a.ID,
+			Parent:         a.Parent,
+			Type:           ActivityType(a.Type),
+			Text:           a.Text,
+			expectedByType: make(map[ActivityType]uint64),
+		}
+
+		switch activity.Type {
+		case ActivityBuild:
+			activity.Path = a.stringField(0)
+			activity.Machine = a.stringField(1)
+		case ActivitySubstitute, ActivityCopyPath, ActivityFileTransfer, ActivityQueryPathInfo, ActivityPostBuildHook:
+			activity.Path = a.stringField(0)
+		}
+
+		s.activities[a.ID] = activity
+		s.order = append(s.order, a.ID)
+
+	case "stop":
+		activity, ok := s.activities[a.ID]
+		if !ok {
+			return
+		}
+
+		totals := s.typeTotals(activity.Type)
+		totals.done += activity.Done
+		totals.failed += activity.Failed
+
+		for t, expected := range activity.expectedByType {
+			totals := s.typeTotals(t)
+			totals.expected -= min(expected, totals.expected)
+		}
+
+		delete(s.activities, a.ID)
+		for i, id := range s.order {
+			if id == a.ID {
+				s.order = append(s.order[:i], s.order[i+1:]...)
+				break
+			}
+		}
+
+	case "result":
+		activity, ok := s.activities[a.ID]
+		if !ok {
+			return
+		}
+
+		switch ResultType(a.Type) {
+		case ResultProgress:
+			activity.Done = a.intField(0)
+			activity.Expected = a.intField(1)
+			activity.Running = a.intField(2)
+			activity.Failed = a.intField(3)
+		case ResultSetExpected:
+			t := ActivityType(a.intField(0))
+			expected := a.intField(1)
+
+			totals := s.typeTotals(t)
+			totals.expected -= min(activity.expectedByType[t], totals.expected)
+			totals.expected += expected
+			activity.expectedByType[t] = expected
+		case ResultSetPhase:
+			activity.Phase = a.stringField(0)
+		case ResultBuildLogLine, ResultPostBuildLogLine:
+			activity.LastLine = a.stringField(0)
+		}
+
+	case "msg":
+		if a.Level == VerbosityError {
+			s.Errors = append(s.Errors, a.Msg)
+		}
+	}
+}
+
+// Get the aggregated progress for all activities of a given type.
+func (s *State) Progress(t ActivityType) Progress {
+	totals := s.typeTotals(t)
+
+	p := Progress{
+		Done:   totals.done,
+		Failed: totals.failed,
+	}
+	p.Expected = p.Done
+
+	for _, activity := range s.activities {
+		if activity.Type != t {
+			continue
+		}
+		p.Done += activity.Done
+		p.Expected += activity.Expected
+		p.Running += activity.Running
+		p.Failed += activity.Failed
+	}
+
+	p.Expected = max(p.Expected, totals.expected)
+
+	return p
+}
+
+// Get an activity by its ID, or nil if it is not running.
+func (s *State) Activity(id uint64) *Activity {
+	return s.activities[id]
+}
+
+// Get all running activities, in the order they were started.
+func (s *State) Activities() []*Activity {
+	activities := make([]*Activity, 0, len(s.order))
+	for _, id := range s.order {
+		activities = append(activities, s.activities[id])
+	}
+	return activities
+}
@@ -0,0 +1,84 @@
+package nixlog
+
+import (
+	"testing"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		line     string
+		expectOk bool
+		action   string
+	}{
+		{`@nix {"action":"start","id":1,"level":0,"parent":0,"text":"","type":104,"fields":[]}`, true, "start"},
+		{`@nix {"action":"msg","level":0,"msg":"error: oops"}`, true, "msg"},
+		{`@nix {not json`, false, ""},
+		{`warning: Git tree '/etc/nixos' is dirty`, false, ""},
+	}
+
+	for _, tt := range tests {
+		action, ok := ParseLine(tt.line)
+		if ok != tt.expectOk {
+			t.Errorf("ParseLine(%q) ok = %v, expected %v", tt.line, ok, tt.expectOk)
+			continue
+		}
+		if ok && action.Action != tt.action {
+			t.Errorf("ParseLine(%q) action = %v, expected %v", tt.line, action.Action, tt.action)
+		}
+	}
+}
+
+func TestStateProgress(t *testing.T) {
+	lines := []string{
+		`@nix {"action":"start","id":1,"level":0,"parent":0,"text":"","type":102,"fields":[]}`,
+		`@nix {"action":"result","id":1,"type":106,"fields":[104,3]}`,
+		`@nix {"action":"start","id":2,"level":0,"parent":0,"text":"","type":104,"fields":[]}`,
+		`@nix {"action":"start","id":3,"level":3,"parent":2,"text":"building '/nix/store/aaaa-hello-2.12.drv'","type":105,"fields":["/nix/store/aaaa-hello-2.12.drv","",1,1]}`,
+		`@nix {"action":"result","id":3,"type":104,"fields":["buildPhase"]}`,
+		`@nix {"action":"result","id":2,"type":105,"fields":[1,3,1,0]}`,
+		`@nix {"action":"start","id":4,"level":4,"parent":0,"text":"downloading","type":101,"fields":["https://cache.nixos.org/nar/bbbb.nar.xz"]}`,
+		`@nix {"action":"result","id":4,"type":105,"fields":[512,2048,0,0]}`,
+		`@nix {"action":"msg","level":0,"msg":"error: builder for '/nix/store/cccc-foo.drv' failed"}`,
+	}
+
+	state := NewState()
+	for _, line := range lines {
+		action, ok := ParseLine(line)
+		if !ok {
+			t.Fatalf("failed to parse %q", line)
+		}
+		state.Apply(action)
+	}
+
+	builds := state.Progress(ActivityBuilds)
+	if builds.Done != 1 || builds.Expected != 3 || builds.Running != 1 {
+		t.Errorf("unexpected build progress: %+v", builds)
+	}
+
+	downloads := state.Progress(ActivityFileTransfer)
+	if downloads.Done != 512 || downloads.Expected != 2048 {
+		t.Errorf("unexpected download progress: %+v", downloads)
+	}
+
+	build := state.Activity(3)
+	if build == nil {
+		t.Fatalf("expected build activity to be running")
+	}
+	if build.Name() != "hello-2.12" || build.Phase != "buildPhase" {
+		t.Errorf("unexpected build activity: name = %v, phase = %v", build.Name(), build.Phase)
+	}
+
+	if len(state.Errors) != 1 {
+		t.Errorf("expected 1 error, got %v", len(state.Errors))
+	}
+
+	// Progress from stopped activities must be kept.
+	state.Apply(&Action{Action: "stop", ID: 4})
+	downloads = state.Progress(ActivityFileTransfer)
+	if downloads.Done != 512 {
+		t.Errorf("expected download progress to be kept after stop, got %+v", downloads)
+	}
+	if len(state.Activities()) != 3 {
+		t.Errorf("expected 3 running activities, got %v", len(state.Activities()))
+	}
+}
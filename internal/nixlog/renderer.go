@@ -0,0 +1,406 @@
+package nixlog
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/nix-community/nixos-cli/internal/utils"
+	"golang.org/x/term"
+)
+
+const refreshInterval = 100 * time.Millisecond
+
+type RendererOptions struct {
+	// Print build logs above the progress display, rather
+	// than only showing the last line for each build.
+	PrintBuildLogs bool
+}
+
+// Renderer displays a live tree of running builds and downloads,
+// as well as overall progress, from Nix's internal JSON log format.
+//
+// Nix's stderr should be written to a Renderer, and the terminal it
+// draws on must not be written to by anything else between calls
+// to Start and Stop.
+type Renderer struct {
+	out   *os.File
+	state *State
+	opts  RendererOptions
+
+	mutex      sync.Mutex
+	partial    []byte
+	linesDrawn int
+	dirty      bool
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+func NewRenderer(out *os.File, opts *RendererOptions) *Renderer {
+	r := &Renderer{
+		out:     out,
+		state:   NewState(),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if opts != nil {
+		r.opts = *opts
+	}
+	return r
+}
+
+// Start redrawing the progress display periodically.
+func (r *Renderer) Start() {
+	go func() {
+		defer close(r.stopped)
+
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				r.mutex.Lock()
+				if r.dirty {
+					r.redraw()
+				}
+				r.mutex.Unlock()
+			}
+		}
+	}()
+}
+
+// Stop redrawing, clear the progress display, and print a
+// summary of what was built and downloaded, if anything.
+func (r *Renderer) Stop() {
+	close(r.stop)
+	<-r.stopped
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(r.partial) > 0 {
+		r.handleLine(string(r.partial))
+		r.partial = nil
+	}
+
+	r.clear()
+
+	if summary := r.summary(); summary != "" {
+		fmt.Fprintln(r.out, summary)
+	}
+}
+
+// Get the state of the Nix invocation that is being rendered.
+func (r *Renderer) State() *State {
+	return r.state
+}
+
+func (r *Renderer) Write(p []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.partial = append(r.partial, p...)
+
+	for {
+		i := bytes.IndexByte(r.partial, '\n')
+		if i < 0 {
+			break
+		}
+
+		line := string(r.partial[:i])
+		r.partial = r.partial[i+1:]
+
+		r.handleLine(line)
+	}
+
+	return len(p), nil
+}
+
+func (r *Renderer) handleLine(line string) {
+	action, ok := ParseLine(line)
+	if !ok {
+		r.printAbove(line)
+		return
+	}
+
+	r.state.Apply(action)
+	r.dirty = true
+
+	switch action.Action {
+	case "msg":
+		r.printAbove(action.Msg)
+	case "result":
+		resultType := ResultType(action.Type)
+		if r.opts.PrintBuildLogs && (resultType == ResultBuildLogLine || resultType == ResultPostBuildLogLine) {
+			name := ""
+			if activity := r.state.Activity(action.ID); activity != nil {
+				name = activity.Name()
+			}
+			r.printAbove(fmt.Sprintf("%s> %s", name, action.stringField(0)))
+		}
+	}
+}
+
+// Print a line of output above the progress display.
+func (r *Renderer) printAbove(line string) {
+	r.clear()
+	fmt.Fprintln(r.out, line)
+	r.redraw()
+}
+
+func (r *Renderer) clear() {
+	if r.linesDrawn > 0 {
+		fmt.Fprintf(r.out, "\r\x1b[%dA\x1b[J", r.linesDrawn)
+		r.linesDrawn = 0
+	}
+}
+
+func (r *Renderer) redraw() {
+	width, height, err := term.GetSize(int(r.out.Fd()))
+	if err != nil {
+		width, height = 80, 24
+	}
+
+	lines := r.render(width, max(height/2, 3))
+
+	var buf bytes.Buffer
+	if r.linesDrawn > 0 {
+		fmt.Fprintf(&buf, "\r\x1b[%dA\x1b[J", r.linesDrawn)
+	}
+	for _, line := range lines {
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	_, _ = r.out.Write(buf.Bytes())
+
+	r.linesDrawn = len(lines)
+	r.dirty = false
+}
+
+// Activities that are shown in the tree. Others, such as the overall
+// "realise" activity, are only used to compute progress.
+func isDisplayed(t ActivityType) bool {
+	switch t {
+	case ActivityBuild, ActivitySubstitute, ActivityCopyPath,
+		ActivityFileTransfer, ActivityPostBuildHook, ActivityFetchTree:
+		return true
+	default:
+		return false
+	}
+}
+
+type treeNode struct {
+	activity *Activity
+	children []*treeNode
+}
+
+func (r *Renderer) buildTree() []*treeNode {
+	nodes := map[uint64]*treeNode{}
+	roots := []*treeNode{}
+
+	for _, activity := range r.state.Activities() {
+		if !isDisplayed(activity.Type) {
+			continue
+		}
+
+		node := &treeNode{activity: activity}
+		nodes[activity.ID] = node
+
+		// Attach to the closest displayed ancestor, which has
+		// always been started (and added) before this one.
+		var parent *treeNode
+		for id := activity.Parent; id != 0; {
+			if p, ok := nodes[id]; ok {
+				parent = p
+				break
+			}
+			ancestor := r.state.Activity(id)
+			if ancestor == nil {
+				break
+			}
+			id = ancestor.Parent
+		}
+
+		if parent != nil {
+			parent.children = append(parent.children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+
+	return roots
+}
+
+func (r *Renderer) render(width int, maxLines int) []string {
+	lines := []string{}
+
+	var walk func(nodes []*treeNode, prefix string)
+	walk = func(nodes []*treeNode, prefix string) {
+		for i, node := range nodes {
+			branch, indent := "├─ ", "│  "
+			if i == len(nodes)-1 {
+				branch, indent = "└─ ", "   "
+			}
+			lines = append(lines, r.renderActivity(node.activity, prefix+branch, width))
+			walk(node.children, prefix+indent)
+		}
+	}
+
+	for _, root := range r.buildTree() {
+		lines = append(lines, r.renderActivity(root.activity, "", width))
+		walk(root.children, "")
+	}
+
+	if len(lines) > maxLines-1 {
+		hidden := len(lines) - (maxLines - 2)
+		lines = append(lines[:maxLines-2], color.New(color.Faint).Sprintf("... and %d more", hidden))
+	}
+
+	if status := r.status(); status != "" {
+		lines = append(lines, truncate(status, width))
+	}
+
+	return lines
+}
+
+func (r *Renderer) renderActivity(activity *Activity, prefix string, width int) string {
+	var verb string
+	var details []string
+
+	name := activity.Name()
+
+	switch activity.Type {
+	case ActivityBuild:
+		verb = "building"
+		if activity.Machine != "" {
+			details = append(details, "on "+activity.Machine)
+		}
+		if activity.Phase != "" {
+			details = append(details, "("+activity.Phase+")")
+		}
+	case ActivitySubstitute:
+		verb = "fetching"
+	case ActivityCopyPath:
+		verb = "copying"
+	case ActivityFileTransfer:
+		verb = "downloading"
+		if activity.Expected > 0 {
+			details = append(details, fmt.Sprintf("%s / %s", utils.FormatBytes(activity.Done), utils.FormatBytes(activity.Expected)))
+		}
+	case ActivityPostBuildHook:
+		verb = "running post-build hook for"
+	default:
+		verb = ""
+		name = activity.Text
+	}
+
+	plain := prefix
+	if verb != "" {
+		plain += verb + " "
+	}
+	plain += name
+	if len(details) > 0 {
+		plain += " " + strings.Join(details, " ")
+	}
+
+	lastLine := ""
+	if activity.Type == ActivityBuild && !r.opts.PrintBuildLogs && activity.LastLine != "" {
+		lastLine = " " + strings.TrimSpace(activity.LastLine)
+	}
+
+	plain = truncate(plain+lastLine, width)
+
+	// Colors are applied after truncation, so that
+	// escape sequences are not counted or cut off.
+	result := plain
+	if verb != "" && strings.HasPrefix(plain, prefix+verb) {
+		rest := strings.TrimPrefix(plain, prefix+verb)
+		result = prefix + color.New(color.FgBlue).Sprint(verb) + rest
+	}
+	if lastLine != "" && strings.HasSuffix(plain, lastLine) {
+		result = strings.TrimSuffix(result, lastLine) + color.New(color.Faint).Sprint(lastLine)
+	}
+
+	return result
+}
+
+func (r *Renderer) status() string {
+	parts := []string{}
+
+	builds := r.state.Progress(ActivityBuilds)
+	if builds.Expected > 0 {
+		part := fmt.Sprintf("built %d/%d", builds.Done, builds.Expected)
+		if builds.Running > 0 {
+			part += fmt.Sprintf(" (%d running)", builds.Running)
+		}
+		if builds.Failed > 0 {
+			part += fmt.Sprintf(" (%d failed)", builds.Failed)
+		}
+		parts = append(parts, part)
+	}
+
+	copies := r.state.Progress(ActivityCopyPaths)
+	if copies.Expected > 0 {
+		parts = append(parts, fmt.Sprintf("copied %d/%d", copies.Done, copies.Expected))
+	}
+
+	downloads := r.state.Progress(ActivityFileTransfer)
+	if downloads.Expected > 0 {
+		parts = append(parts, fmt.Sprintf("downloaded %s/%s", utils.FormatBytes(downloads.Done), utils.FormatBytes(downloads.Expected)))
+	}
+
+	if len(parts) == 0 {
+		return ""
+	}
+
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+func (r *Renderer) summary() string {
+	parts := []string{}
+
+	builds := r.state.Progress(ActivityBuilds)
+	if builds.Done > 0 {
+		parts = append(parts, fmt.Sprintf("built %d derivation(s)", builds.Done))
+	}
+	if builds.Failed > 0 {
+		parts = append(parts, fmt.Sprintf("%d build(s) failed", builds.Failed))
+	}
+
+	copies := r.state.Progress(ActivityCopyPaths)
+	if copies.Done > 0 {
+		parts = append(parts, fmt.Sprintf("copied %d path(s)", copies.Done))
+	}
+
+	downloads := r.state.Progress(ActivityFileTransfer)
+	if downloads.Done > 0 {
+		parts = append(parts, fmt.Sprintf("downloaded %s", utils.FormatBytes(downloads.Done)))
+	}
+
+	if len(parts) == 0 {
+		return ""
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+func truncate(s string, width int) string {
+	if width <= 1 {
+		return s
+	}
+
+	runes := []rune(s)
+	if len(runes) < width {
+		return s
+	}
+
+	return string(runes[:width-2]) + "…"
+}
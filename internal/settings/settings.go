@@ -10,15 +10,22 @@ import (
 	"github.com/knadh/koanf/parsers/toml/v2"
 	"github.com/knadh/koanf/providers/file"
 	"github.com/knadh/koanf/v2"
+
+	"github.com/nix-community/nixos-cli/internal/closure"
+	timeUtils "github.com/nix-community/nixos-cli/internal/time"
 )
 
 type Settings struct {
 	Aliases        map[string][]string `koanf:"aliases" noset:"true"`
 	Apply          ApplySettings       `koanf:"apply"`
 	AutoRollback   bool                `koanf:"auto_rollback"`
+	AutoUpgrade    AutoUpgradeSettings `koanf:"auto_upgrade"`
 	UseColor       bool                `koanf:"color"`
+	ConfigAttr     string              `koanf:"config_attr"`
 	ConfigLocation string              `koanf:"config_location"`
 	Enter          EnterSettings       `koanf:"enter"`
+	HealthChecks   HealthCheckSettings `koanf:"health_checks"`
+	Hooks          HookSettings        `koanf:"hooks"`
 	Init           InitSettings        `koanf:"init"`
 	NoConfirm      bool                `koanf:"no_confirm"`
 	Option         OptionSettings      `koanf:"option"`
@@ -29,15 +36,60 @@ type Settings struct {
 type ApplySettings struct {
 	ImplyImpureWithTag    bool   `koanf:"imply_impure_with_tag"`
 	DefaultSpecialisation string `koanf:"specialisation"`
+	ShowProgress          bool   `koanf:"show_progress"`
 	UseNom                bool   `koanf:"use_nom"`
 	UseGitCommitMsg       bool   `koanf:"use_git_commit_msg"`
 	IgnoreDirtyTree       bool   `koanf:"ignore_dirty_tree"`
+	GitPolicy             string `koanf:"git_policy"`
+	PushTo                string `koanf:"push_to"`
+	PushSigningKey        string `koanf:"push_signing_key"`
+	SizeGrowthWarning     string `koanf:"size_growth_warning"`
+}
+
+// Values for `apply.git_policy`, from least to most strict.
+const (
+	GitPolicyAllow  = "allow"
+	GitPolicyClean  = "clean"
+	GitPolicyPushed = "pushed"
+)
+
+type AutoUpgradeSettings struct {
+	Operation       string   `koanf:"operation"`
+	Update          bool     `koanf:"update"`
+	UpdateInputs    []string `koanf:"update_inputs" noset:"true"`
+	AllowReboot     bool     `koanf:"allow_reboot"`
+	RebootWindows   []string `koanf:"reboot_windows" noset:"true"`
+	RandomizedDelay string   `koanf:"randomized_delay"`
 }
 
 type EnterSettings struct {
 	MountResolvConf bool `koanf:"mount_resolv_conf"`
 }
 
+type HealthCheckSettings struct {
+	NoFailedUnits bool     `koanf:"no_failed_units"`
+	Units         []string `koanf:"units" noset:"true"`
+	Commands      []string `koanf:"commands" noset:"true"`
+	TCPPorts      []int64  `koanf:"tcp_ports" noset:"true"`
+	Timeout       string   `koanf:"timeout"`
+}
+
+const defaultHealthCheckTimeout = "10s"
+
+func (h *HealthCheckSettings) IsConfigured() bool {
+	return h.NoFailedUnits || len(h.Units) > 0 || len(h.Commands) > 0 || len(h.TCPPorts) > 0
+}
+
+type HookSettings struct {
+	PreBuild     []string `koanf:"pre_build" noset:"true"`
+	PostBuild    []string `koanf:"post_build" noset:"true"`
+	PreActivate  []string `koanf:"pre_activate" noset:"true"`
+	PostActivate []string `koanf:"post_activate" noset:"true"`
+	PreDelete    []string `koanf:"pre_delete" noset:"true"`
+	PostDelete   []string `koanf:"post_delete" noset:"true"`
+	OnFailure    []string `koanf:"on_failure" noset:"true"`
+}
+
 type InitSettings struct {
 	EnableXserver bool              `koanf:"xserver_enabled"`
 	DesktopConfig string            `koanf:"desktop_config"`
@@ -80,6 +132,12 @@ var SettingsDocs = map[string]DescriptionEntry{
 		Short: "Name of specialisation to use by default when activating",
 		Long:  "Specifies which systemd specialisation to use when activating a configuration with 'apply'.",
 	},
+	"apply.show_progress": {
+		Short: "Show a live view of build progress",
+		Long: "Shows running builds and downloads, as well as overall progress, when building a configuration with " +
+			"'apply' or 'install'. This is only shown when output is a terminal, and is replaced by nix-output-monitor " +
+			"when 'apply.use_nom' is enabled.",
+	},
 	"apply.use_nom": {
 		Short: "Use 'nix-output-monitor' as an alternative 'nix build' frontend",
 		Long:  "Enables nix-output-monitor to show more user-friendly build progress output for the 'apply' command.",
@@ -92,15 +150,74 @@ var SettingsDocs = map[string]DescriptionEntry{
 		Short: "Ignore dirty working tree when using Git commit message for --tag",
 		Long:  "Allows 'apply' to use Git commit messages even when the working directory is dirty.",
 	},
+	"apply.git_policy": {
+		Short: "Refuse to apply configurations with uncommitted or unpushed changes",
+		Long: "Controls which states of a Git-backed configuration directory 'apply' accepts when activating. " +
+			"'allow' accepts any state, 'clean' refuses to apply from a tree with uncommitted changes, and " +
+			"'pushed' additionally refuses to apply commits that are not in the upstream branch.",
+	},
+	"apply.push_to": {
+		Short: "Binary cache to copy built configurations to",
+		Long: "Copies the closure of each built configuration to this Nix store URI (such as 's3://my-cache' or " +
+			"'file:///var/cache/nix') after a successful build, so that other machines can substitute it.",
+	},
+	"apply.push_signing_key": {
+		Short: "Secret key file to sign closures with before pushing",
+		Long: "Signs the closure with the secret key in this file before copying it to 'apply.push_to', as " +
+			"generated by 'nix key generate-secret'. Closures are not signed if this is empty.",
+	},
+	"apply.size_growth_warning": {
+		Short: "Warn when the closure grows by more than this amount",
+		Long: "Shows a warning before activating a configuration whose closure is larger than the current " +
+			"system's by more than this size (such as '500MiB') or percentage (such as '10%'). No warning is " +
+			"shown if this is empty.",
+	},
 	"auto_rollback": {
 		Short: "Automatically rollback profile on activation failure",
 		Long: "Enables automatic rollback of a NixOS system profile when an activation command fails. This can be " +
 			"disabled when a reboot or some other circumstance is needed for successful activation",
 	},
+	"auto_upgrade": {
+		Short: "Settings for `auto-upgrade` command",
+	},
+	"auto_upgrade.operation": {
+		Short: "How to activate upgraded configurations",
+		Long: "Either 'switch' to activate the upgraded configuration immediately and make it the boot default, " +
+			"or 'boot' to only make it the boot default, so that it is activated on the next reboot.",
+	},
+	"auto_upgrade.update": {
+		Short: "Update flake inputs or channels before building",
+		Long: "Updates all inputs of the configuration flake before building, or upgrades the 'nixos' channel " +
+			"and any channels marked with '.update-on-nixos-rebuild' for legacy configurations.",
+	},
+	"auto_upgrade.update_inputs": {
+		Short: "Specific flake inputs to update before building",
+		Long:  "Only updates these inputs of the configuration flake, rather than all of them. Implies 'auto_upgrade.update'.",
+	},
+	"auto_upgrade.allow_reboot": {
+		Short: "Reboot after upgrading, if needed",
+		Long: "Reboots the system after upgrading if the new configuration requires it, such as when the kernel " +
+			"has changed. This only happens within 'auto_upgrade.reboot_windows', if any are set.",
+	},
+	"auto_upgrade.reboot_windows": {
+		Short: "Times of day during which rebooting is allowed",
+		Long: "A list of local time windows in the form 'HH:MM-HH:MM', such as '01:00-05:00'. Windows may wrap " +
+			"around midnight. If empty, rebooting is allowed at any time.",
+	},
+	"auto_upgrade.randomized_delay": {
+		Short: "Maximum random delay before upgrading",
+		Long: "Waits for a random amount of time up to this systemd.time(7) span (such as '30min') before " +
+			"upgrading, to avoid many machines upgrading at the same time.",
+	},
 	"color": {
 		Short: "Enable colored output",
 		Long:  "Turns on ANSI color sequences for decorated output in supported terminals.",
 	},
+	"config_attr": {
+		Short: "Attribute of the system in a Nix file at `config_location`",
+		Long: "When set, the configuration is built from this attribute path inside the Nix file at " +
+			"`config_location` instead of from a flake or from `<nixos-config>`, like `nix-build FILE -A ATTR`.",
+	},
 	"config_location": {
 		Short: "Where to look for configuration by default",
 		Long:  "Path to a Nix file or directory to look for user configuration in by default.",
@@ -112,6 +229,66 @@ var SettingsDocs = map[string]DescriptionEntry{
 		Short: "Bind-mount host 'resolv.conf' inside chroot for internet accesss",
 		Long:  "Ensures internet access by mounting the host's /etc/resolv.conf into the chroot environment.",
 	},
+	"health_checks": {
+		Short: "Checks to run after activating a configuration",
+		Long: "Checks that are run after 'apply' activates a configuration. If any of them fail, the activation is " +
+			"treated as failed, and the previous generation is restored if 'auto_rollback' is enabled.",
+	},
+	"health_checks.no_failed_units": {
+		Short: "Fail if any systemd units are in a failed state",
+		Long:  "Treats the activation as failed if any systemd units are in a failed state after activation.",
+	},
+	"health_checks.units": {
+		Short: "Systemd units that must be active",
+		Long:  "List of systemd unit names that must be active after activation.",
+	},
+	"health_checks.commands": {
+		Short: "Commands that must exit successfully",
+		Long:  "List of shell commands that must exit with a zero status after activation. These are run with 'sh -c'.",
+	},
+	"health_checks.tcp_ports": {
+		Short: "TCP ports that must be listening",
+		Long:  "List of TCP ports that must have a listening socket after activation.",
+	},
+	"health_checks.timeout": {
+		Short: "How long to retry failing health checks",
+		Long: "Failing health checks are retried until this systemd.time(7) span (such as '30s') has passed, since " +
+			"services can take some time to start. Checks are not retried if this is empty.",
+	},
+	"hooks": {
+		Short: "Commands to run around system changes",
+		Long: "Shell commands that are run before and after 'apply', 'generation switch', 'generation rollback', " +
+			"and 'generation delete' change the system. Information about the operation is passed through " +
+			"environment variables; see nixos-cli-hooks(5) for details.",
+	},
+	"hooks.pre_build": {
+		Short: "Commands to run before building a configuration",
+		Long:  "Commands to run before 'apply' builds a configuration. A non-zero exit status aborts the operation.",
+	},
+	"hooks.post_build": {
+		Short: "Commands to run after building a configuration",
+		Long:  "Commands to run after 'apply' builds a configuration, before activation. A non-zero exit status aborts the operation.",
+	},
+	"hooks.pre_activate": {
+		Short: "Commands to run before changing the system",
+		Long:  "Commands to run before a generation is activated. A non-zero exit status aborts the operation.",
+	},
+	"hooks.post_activate": {
+		Short: "Commands to run after changing the system",
+		Long:  "Commands to run after a generation has been activated successfully.",
+	},
+	"hooks.pre_delete": {
+		Short: "Commands to run before deleting generations",
+		Long:  "Commands to run before 'generation delete' deletes generations. A non-zero exit status aborts the operation.",
+	},
+	"hooks.post_delete": {
+		Short: "Commands to run after deleting generations",
+		Long:  "Commands to run after 'generation delete' has deleted generations successfully.",
+	},
+	"hooks.on_failure": {
+		Short: "Commands to run when an operation fails",
+		Long:  "Commands to run when an operation fails, including when it was aborted by another hook.",
+	},
 	"init": {
 		Short: "Settings for `init` command",
 	},
@@ -147,19 +324,30 @@ var SettingsDocs = map[string]DescriptionEntry{
 		Long:  "Specifies which command to use for privilege escalation (e.g., sudo or doas).",
 	},
 	"use_nvd": {
-		Short: "Use 'nvd' instead of `nix store diff-closures`",
-		Long:  "Use the better-looking `nvd` diffing tool when comparing configurations instead of `nix store diff-closures`.",
+		Short: "Use 'nvd' instead of the built-in closure diff",
+		Long:  "Use the `nvd` diffing tool when comparing configurations instead of the built-in closure diff.",
 	},
 }
 
 func NewSettings() *Settings {
 	return &Settings{
-		AutoRollback:   true,
+		Apply: ApplySettings{
+			ShowProgress:      true,
+			GitPolicy:         GitPolicyAllow,
+			SizeGrowthWarning: "10%",
+		},
+		AutoRollback: true,
+		AutoUpgrade: AutoUpgradeSettings{
+			Operation: "switch",
+		},
 		UseColor:       true,
 		ConfigLocation: "/etc/nixos",
 		Enter: EnterSettings{
 			MountResolvConf: true,
 		},
+		HealthChecks: HealthCheckSettings{
+			Timeout: defaultHealthCheckTimeout,
+		},
 		Init:        InitSettings{},
 		RootCommand: "sudo",
 		Option: OptionSettings{
@@ -171,9 +359,29 @@ func NewSettings() *Settings {
 }
 
 func ParseSettings(location string) (*Settings, error) {
+	return parseSettings(file.Provider(location))
+}
+
+// Parse settings from the raw contents of a settings file, such
+// as one that was read from a remote machine.
+func ParseSettingsFromBytes(contents []byte) (*Settings, error) {
+	return parseSettings(rawBytesProvider(contents))
+}
+
+type rawBytesProvider []byte
+
+func (r rawBytesProvider) ReadBytes() ([]byte, error) {
+	return r, nil
+}
+
+func (r rawBytesProvider) Read() (map[string]any, error) {
+	return nil, fmt.Errorf("raw bytes provider does not support Read()")
+}
+
+func parseSettings(provider koanf.Provider) (*Settings, error) {
 	k := koanf.New(".")
 
-	if err := k.Load(file.Provider(location), toml.Parser()); err != nil {
+	if err := k.Load(provider, toml.Parser()); err != nil {
 		return nil, err
 	}
 
@@ -215,6 +423,65 @@ func (cfg *Settings) Validate() SettingsErrors {
 		}
 	}
 
+	validPorts := []int64{}
+	for _, port := range cfg.HealthChecks.TCPPorts {
+		if port < 1 || port > 65535 {
+			errs = append(errs, SettingsError{Field: "health_checks.tcp_ports", Message: fmt.Sprintf("invalid port %d", port)})
+			continue
+		}
+		validPorts = append(validPorts, port)
+	}
+	cfg.HealthChecks.TCPPorts = validPorts
+
+	switch cfg.Apply.GitPolicy {
+	case "", GitPolicyAllow, GitPolicyClean, GitPolicyPushed:
+	default:
+		errs = append(errs, SettingsError{
+			Field:   "apply.git_policy",
+			Message: fmt.Sprintf("invalid policy '%s', must be one of 'allow', 'clean', or 'pushed'", cfg.Apply.GitPolicy),
+		})
+		cfg.Apply.GitPolicy = GitPolicyAllow
+	}
+
+	if cfg.Apply.SizeGrowthWarning != "" {
+		if _, err := closure.ParseGrowthThreshold(cfg.Apply.SizeGrowthWarning); err != nil {
+			errs = append(errs, SettingsError{Field: "apply.size_growth_warning", Message: err.Error()})
+			cfg.Apply.SizeGrowthWarning = ""
+		}
+	}
+
+	switch cfg.AutoUpgrade.Operation {
+	case "", "switch", "boot":
+	default:
+		errs = append(errs, SettingsError{
+			Field:   "auto_upgrade.operation",
+			Message: fmt.Sprintf("invalid operation '%s', must be either 'switch' or 'boot'", cfg.AutoUpgrade.Operation),
+		})
+		cfg.AutoUpgrade.Operation = "switch"
+	}
+
+	// Invalid windows are kept rather than removed, since removing all of
+	// them would allow rebooting at any time. Rebooting is refused instead.
+	for _, window := range cfg.AutoUpgrade.RebootWindows {
+		if _, err := timeUtils.ParseDailyWindow(window); err != nil {
+			errs = append(errs, SettingsError{Field: "auto_upgrade.reboot_windows", Message: err.Error()})
+		}
+	}
+
+	if cfg.AutoUpgrade.RandomizedDelay != "" {
+		if _, err := timeUtils.DurationFromTimeSpan(cfg.AutoUpgrade.RandomizedDelay); err != nil {
+			errs = append(errs, SettingsError{Field: "auto_upgrade.randomized_delay", Message: err.Error()})
+			cfg.AutoUpgrade.RandomizedDelay = ""
+		}
+	}
+
+	if cfg.HealthChecks.Timeout != "" {
+		if _, err := timeUtils.DurationFromTimeSpan(cfg.HealthChecks.Timeout); err != nil {
+			errs = append(errs, SettingsError{Field: "health_checks.timeout", Message: err.Error()})
+			cfg.HealthChecks.Timeout = defaultHealthCheckTimeout
+		}
+	}
+
 	if len(errs) > 0 {
 		return errs
 	}
@@ -46,6 +46,57 @@ func TestValidateConfig(t *testing.T) {
 			t.Errorf("expected error slice to be nil, got %d errors", len(errs))
 		}
 	})
+
+	t.Run("invalid git policy is reset", func(t *testing.T) {
+		cfg := &settings.Settings{
+			Apply: settings.ApplySettings{
+				GitPolicy: "strict",
+			},
+		}
+
+		errs := cfg.Validate()
+		if len(errs) != 1 {
+			t.Errorf("expected 1 error, got %d", len(errs))
+		}
+
+		if cfg.Apply.GitPolicy != settings.GitPolicyAllow {
+			t.Errorf("expected git policy to be reset to %v, got %v", settings.GitPolicyAllow, cfg.Apply.GitPolicy)
+		}
+	})
+
+	t.Run("invalid health check ports are removed", func(t *testing.T) {
+		cfg := &settings.Settings{
+			HealthChecks: settings.HealthCheckSettings{
+				TCPPorts: []int64{0, 22, 65536, 443},
+			},
+		}
+
+		errs := cfg.Validate()
+		if len(errs) != 2 {
+			t.Errorf("expected 2 errors, got %d", len(errs))
+		}
+
+		if len(cfg.HealthChecks.TCPPorts) != 2 {
+			t.Errorf("expected two valid ports to remain, got %v", cfg.HealthChecks.TCPPorts)
+		}
+	})
+
+	t.Run("invalid health check timeout is reset", func(t *testing.T) {
+		cfg := &settings.Settings{
+			HealthChecks: settings.HealthCheckSettings{
+				Timeout: "10 parsecs",
+			},
+		}
+
+		errs := cfg.Validate()
+		if len(errs) != 1 {
+			t.Errorf("expected 1 error, got %d", len(errs))
+		}
+
+		if cfg.HealthChecks.Timeout != "10s" {
+			t.Errorf("expected health check timeout to be reset to 10s, got %v", cfg.HealthChecks.Timeout)
+		}
+	})
 }
 
 func TestSetConfigValue(t *testing.T) {
@@ -0,0 +1,141 @@
+package system
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// These helpers perform simple filesystem operations on a system,
+// regardless of whether or not it is the local machine. Local
+// systems avoid spawning any processes.
+
+func Readlink(s System, path string) (string, error) {
+	if !s.IsRemote() {
+		return os.Readlink(path)
+	}
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+
+	cmd := NewCommand("readlink", path)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if _, err := s.Run(cmd); err != nil {
+		return "", fmt.Errorf("failed to read link %v: %v", path, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func MkdirAll(s System, path string, perm os.FileMode) error {
+	if !s.IsRemote() {
+		return os.MkdirAll(path, perm)
+	}
+
+	cmd := NewCommand("mkdir", "-p", "-m", fmt.Sprintf("%o", perm), path)
+	_, err := s.Run(cmd)
+	return err
+}
+
+func HasCommand(s System, name string) bool {
+	if !s.IsRemote() {
+		path, _ := exec.LookPath(name)
+		return path != ""
+	}
+
+	cmd := NewCommand("sh", "-c", fmt.Sprintf("command -v %s", shellQuote(name)))
+	cmd.Stdout = nil
+	cmd.Stderr = nil
+
+	_, err := s.Run(cmd)
+	return err == nil
+}
+
+func ReadFile(s System, path string) ([]byte, error) {
+	if !s.IsRemote() {
+		return os.ReadFile(path)
+	}
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+
+	cmd := NewCommand("cat", path)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if _, err := s.Run(cmd); err != nil {
+		return nil, fmt.Errorf("failed to read %v: %v", path, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+func PathExists(s System, path string) bool {
+	if !s.IsRemote() {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+
+	cmd := NewCommand("test", "-e", path)
+	cmd.Stdout = nil
+	cmd.Stderr = nil
+
+	_, err := s.Run(cmd)
+	return err == nil
+}
+
+func WriteFile(s System, path string, contents []byte, perm os.FileMode) error {
+	if !s.IsRemote() {
+		return os.WriteFile(path, contents, perm)
+	}
+
+	var stderr bytes.Buffer
+
+	script := fmt.Sprintf("umask %03o && cat > %s", 0o777&^perm, shellQuote(path))
+
+	cmd := NewCommand("sh", "-c", script)
+	cmd.Stdin = bytes.NewReader(contents)
+	cmd.Stdout = nil
+	cmd.Stderr = &stderr
+
+	if _, err := s.Run(cmd); err != nil {
+		return fmt.Errorf("failed to write %v: %v", path, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+// Append contents to a file, creating it if it does not exist.
+func AppendFile(s System, path string, contents []byte, perm os.FileMode) error {
+	if !s.IsRemote() {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, perm)
+		if err != nil {
+			return err
+		}
+
+		_, err = file.Write(contents)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	}
+
+	var stderr bytes.Buffer
+
+	script := fmt.Sprintf("umask %03o && cat >> %s", 0o777&^perm, shellQuote(path))
+
+	cmd := NewCommand("sh", "-c", script)
+	cmd.Stdin = bytes.NewReader(contents)
+	cmd.Stdout = nil
+	cmd.Stderr = &stderr
+
+	if _, err := s.Run(cmd); err != nil {
+		return fmt.Errorf("failed to append to %v: %v", path, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
@@ -20,7 +20,7 @@ func NewLocalSystem(logger *logger.Logger) *LocalSystem {
 func (l *LocalSystem) Run(cmd *Command) (int, error) {
 	command := exec.Command(cmd.Name, cmd.Args...)
 
-	command.Stdout = cmd.Stdout
+	command.Stdout = commandStdout(l.logger, cmd.Stdout)
 	command.Stderr = cmd.Stderr
 	command.Stdin = cmd.Stdin
 	command.Env = os.Environ()
@@ -49,6 +49,10 @@ func (l *LocalSystem) IsNixOS() bool {
 	return err == nil
 }
 
+func (l *LocalSystem) IsRemote() bool {
+	return false
+}
+
 func (l *LocalSystem) Logger() *logger.Logger {
 	return l.logger
 }
@@ -35,3 +35,13 @@ func NewCommand(name string, args ...string) *Command {
 func (c *Command) SetEnv(key string, value string) {
 	c.Env[key] = value
 }
+
+// Events are written to stdout when using JSON output, so output
+// of child processes that would go there is written to stderr
+// instead, so that it does not get mixed in with them.
+func commandStdout(log *logger.Logger, stdout io.Writer) io.Writer {
+	if stdout == io.Writer(os.Stdout) && log.OutputFormat() == logger.OutputFormatJSON {
+		return os.Stderr
+	}
+	return stdout
+}
@@ -0,0 +1,235 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nix-community/nixos-cli/internal/logger"
+)
+
+// SSHSystem runs commands on a remote machine using the system's
+// `ssh` executable. This means that the user's SSH configuration,
+// agent, and known hosts are all respected without any extra work.
+type SSHSystem struct {
+	host        string
+	rootCommand string
+	sshOpts     []string
+	controlDir  string
+
+	logger *logger.Logger
+}
+
+type SSHSystemOptions struct {
+	// Command used to escalate privileges on the remote host, such as
+	// `sudo` or `doas`. This must not require a password, since commands
+	// are not run with a TTY. If empty, commands are run as the login user.
+	RootCommand string
+}
+
+func NewSSHSystem(log *logger.Logger, host string, opts *SSHSystemOptions) (*SSHSystem, error) {
+	if host == "" {
+		return nil, fmt.Errorf("remote host cannot be empty")
+	}
+
+	controlDir, err := os.MkdirTemp("", "nixos-ssh-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ssh control directory: %w", err)
+	}
+
+	// Respect the same variable that Nix uses for its own SSH
+	// invocations, so that both share the same options.
+	sshOpts := strings.Fields(os.Getenv("NIX_SSHOPTS"))
+
+	// Re-use a single connection for all commands run on this host,
+	// since there are usually quite a few of them.
+	sshOpts = append(sshOpts,
+		"-o", "ControlMaster=auto",
+		"-o", "ControlPath="+filepath.Join(controlDir, "ssh-%n"),
+		"-o", "ControlPersist=60",
+	)
+
+	return &SSHSystem{
+		host:        host,
+		rootCommand: opts.RootCommand,
+		sshOpts:     sshOpts,
+		controlDir:  controlDir,
+		logger:      log,
+	}, nil
+}
+
+func (s *SSHSystem) Host() string {
+	return s.host
+}
+
+// Options that should be passed to `ssh` to connect to this host;
+// this includes the control socket for the shared connection.
+func (s *SSHSystem) SSHOpts() []string {
+	return s.sshOpts
+}
+
+func (s *SSHSystem) Run(cmd *Command) (int, error) {
+	argv := []string{"ssh"}
+	argv = append(argv, s.sshOpts...)
+	argv = append(argv, s.host, "--", s.remoteCommandString(cmd))
+
+	command := exec.Command(argv[0], argv[1:]...)
+
+	command.Stdout = commandStdout(s.logger, cmd.Stdout)
+	command.Stderr = cmd.Stderr
+	command.Stdin = cmd.Stdin
+
+	err := command.Run()
+
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(interface{ ExitStatus() int }); ok {
+			return status.ExitStatus(), err
+		}
+	}
+
+	if err == nil {
+		return 0, nil
+	}
+
+	return 0, err
+}
+
+// Build the string that will be interpreted by the remote user's shell.
+// `ssh` joins all arguments with spaces, so everything needs to be
+// quoted beforehand to avoid any word splitting or expansion.
+func (s *SSHSystem) remoteCommandString(cmd *Command) string {
+	argv := []string{}
+
+	if s.rootCommand != "" {
+		argv = append(argv, s.rootCommand)
+	}
+
+	if len(cmd.Env) > 0 {
+		keys := make([]string, 0, len(cmd.Env))
+		for k := range cmd.Env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		argv = append(argv, "env")
+		for _, k := range keys {
+			argv = append(argv, k+"="+cmd.Env[k])
+		}
+	}
+
+	argv = append(argv, cmd.Name)
+	argv = append(argv, cmd.Args...)
+
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		quoted[i] = shellQuote(arg)
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+func (s *SSHSystem) IsNixOS() bool {
+	cmd := NewCommand("test", "-f", "/etc/NIXOS")
+	cmd.Stdout = nil
+	cmd.Stderr = nil
+
+	_, err := s.Run(cmd)
+	return err == nil
+}
+
+func (s *SSHSystem) IsRemote() bool {
+	return true
+}
+
+func (s *SSHSystem) Logger() *logger.Logger {
+	return s.logger
+}
+
+// Tear down the shared SSH connection, if one was opened.
+func (s *SSHSystem) Close() {
+	argv := []string{"ssh"}
+	argv = append(argv, s.sshOpts...)
+	argv = append(argv, "-O", "exit", s.host)
+
+	// This fails if no master connection was ever started,
+	// which is fine.
+	_ = exec.Command(argv[0], argv[1:]...).Run()
+
+	_ = os.RemoveAll(s.controlDir)
+}
+
+// Copy the closure of a store path from the local Nix store
+// to this host's Nix store.
+func (s *SSHSystem) CopyClosureTo(local CommandRunner, path string, verbose bool) error {
+	argv := []string{"nix-copy-closure", "--to", s.host, path}
+
+	if verbose {
+		local.Logger().CmdArray(argv)
+	}
+
+	cmd := NewCommand(argv[0], argv[1:]...)
+	cmd.SetEnv("NIX_SSHOPTS", strings.Join(s.sshOpts, " "))
+
+	_, err := local.Run(cmd)
+	return err
+}
+
+// Copy the closure of a store path from this host's Nix
+// store to the local Nix store.
+func (s *SSHSystem) CopyClosureFrom(local CommandRunner, path string, verbose bool) error {
+	argv := []string{"nix-copy-closure", "--from", s.host, path}
+
+	if verbose {
+		local.Logger().CmdArray(argv)
+	}
+
+	cmd := NewCommand(argv[0], argv[1:]...)
+	cmd.SetEnv("NIX_SSHOPTS", strings.Join(s.sshOpts, " "))
+
+	_, err := local.Run(cmd)
+	return err
+}
+
+// Copy the closure of a store path directly between two remote hosts,
+// relaying the data through the local machine. Nothing is added to
+// the local Nix store.
+func CopyClosureBetween(local CommandRunner, from *SSHSystem, to *SSHSystem, path string, verbose bool) error {
+	argv := []string{
+		"nix", "--extra-experimental-features", "nix-command",
+		"copy", "--from", "ssh://" + from.host, "--to", "ssh://" + to.host, path,
+	}
+
+	if verbose {
+		local.Logger().CmdArray(argv)
+	}
+
+	cmd := NewCommand(argv[0], argv[1:]...)
+	// Both hosts have their own control sockets, so only
+	// the user's options can be shared between them.
+	cmd.SetEnv("NIX_SSHOPTS", os.Getenv("NIX_SSHOPTS"))
+
+	_, err := local.Run(cmd)
+	return err
+}
+
+func shellQuote(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+
+	isSafe := true
+	for _, c := range arg {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("@%+=:,./-_", c)) {
+			isSafe = false
+			break
+		}
+	}
+	if isSafe {
+		return arg
+	}
+
+	return "'" + strings.ReplaceAll(arg, "'", `'"'"'`) + "'"
+}
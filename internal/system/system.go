@@ -1,5 +1,7 @@
 package system
 
 type System interface {
+	CommandRunner
 	IsNixOS() bool
+	IsRemote() bool
 }
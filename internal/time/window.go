@@ -0,0 +1,63 @@
+package time
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A window of time within a day, such as "01:00-05:00". Windows
+// whose end is before their start wrap around midnight.
+type DailyWindow struct {
+	// Minutes since midnight
+	Start int
+	End   int
+}
+
+func parseClockTime(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time '%v', expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Parse a daily window in the form "HH:MM-HH:MM".
+func ParseDailyWindow(s string) (DailyWindow, error) {
+	startStr, endStr, found := strings.Cut(s, "-")
+	if !found {
+		return DailyWindow{}, fmt.Errorf("invalid window '%v', expected HH:MM-HH:MM", s)
+	}
+
+	start, err := parseClockTime(startStr)
+	if err != nil {
+		return DailyWindow{}, err
+	}
+
+	end, err := parseClockTime(endStr)
+	if err != nil {
+		return DailyWindow{}, err
+	}
+
+	if start == end {
+		return DailyWindow{}, fmt.Errorf("window '%v' is empty", s)
+	}
+
+	return DailyWindow{Start: start, End: end}, nil
+}
+
+// Check if the given time falls within this window, using
+// the time's own location.
+func (w DailyWindow) Contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+
+	if w.Start < w.End {
+		return minute >= w.Start && minute < w.End
+	}
+
+	return minute >= w.Start || minute < w.End
+}
+
+func (w DailyWindow) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.Start/60, w.Start%60, w.End/60, w.End%60)
+}
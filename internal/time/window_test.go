@@ -0,0 +1,42 @@
+package time
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDailyWindow(t *testing.T) {
+	at := func(hour int, minute int) time.Time {
+		return time.Date(2025, 1, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		window   string
+		time     time.Time
+		expected bool
+	}{
+		{"01:00-05:00", at(3, 0), true},
+		{"01:00-05:00", at(5, 0), false},
+		{"01:00-05:00", at(0, 59), false},
+		{"22:00-04:00", at(23, 30), true},
+		{"22:00-04:00", at(2, 0), true},
+		{"22:00-04:00", at(12, 0), false},
+	}
+
+	for _, tt := range tests {
+		w, err := ParseDailyWindow(tt.window)
+		if err != nil {
+			t.Fatalf("unexpected error parsing %v: %v", tt.window, err)
+		}
+
+		if actual := w.Contains(tt.time); actual != tt.expected {
+			t.Errorf("%v.Contains(%v) = %v, expected %v", tt.window, tt.time.Format("15:04"), actual, tt.expected)
+		}
+	}
+
+	for _, invalid := range []string{"", "01:00", "1am-5am", "25:00-01:00", "03:00-03:00"} {
+		if _, err := ParseDailyWindow(invalid); err == nil {
+			t.Errorf("expected error parsing %q", invalid)
+		}
+	}
+}
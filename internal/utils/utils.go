@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"os/user"
+	"strconv"
 	"strings"
 	"syscall"
 )
@@ -39,3 +41,93 @@ func EscapeAndJoinArgs(args []string) string {
 
 	return strings.Join(escapedArgs, " ")
 }
+
+// Format a number of bytes in human-readable binary units (i.e. "1.5 MiB").
+func FormatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// Parse a human-readable size, such as "512MiB", "1.5 G", or "1024".
+// Units are always binary, so "1G", "1GB", and "1GiB" are the same size.
+func ParseBytes(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i == -1 {
+		i = len(s)
+	}
+
+	number, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size '%s'", s)
+	}
+
+	unit := strings.ToUpper(strings.TrimSpace(s[i:]))
+	unit = strings.TrimSuffix(strings.TrimSuffix(unit, "B"), "I")
+
+	multiplier := uint64(1)
+	if unit != "" {
+		exp := strings.Index("KMGTPE", unit)
+		if len(unit) != 1 || exp == -1 {
+			return 0, fmt.Errorf("invalid size '%s'", s)
+		}
+		for range exp + 1 {
+			multiplier *= 1024
+		}
+	}
+
+	return uint64(number * float64(multiplier)), nil
+}
+
+// Get the account of the user that invoked this command through sudo
+// or doas, if this command is running as root on their behalf. Returns
+// nil if this command is not running as root for another user.
+func GetInvokingUserAccount() *user.User {
+	if os.Geteuid() != 0 {
+		return nil
+	}
+
+	if sudoUID := os.Getenv("SUDO_UID"); sudoUID != "" {
+		if u, err := user.LookupId(sudoUID); err == nil && u.Uid != "0" {
+			return u
+		}
+	}
+	if doasUser := os.Getenv("DOAS_USER"); doasUser != "" {
+		if u, err := user.Lookup(doasUser); err == nil && u.Uid != "0" {
+			return u
+		}
+	}
+
+	return nil
+}
+
+// Get the name of the user that invoked this command. Commands are
+// usually re-executed as root with ExecAsRoot, so this prefers the
+// name of the original user if it is available.
+func GetInvokingUser() string {
+	if sudoUser := os.Getenv("SUDO_USER"); sudoUser != "" {
+		return sudoUser
+	}
+	if doasUser := os.Getenv("DOAS_USER"); doasUser != "" {
+		return doasUser
+	}
+
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+
+	return fmt.Sprintf("uid %d", os.Getuid())
+}